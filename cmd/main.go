@@ -6,12 +6,18 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/mailer"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/migrations"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/routes"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
 	"github.com/joho/godotenv"
 )
 
@@ -27,6 +33,18 @@ func main() {
 	}
 	defer database.GetMongoClient().Disconnect(context.TODO())
 
+	// Bring indexes and documents up to date, unless they are migrated separately with cmd/migrate
+	if os.Getenv("MIGRATE_ON_STARTUP") != "false" {
+		if _, err := migrations.Run(context.Background(), database.GetMongoClient().Database("esports-tournament-manager")); err != nil {
+			log.Fatalf("Failed to migrate the database: %v", err)
+		}
+	}
+
+	// Check access tokens against their session so revoked sessions stop working straight away
+	repos := models.NewMongoRepositories()
+	auth.SetSessionChecker(models.SessionStore{Sessions: repos.Sessions})
+	auth.SetAPIKeyVerifier(models.APIKeyStore{APIKeys: repos.APIKeys})
+
 	// Get server port from env variable or use default
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -38,6 +56,9 @@ func main() {
 
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true 
+	// Let browsers send If-Match and read ETag for versioned updates
+	config.AddAllowHeaders("If-Match")
+	config.AddExposeHeaders("ETag")
 	router.Use(cors.New(config))
 
 	// SImple root route
@@ -54,19 +75,71 @@ func main() {
 		})
 	})
 
-	// Initialise the websocket hub
+	// Initialise the websocket hub. With EVENT_BUS=mongo events are shared through
+	// MongoDB so every server instance broadcasts every change
 	var WebSocketHub = realtimemanager.NewWebSocketHub()
+	if os.Getenv("EVENT_BUS") == "mongo" {
+		eventsCollection := database.GetMongoClient().Database("esports-tournament-manager").Collection("realtime_events")
+		eventBus, err := realtimemanager.NewMongoEventBus(context.Background(), eventsCollection)
+		if err != nil {
+			log.Fatalf("Failed to create MongoDB event bus: %v", err)
+		}
+
+		WebSocketHub, err = realtimemanager.NewWebSocketHubWithBus(eventBus)
+		if err != nil {
+			log.Fatalf("Failed to subscribe to MongoDB event bus: %v", err)
+		}
+	}
+	defer WebSocketHub.Close()
+
+	// Publish the events recorded by model writes once their transactions commit. Each event
+	// is published by one instance only, which is why several instances need EVENT_BUS=mongo
+	outboxDispatcher, err := models.NewOutboxDispatcher(context.Background(), WebSocketHub)
+	if err != nil {
+		log.Fatalf("Failed to create outbox dispatcher: %v", err)
+	}
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	defer stopDispatch()
+	go outboxDispatcher.Run(dispatchCtx)
+
+	// Rebuild broadcast overlays as the matches they show change
+	go models.NewOverlayFeed(WebSocketHub, repos).Run(dispatchCtx)
+
+	// Deletes cascade by the CASCADE_* rules and can be undone until DELETED_RETENTION has passed
+	cascadeRules, err := models.CascadeRulesFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid cascade rules: %v", err)
+	}
+	deletedRetention, err := services.DeletedRetentionFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid deleted retention: %v", err)
+	}
+
+	// Initialise the services on the MongoDB repositories, and the handlers on the services
+	m := mailer.NewMailerFromEnv()
+	svc := services.NewServices(repos, m, cascadeRules)
 
-	// Initialise handlers
-	userHandler := handlers.NewUserHandler()
-	teamHandler := handlers.NewTeamHandler()
-	tournamentHandler := handlers.NewTournamentHandler()
-	matchHandler := handlers.NewMatchHandler()
-	matchResultHandler := handlers.NewMatchResultHandler()
-	webSocketHandler := handlers.NewWebSocketHandler(WebSocketHub)
+	// Purge deleted documents once they can no longer be restored
+	go services.NewPurgeService(repos.Purger, deletedRetention).Run(dispatchCtx, time.Hour)
+	userHandler := handlers.NewUserHandler(svc.Users, svc.Auth)
+	teamHandler := handlers.NewTeamHandler(svc.Teams)
+	tournamentHandler := handlers.NewTournamentHandler(svc.Tournaments)
+	matchHandler := handlers.NewMatchHandler(svc.Matches)
+	matchResultHandler := handlers.NewMatchResultHandler(svc.MatchResults)
+	webSocketHandler := handlers.NewWebSocketHandler(WebSocketHub, svc.Users, svc.Chat)
+	eventStreamHandler := handlers.NewEventStreamHandler(WebSocketHub, svc.Users, svc.Visibility)
+	liveScoreHandler := handlers.NewLiveScoreHandler(WebSocketHub, svc.LiveScores, svc.Visibility)
+	overlayHandler := handlers.NewOverlayHandler(svc.Overlays)
+	presenceHandler := handlers.NewPresenceHandler(WebSocketHub, svc.Visibility)
+	chatHandler := handlers.NewChatHandler(svc.Chat)
+	oidcHandler := handlers.NewOIDCHandler(auth.LoadOIDCProvidersFromEnv(), svc.Auth, svc.Users)
+	organisationHandler := handlers.NewOrganisationHandler(svc.Organisations)
+	publicHandler := handlers.NewPublicHandler(svc.Public)
+	twoFactorPolicy := handlers.TwoFactorPolicyMiddleware(svc.Users)
+	searchHandler := handlers.NewSearchHandler(svc.Search)
 
-	// Setup WebSocket route
-	router.GET("/ws", func(c *gin.Context) {
+	// Setup WebSocket route, identifying signed in users for presence
+	router.GET("/ws", auth.OptionalAuthMiddleware(os.Getenv("SECRET_KEY")), func(c *gin.Context) {
 		// Upgrade HTTP connection to WebSocket
 		conn, err := realtimemanager.GetUpgrader().Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
@@ -79,11 +152,21 @@ func main() {
 	})
 
 	// Setup routes
-	routes.SetupUserRoutes(router, userHandler)
-	routes.SetupTeamRoutes(router, teamHandler)
-	routes.SetupTournamentRoutes(router, tournamentHandler)
-	routes.SetupMatchRoutes(router, matchHandler)
-	routes.SetupMatchResultRoutes(router, matchResultHandler)
+	routes.SetupUserRoutes(router, userHandler, twoFactorPolicy)
+	routes.SetupTeamRoutes(router, teamHandler, twoFactorPolicy)
+	routes.SetupTournamentRoutes(router, tournamentHandler, twoFactorPolicy)
+	routes.SetupMatchRoutes(router, matchHandler, twoFactorPolicy)
+	routes.SetupMatchResultRoutes(router, matchResultHandler, twoFactorPolicy)
+	routes.SetupEventRoutes(router, eventStreamHandler)
+	routes.SetupLiveScoreRoutes(router, liveScoreHandler, twoFactorPolicy)
+	routes.SetupOverlayRoutes(router, overlayHandler)
+	routes.SetupPresenceRoutes(router, presenceHandler)
+	routes.SetupChatRoutes(router, chatHandler, twoFactorPolicy)
+	routes.SetupOIDCRoutes(router, oidcHandler)
+	routes.SetupOrganisationRoutes(router, organisationHandler, twoFactorPolicy)
+	routes.SetupPublicRoutes(router, publicHandler)
+	routes.SetupMeRoutes(router, tournamentHandler, teamHandler, matchHandler, matchResultHandler)
+	routes.SetupSearchRoutes(router, searchHandler)
 
 	// Start server, or log error if problem with server starting
 	if err := router.Run(":" + port); err != nil {
@@ -0,0 +1,68 @@
+// Command migrate runs the database migrations without starting the server
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/migrations"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	status := flag.Bool("status", false, "list the migrations and whether they have been applied, without running them")
+	release := flag.Int("release", 0, "forget a migration that was started but never finished, so it runs again")
+	flag.Parse()
+
+	// Load environment variables from the .env file, if there is one
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Not loading .env file: %v", err)
+	}
+
+	if err := database.ConnectToMongoDB(); err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer database.GetMongoClient().Disconnect(context.TODO())
+
+	ctx := context.Background()
+	db := database.GetMongoClient().Database("esports-tournament-manager")
+
+	switch {
+	case *status:
+		records, err := migrations.Applied(ctx, db)
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		recorded := make(map[int]migrations.Record, len(records))
+		for _, record := range records {
+			recorded[record.Version] = record
+		}
+
+		for _, migration := range migrations.All() {
+			state := "pending"
+			if record, ok := recorded[migration.Version]; ok {
+				state = "in progress since " + record.StartedAt.Format("2006-01-02 15:04:05")
+				if record.AppliedAt != nil {
+					state = "applied " + record.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+			}
+			fmt.Printf("%4d  %-40s %s\n", migration.Version, migration.Description, state)
+		}
+
+	case *release != 0:
+		if err := migrations.Release(ctx, db, *release); err != nil {
+			log.Fatalf("Failed to release migration: %v", err)
+		}
+		log.Printf("Released migration %d", *release)
+
+	default:
+		ran, err := migrations.Run(ctx, db)
+		if err != nil {
+			log.Fatalf("Failed to migrate the database: %v", err)
+		}
+		log.Printf("Applied %d migrations", len(ran))
+	}
+}
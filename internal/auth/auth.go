@@ -1,18 +1,97 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// Generate JWT token for the given UserID
-func GenerateJWT(userID primitive.ObjectID, jwtSecret string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID.Hex(), //Convert ObjectID to a string
+// Access tokens are short lived, clients use their refresh token to get a new one
+const AccessTokenLifetime = 15 * time.Minute
+
+// Scopes an API key can be given. Tournament scopes are built with TournamentManageScope.
+// Public endpoints need no scope: an API key sent to one is treated like no token at all.
+const ScopeResultsWrite = "results:write"
+
+// Scope for managing one tournament, "tournament:<id>:manage". Routes that only find out
+// which tournament they touch once they have loaded it ask for TournamentManageScope("*"),
+// and the service checks the tournament's own scope with CheckScope.
+func TournamentManageScope(tournamentID string) string {
+	return "tournament:" + tournamentID + ":manage"
+}
+
+// Key the scopes of a request made with an API key are kept under in the gin context,
+// where services read them back through context.Context
+const ScopesContextKey = "api_key_scopes"
+
+// Issuer set on and required in every access token
+const TokenIssuer = "cod-esports-tournament-manager"
+
+// Prefix every API key starts with, so they can be told apart from JWTs
+const APIKeyPrefix = "ctm_"
+
+// Claims carried in an access token. Requests made with an API key get the
+// key's user, ID and scopes instead, and have no session.
+type Claims struct {
+	UserID    string   `json:"user_id"`
+	SessionID string   `json:"sid"`
+	APIKeyID  string   `json:"-"`
+	Scopes    []string `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// Checks whether the session an access token belongs to is still active,
+// so revoked sessions stop working before their access tokens expire
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+var sessionChecker SessionChecker
+
+// SetSessionChecker registers the store AuthMiddleware checks sessions against
+func SetSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
+// Looks up API keys, returning the user and scopes a key grants
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*APIKeyPrincipal, error)
+}
+
+// Who an API key acts as and what it is allowed to do
+type APIKeyPrincipal struct {
+	KeyID  string
+	UserID string
+	Scopes []string
+}
+
+var apiKeyVerifier APIKeyVerifier
+
+// SetAPIKeyVerifier registers the store API keys are checked against
+func SetAPIKeyVerifier(verifier APIKeyVerifier) {
+	apiKeyVerifier = verifier
+}
+
+// Generate JWT token for the given UserID and the session it belongs to
+func GenerateJWT(userID, sessionID primitive.ObjectID, jwtSecret string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID:    userID.Hex(), //Convert ObjectID to a string
+		SessionID: sessionID.Hex(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    TokenIssuer,
+			Subject:   userID.Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenLifetime)),
+		},
 	})
 
 	// Sign token with secret key
@@ -24,57 +103,210 @@ func GenerateJWT(userID primitive.ObjectID, jwtSecret string) (string, error) {
 	return tokenString, nil
 }
 
-// Middleware for protecting routes with JWT authentication
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-
-		if authHeader == "" {
-			cookies := c.Request.Cookies()
-			var tokenString string
-			for _, cookie := range cookies {
-				if cookie.Name == "jwtToken" {
-					tokenString = cookie.Value
-					break
-				}
-			}
+// Errors returned when a request's token cannot be used
+var (
+	ErrMissingToken      = errors.New("Authorisation header is missing")
+	ErrInvalidToken      = errors.New("Invalid token")
+	ErrRevokedToken      = errors.New("Session has been revoked")
+	ErrInvalidAPIKey     = errors.New("Invalid API key")
+	ErrInsufficientScope = errors.New("API key does not have the scope for this request")
+)
 
-			if tokenString == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorisation header is missing"})
-				c.Abort()
-				return
-			}
+// Parses and validates an access token, including its expiry, issuer and signing method
+func ParseJWT(tokenString, jwtSecret string) (*Claims, error) {
+	var claims Claims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(TokenIssuer), jwt.WithIssuedAt())
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens issued before expiry was added never expire, so they are no longer accepted
+	if claims.ExpiresAt == nil || claims.UserID == "" || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
 
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				return []byte(jwtSecret), nil
-			})
+// Reads the JWT from the Authorization header, or the jwtToken cookie if there is no header,
+// and returns its claims once it has been validated. API keys are accepted in the
+// Authorization header or the X-API-Key header.
+func ClaimsFromRequest(c *gin.Context, jwtSecret string) (*Claims, error) {
+	var tokenString string
 
-			if err != nil || !token.Valid {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-				c.Abort()
-				return
+	authHeader := c.GetHeader("Authorization")
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		return claimsFromAPIKey(c, apiKey)
+	}
+	if apiKey := strings.TrimPrefix(authHeader, "Bearer "); strings.HasPrefix(apiKey, APIKeyPrefix) {
+		return claimsFromAPIKey(c, apiKey)
+	}
+
+	if authHeader == "" {
+		for _, cookie := range c.Request.Cookies() {
+			if cookie.Name == "jwtToken" {
+				tokenString = cookie.Value
+				break
 			}
+		}
+	} else {
+		tokenString = strings.Replace(authHeader, "Bearer ", "", 1)
+	}
 
-			// Extract user information from the token and set it in the context
-			claims, _ := token.Claims.(jwt.MapClaims)
-			userID := claims["user_id"].(string)
-			c.Set("user_id", userID)
-
-		} else {
-			tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				return []byte(jwtSecret), nil
-			})
-
-			if err != nil || !token.Valid {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-				c.Abort()
-				return
+	if tokenString == "" {
+		return nil, ErrMissingToken
+	}
+
+	claims, err := ParseJWT(tokenString, jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if sessionChecker != nil {
+		active, err := sessionChecker.IsSessionActive(c, claims.SessionID)
+		if err != nil {
+			log.Printf("Error checking session %s: %v", claims.SessionID, err)
+			return nil, ErrInvalidToken
+		}
+		if !active {
+			return nil, ErrRevokedToken
+		}
+	}
+
+	return claims, nil
+}
+
+func claimsFromAPIKey(c *gin.Context, apiKey string) (*Claims, error) {
+	if apiKeyVerifier == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	principal, err := apiKeyVerifier.VerifyAPIKey(c, apiKey)
+	if err != nil {
+		if err != ErrInvalidAPIKey {
+			log.Printf("Error checking API key: %v", err)
+		}
+		return nil, ErrInvalidAPIKey
+	}
+
+	return &Claims{
+		UserID:   principal.UserID,
+		APIKeyID: principal.KeyID,
+		Scopes:   principal.Scopes,
+	}, nil
+}
+
+// Reports whether claims from an API key grant one of the scopes. A scope can contain
+// "{id}", which is replaced with the route's id parameter. JWTs act as the user and
+// are allowed everything.
+func HasScope(c *gin.Context, claims *Claims, scopes ...string) bool {
+	if claims.APIKeyID == "" {
+		return true
+	}
+
+	for _, scope := range scopes {
+		if scopeGranted(claims.Scopes, strings.ReplaceAll(scope, "{id}", c.Param("id"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks that a request made with an API key has one of the scopes, failing with
+// ErrInsufficientScope if not. Requests without an API key are allowed everything.
+func CheckScope(ctx context.Context, scopes ...string) error {
+	granted, ok := ctx.Value(ScopesContextKey).([]string)
+	if !ok {
+		return nil
+	}
+
+	for _, scope := range scopes {
+		if scopeGranted(granted, scope) {
+			return nil
+		}
+	}
+	return ErrInsufficientScope
+}
+
+// Reports whether a scope is one of those granted. A "*" part of the scope matches any
+// part in its place, so "tournament:*:manage" is met by a key for any tournament.
+func scopeGranted(granted []string, scope string) bool {
+	parts := strings.Split(scope, ":")
+	for _, grantedScope := range granted {
+		grantedParts := strings.Split(grantedScope, ":")
+		if len(grantedParts) != len(parts) {
+			continue
+		}
+
+		matches := true
+		for i, part := range parts {
+			if part != grantedParts[i] && (part != "*" || grantedParts[i] == "") {
+				matches = false
+				break
 			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets the user, session and API key from a request's claims in the context
+func setClaims(c *gin.Context, claims *Claims) {
+	c.Set("user_id", claims.UserID)
+	if claims.SessionID != "" {
+		c.Set("session_id", claims.SessionID)
+	}
+	if claims.APIKeyID != "" {
+		c.Set("api_key_id", claims.APIKeyID)
+		c.Set(ScopesContextKey, append([]string{}, claims.Scopes...))
+	}
+}
+
+// Returns the user ID from the request's token
+func UserIDFromRequest(c *gin.Context, jwtSecret string) (string, error) {
+	claims, err := ClaimsFromRequest(c, jwtSecret)
+	if err != nil {
+		return "", err
+	}
 
-			claims, _ := token.Claims.(jwt.MapClaims)
-			userID := claims["user_id"].(string)
-			c.Set("user_id", userID)
+	return claims.UserID, nil
+}
+
+// Middleware for protecting routes with JWT authentication. API keys are only
+// accepted if they have one of the given scopes, so routes without scopes need a JWT.
+func AuthMiddleware(jwtSecret string, scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := ClaimsFromRequest(c, jwtSecret)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if !HasScope(c, claims, scopes...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": ErrInsufficientScope.Error()})
+			c.Abort()
+			return
+		}
+
+		// Extract user information from the token and set it in the context
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// Middleware for public routes that behave differently for signed in users.
+// Sets the user ID when a valid login token is sent and lets every request through.
+// API keys are ignored, so a request made with one is anonymous.
+func OptionalAuthMiddleware(jwtSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims, err := ClaimsFromRequest(c, jwtSecret); err == nil && claims.APIKeyID == "" {
+			setClaims(c, claims)
 		}
 		c.Next()
 	}
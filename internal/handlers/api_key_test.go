@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Creates an API key with the scopes, returning the key
+func (s *testServer) createAPIKey(token string, scopes ...string) string {
+	s.t.Helper()
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	s.expect(http.StatusCreated, "POST", "/users/api-keys", token, map[string]interface{}{"name": "bot", "scopes": scopes}, &created)
+	return created.Key
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+
+	var organisation document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+
+	tournament := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"Name":           name,
+			"StartDate":      "2026-03-01T00:00:00Z",
+			"EndDate":        "2026-03-05T00:00:00Z",
+			"OrganiserID":    userID,
+			"OrganisationID": organisation.ID,
+		}
+	}
+	var scoped, other document
+	s.expect(http.StatusCreated, "POST", "/tournaments/", token, tournament("Scoped"), &scoped)
+	s.expect(http.StatusCreated, "POST", "/tournaments/", token, tournament("Other"), &other)
+
+	var team1, team2 document
+	for name, team := range map[string]*document{"Optic": &team1, "FaZe": &team2} {
+		s.expect(http.StatusCreated, "POST", "/teams/", token, map[string]interface{}{
+			"Name":           name,
+			"OrganiserID":    userID,
+			"OrganisationID": organisation.ID,
+		}, team)
+	}
+
+	match := func(tournamentID string) map[string]interface{} {
+		return map[string]interface{}{
+			"TournamentID":   tournamentID,
+			"OrganiserID":    userID,
+			"OrganisationID": organisation.ID,
+			"Team1ID":        team1.ID,
+			"Team2ID":        team2.ID,
+		}
+	}
+	var scopedMatch, otherMatch document
+	s.expect(http.StatusCreated, "POST", "/matches/", token, match(scoped.ID), &scopedMatch)
+	s.expect(http.StatusCreated, "POST", "/matches/", token, match(other.ID), &otherMatch)
+
+	key := s.createAPIKey(token, "tournament:"+scoped.ID+":manage")
+
+	// The key manages its own tournament and the matches in it, and nothing else
+	s.expect(http.StatusOK, "GET", "/tournaments/"+scoped.ID, key, nil, &scoped)
+	s.expect(http.StatusOK, "PATCH", "/tournaments/"+scoped.ID, key, map[string]interface{}{"Description": "Set by a bot", "Version": scoped.Version}, nil)
+	s.expect(http.StatusForbidden, "GET", "/tournaments/"+other.ID, key, nil, nil)
+	s.expect(http.StatusForbidden, "POST", "/tournaments/", key, tournament("Made by a bot"), nil)
+
+	s.expect(http.StatusOK, "GET", "/matches/"+scopedMatch.ID, key, nil, nil)
+	s.expect(http.StatusCreated, "POST", "/matches/", key, match(scoped.ID), nil)
+	s.expect(http.StatusForbidden, "GET", "/matches/"+otherMatch.ID, key, nil, nil)
+	s.expect(http.StatusForbidden, "DELETE", "/matches/"+otherMatch.ID, key, nil, nil)
+	s.expect(http.StatusForbidden, "POST", "/matches/", key, match(other.ID), nil)
+
+	// Moving a match out of the key's tournament needs the scope for where it goes too
+	s.expect(http.StatusForbidden, "PATCH", "/matches/"+scopedMatch.ID, key, map[string]interface{}{"TournamentID": other.ID, "Version": 1}, nil)
+
+	s.expect(http.StatusForbidden, "GET", "/teams/"+team1.ID, key, nil, nil)
+
+	// results:write is for results, not matches
+	resultsKey := s.createAPIKey(token, "results:write")
+	s.expect(http.StatusForbidden, "GET", "/matches/"+scopedMatch.ID, resultsKey, nil, nil)
+	s.expect(http.StatusCreated, "POST", "/match-results/", resultsKey, map[string]interface{}{
+		"MatchID":     scopedMatch.ID,
+		"OrganiserID": userID,
+		"WinnerID":    team1.ID,
+		"LoserID":     team2.ID,
+		"WinnerScore": 3,
+		"LoserScore":  0,
+	}, nil)
+}
+
+func TestAPIKeyScopesAreValidated(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp("organiser@example.com")
+
+	for _, scope := range []string{"read:public", "tournament:*:manage", "tournament:not-an-id:manage"} {
+		if code := s.do("POST", "/users/api-keys", token, map[string]interface{}{"name": "bot", "scopes": []string{scope}}, nil); code != http.StatusBadRequest {
+			t.Errorf("creating a key with scope %q: got status %d, want %d", scope, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestTournamentKeysOnlyWriteResultsInTheirTournament(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	scopedMatchID, scopedTeam1ID, scopedTeam2ID := s.createMatch(userID, token)
+	otherMatchID, otherTeam1ID, otherTeam2ID := s.createMatch(userID, token)
+
+	var scopedMatch struct {
+		TournamentID string
+	}
+	s.expect(http.StatusOK, "GET", "/matches/"+scopedMatchID, token, nil, &scopedMatch)
+	key := s.createAPIKey(token, "tournament:"+scopedMatch.TournamentID+":manage")
+
+	result := func(matchID, winnerID, loserID string) map[string]interface{} {
+		return map[string]interface{}{
+			"MatchID":     matchID,
+			"OrganiserID": userID,
+			"WinnerID":    winnerID,
+			"LoserID":     loserID,
+			"WinnerScore": 3,
+			"LoserScore":  1,
+		}
+	}
+	score := map[string]interface{}{"map_number": 1, "mode": "search_and_destroy", "team1_score": 2, "team2_score": 1}
+
+	s.expect(http.StatusCreated, "POST", "/match-results/", key, result(scopedMatchID, scopedTeam1ID, scopedTeam2ID), nil)
+	s.expect(http.StatusOK, "POST", "/matches/"+scopedMatchID+"/live", key, score, nil)
+
+	// The user can manage the other tournament, but the key can't
+	s.expect(http.StatusForbidden, "POST", "/match-results/", key, result(otherMatchID, otherTeam1ID, otherTeam2ID), nil)
+	s.expect(http.StatusForbidden, "POST", "/matches/"+otherMatchID+"/live", key, score, nil)
+
+	var otherResult document
+	s.expect(http.StatusCreated, "POST", "/match-results/", token, result(otherMatchID, otherTeam1ID, otherTeam2ID), &otherResult)
+	item := "/match-results/" + otherResult.ID
+	s.expect(http.StatusForbidden, "GET", item, key, nil, nil)
+	s.expect(http.StatusForbidden, "PATCH", item, key, map[string]interface{}{"LoserScore": 2, "Version": otherResult.Version}, nil)
+	s.expect(http.StatusForbidden, "DELETE", item, key, nil, nil)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+)
+
+// Gets where a request came from, to record on the session it starts
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// Sets the access and refresh tokens as HTTP cookies
+func setAuthCookies(c *gin.Context, tokens *models.AuthTokens) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "jwtToken",
+		Value:    tokens.AccessToken,
+		Path:     "/",
+		MaxAge:   int(auth.AccessTokenLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	// The refresh token is only ever sent to the user routes
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "refreshToken",
+		Value:    tokens.RefreshToken,
+		Path:     "/users",
+		MaxAge:   int(models.RefreshTokenLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+// Clears the access and refresh token cookies
+func clearAuthCookies(c *gin.Context) {
+	for name, path := range map[string]string{"jwtToken": "/", "refreshToken": "/users"} {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     path,
+			HttpOnly: true,
+			Secure:   true,
+			MaxAge:   -1,
+			SameSite: http.SameSiteNoneMode,
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ChatHandler struct {
+	Chat *services.ChatService
+}
+
+// Loads the match in the URL and the current user's access to its chat,
+// writing the error response and returning false if either fails
+func (h *ChatHandler) loadChatAccess(c *gin.Context) (*models.Match, *models.ChatAccess, bool) {
+	matchID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
+		return nil, nil, false
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	match, access, err := h.Chat.Access(c, userID, matchID)
+	if err != nil {
+		respondError(c, err)
+		return nil, nil, false
+	}
+
+	return match, access, true
+}
+
+// Handles getting a page of a match's chat history
+func (h *ChatHandler) GetChatMessages(c *gin.Context) {
+	match, _, ok := h.loadChatAccess(c)
+	if !ok {
+		return
+	}
+
+	var before primitive.ObjectID
+	if beforeStr := c.Query("before"); beforeStr != "" {
+		var err error
+		before, err = primitive.ObjectIDFromHex(beforeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before message ID format"})
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	chatMessages, err := h.Chat.ListMessages(c, match, before, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response := gin.H{"messages": chatMessages}
+	if len(chatMessages) > 0 {
+		response["next_before"] = chatMessages[len(chatMessages)-1].ID.Hex()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Handles posting a chat message over REST
+func (h *ChatHandler) CreateChatMessage(c *gin.Context) {
+	var request struct {
+		Body string `json:"body" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	match, access, ok := h.loadChatAccess(c)
+	if !ok {
+		return
+	}
+
+	chatMessage, err := h.Chat.Post(c, match, access, request.Body)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, chatMessage)
+}
+
+// Handles a moderator deleting a chat message
+func (h *ChatHandler) DeleteChatMessage(c *gin.Context) {
+	messageID, err := primitive.ObjectIDFromHex(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+		return
+	}
+
+	match, access, ok := h.loadChatAccess(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Chat.DeleteMessage(c, match, access, messageID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted successfully"})
+}
+
+// Handles a moderator muting a user in a match's chat
+func (h *ChatHandler) MuteChatUser(c *gin.Context) {
+	var request struct {
+		UserID          string `json:"user_id" binding:"required"`
+		DurationMinutes int    `json:"duration_minutes" binding:"required,min=1"`
+		Reason          string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mutedUserID, err := primitive.ObjectIDFromHex(request.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	match, access, ok := h.loadChatAccess(c)
+	if !ok {
+		return
+	}
+
+	duration := time.Duration(request.DurationMinutes) * time.Minute
+	mute, err := h.Chat.Mute(c, match, access, mutedUserID, duration, request.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, mute)
+}
+
+// Handles a moderator lifting a user's mute
+func (h *ChatHandler) UnmuteChatUser(c *gin.Context) {
+	mutedUserID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	match, access, ok := h.loadChatAccess(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Chat.Unmute(c, match, access, mutedUserID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unmuted successfully"})
+}
+
+func NewChatHandler(chat *services.ChatService) *ChatHandler {
+	return &ChatHandler{
+		Chat: chat,
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+)
+
+// The status code for each kind of service error
+var errorStatuses = map[services.Kind]int{
+	services.KindNotFound:     http.StatusNotFound,
+	services.KindForbidden:    http.StatusForbidden,
+	services.KindConflict:     http.StatusConflict,
+	services.KindValidation:   http.StatusBadRequest,
+	services.KindUnauthorized: http.StatusUnauthorized,
+}
+
+// Writes the response for an error returned by a service
+func respondError(c *gin.Context, err error) {
+	status, ok := errorStatuses[services.KindOf(err)]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	// Locked out logins tell the client when to try again
+	var lockedErr *models.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+	}
+
+	// Updates made against an old version come back with what is stored now
+	var conflictErr *services.VersionConflictError
+	if errors.As(err, &conflictErr) {
+		setETag(c, conflictErr.Version)
+		c.JSON(status, gin.H{"error": err.Error(), "current": conflictErr.Current})
+		return
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
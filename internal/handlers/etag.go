@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sets the ETag header to a document's version
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// Reads the version an update is made against from the If-Match header. No header or *
+// gives 0. Writes a 400 and returns false if the header isn't a version.
+func ifMatchVersion(c *gin.Context) (int64, bool) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(value, "W/"), `"`), 10, 64)
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match must be an ETag from this API"})
+		return 0, false
+	}
+	return version, true
+}
+
+// Reads the version an update is made against from the If-Match header, or the version
+// in the body if there is no header. An update without either would overwrite whatever is
+// stored, so it is refused with a 428. Writes the error and returns false if there is no
+// version to use.
+func updateVersion(c *gin.Context, bodyVersion int64) (int64, bool) {
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return 0, false
+	}
+	if version == 0 {
+		version = bodyVersion
+	}
+	if version < 1 {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "Updates must send the version they were made against in If-Match or the body"})
+		return 0, false
+	}
+	return version, true
+}
+
+// Gets the version in a JSON merge patch, or 0 if it doesn't have one
+func patchVersion(patch []byte) int64 {
+	var fields struct {
+		Version int64
+	}
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return 0
+	}
+	return fields.Version
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How often a comment is sent to keep idle streams open through proxies
+const eventStreamKeepAlive = 15 * time.Second
+
+type EventStreamHandler struct {
+	WebSocketHub *realtimemanager.WebSocketHub
+	Users        *services.UserService
+	Visibility   *services.VisibilityService
+}
+
+// Streams hub events to the client as Server-Sent Events
+func (h *EventStreamHandler) StreamEvents(c *gin.Context) {
+	var topics []string
+	for _, topic := range strings.Split(c.Query("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	if len(topics) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Choose the topics to stream with the topics parameter"})
+		return
+	}
+
+	// Private topics such as match chat are only available over the WebSocket
+	if len(publicTopics(topics)) != len(topics) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Private topics cannot be streamed"})
+		return
+	}
+
+	// Only tournaments the user could fetch can be streamed, so drafts aren't given away
+	userID := optionalUserID(c)
+	for _, topic := range topics {
+		if !h.checkTopic(c, userID, topic) {
+			return
+		}
+	}
+
+	// Subscribe before reading the history so nothing is missed in between
+	sub := h.WebSocketHub.SubscribeAs(presenceIdentity(c, h.Users), topics...)
+	defer h.WebSocketHub.Unsubscribe(sub)
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// Replay anything the client missed while disconnected
+	replayed := make(map[string]struct{})
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	if lastEventID != "" {
+		missed, ok := h.WebSocketHub.EventsSince(lastEventID, topics...)
+		if !ok {
+			// The event is too old to resume from, the client should refetch its state
+			c.Render(-1, sse.Event{Event: "resync", Data: lastEventID})
+		}
+		for _, event := range missed {
+			if onTopic(event, topics) {
+				renderEvent(c, event)
+			}
+			replayed[event.ID] = struct{}{}
+		}
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+			if _, seen := replayed[event.ID]; !seen && onTopic(event, topics) {
+				renderEvent(c, event)
+			}
+			return true
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// Checks the user can see what a topic is about, writing the error response and returning
+// false if not. Overlay topics are about a match. Other kinds of topic can't be checked, so
+// they can't be streamed.
+func (h *EventStreamHandler) checkTopic(c *gin.Context, userID primitive.ObjectID, topic string) bool {
+	kind, idStr, _ := strings.Cut(topic, ":")
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic " + topic})
+		return false
+	}
+
+	switch kind {
+	case "tournament":
+		_, err = h.Visibility.Tournament(c, userID, id)
+	case "match", "overlay":
+		_, err = h.Visibility.Match(c, userID, id)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only tournament, match and overlay topics can be streamed"})
+		return false
+	}
+	if err != nil {
+		respondError(c, err)
+		return false
+	}
+	return true
+}
+
+// Reports whether an event is for one of the topics. Events without topics go to every
+// subscription on the hub, but a stream only sends what it was allowed to subscribe to.
+func onTopic(event realtimemanager.Event, topics []string) bool {
+	for _, topic := range event.Topics {
+		for _, allowed := range topics {
+			if topic == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Writes a hub event in Server-Sent Events format
+func renderEvent(c *gin.Context, event realtimemanager.Event) {
+	c.Render(-1, sse.Event{
+		Id:    event.ID,
+		Event: event.Action,
+		Data:  string(event.Data),
+	})
+}
+
+func NewEventStreamHandler(webSocketHub *realtimemanager.WebSocketHub, users *services.UserService, visibility *services.VisibilityService) *EventStreamHandler {
+	return &EventStreamHandler{
+		WebSocketHub: webSocketHub,
+		Users:        users,
+		Visibility:   visibility,
+	}
+}
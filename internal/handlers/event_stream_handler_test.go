@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+)
+
+func TestEventStreamsOnlyCarryVisibleTopics(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	draftMatchID, _, _ := s.createMatch(userID, token)
+	publishedMatchID, _, _ := s.createMatch(userID, token)
+
+	tournamentOf := func(matchID string) string {
+		var match struct {
+			TournamentID string
+		}
+		s.expect(http.StatusOK, "GET", "/matches/"+matchID, token, nil, &match)
+		return match.TournamentID
+	}
+	draftID, publishedID := tournamentOf(draftMatchID), tournamentOf(publishedMatchID)
+
+	var tournament document
+	s.expect(http.StatusOK, "GET", "/tournaments/"+publishedID, token, nil, &tournament)
+	s.expect(http.StatusOK, "PATCH", "/tournaments/"+publishedID, token, map[string]interface{}{"Published": true, "Version": tournament.Version}, nil)
+
+	// Streams have to say what they are for, and can only be for what the user could fetch
+	s.expect(http.StatusBadRequest, "GET", "/events", "", nil, nil)
+	s.expect(http.StatusBadRequest, "GET", "/events?topics=team:"+publishedID, "", nil, nil)
+	s.expect(http.StatusNotFound, "GET", "/events?topics=tournament:"+draftID, "", nil, nil)
+	s.expect(http.StatusNotFound, "GET", "/events?topics=tournament:"+publishedID+",match:"+draftMatchID, "", nil, nil)
+
+	// Resuming a published tournament's stream doesn't replay anything else it missed
+	start := s.hub.Publish(realtimemanager.Event{Action: "start", Topics: []string{realtimemanager.Topic("tournament", publishedID)}})
+	s.hub.Publish(realtimemanager.Event{Action: "tournament_updated", Topics: []string{realtimemanager.Topic("tournament", draftID)}, Data: []byte(`"draft"`)})
+	s.hub.Publish(realtimemanager.Event{Action: "announcement", Data: []byte(`"draft"`)})
+	s.hub.Publish(realtimemanager.Event{Action: "tournament_updated", Topics: []string{realtimemanager.Topic("tournament", publishedID)}, Data: []byte(`"published"`)})
+
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events?topics=tournament:"+publishedID, nil)
+	req.Header.Set("Last-Event-ID", start.ID)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d streaming a published tournament", res.StatusCode)
+	}
+
+	lines := bufio.NewScanner(res.Body)
+	for lines.Scan() {
+		line := lines.Text()
+		if strings.Contains(line, "draft") {
+			t.Fatalf("stream replayed %q", line)
+		}
+		if strings.Contains(line, "published") {
+			return
+		}
+	}
+	t.Fatalf("stream ended before replaying the published tournament's event: %v", lines.Err())
+}
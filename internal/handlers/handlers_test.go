@@ -0,0 +1,363 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/mailer"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/routes"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Setenv("SECRET_KEY", "handlers-test-secret")
+	os.Exit(m.Run())
+}
+
+// The API on memory repositories, wired like cmd/main.go
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	repos  *models.Repositories
+	svc    *services.Services
+	hub    *realtimemanager.WebSocketHub
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	repos := models.NewMemoryRepositories()
+	auth.SetSessionChecker(models.SessionStore{Sessions: repos.Sessions})
+	auth.SetAPIKeyVerifier(models.APIKeyStore{APIKeys: repos.APIKeys})
+
+	svc := services.NewServices(repos, &mailer.LogMailer{Path: t.TempDir() + "/mail.log"}, models.DefaultCascadeRules())
+	twoFactorPolicy := handlers.TwoFactorPolicyMiddleware(svc.Users)
+
+	hub := realtimemanager.NewWebSocketHub()
+	t.Cleanup(hub.Close)
+
+	router := gin.New()
+	routes.SetupUserRoutes(router, handlers.NewUserHandler(svc.Users, svc.Auth), twoFactorPolicy)
+	routes.SetupOrganisationRoutes(router, handlers.NewOrganisationHandler(svc.Organisations), twoFactorPolicy)
+	routes.SetupTeamRoutes(router, handlers.NewTeamHandler(svc.Teams), twoFactorPolicy)
+	routes.SetupTournamentRoutes(router, handlers.NewTournamentHandler(svc.Tournaments), twoFactorPolicy)
+	routes.SetupMatchRoutes(router, handlers.NewMatchHandler(svc.Matches), twoFactorPolicy)
+	routes.SetupMatchResultRoutes(router, handlers.NewMatchResultHandler(svc.MatchResults), twoFactorPolicy)
+	routes.SetupLiveScoreRoutes(router, handlers.NewLiveScoreHandler(hub, svc.LiveScores, svc.Visibility), twoFactorPolicy)
+	routes.SetupChatRoutes(router, handlers.NewChatHandler(svc.Chat), twoFactorPolicy)
+	routes.SetupPresenceRoutes(router, handlers.NewPresenceHandler(hub, svc.Visibility))
+	routes.SetupEventRoutes(router, handlers.NewEventStreamHandler(hub, svc.Users, svc.Visibility))
+	routes.SetupSearchRoutes(router, handlers.NewSearchHandler(svc.Search))
+	routes.SetupMeRoutes(router, handlers.NewTournamentHandler(svc.Tournaments), handlers.NewTeamHandler(svc.Teams), handlers.NewMatchHandler(svc.Matches), handlers.NewMatchResultHandler(svc.MatchResults))
+
+	return &testServer{t: t, router: router, repos: repos, svc: svc, hub: hub}
+}
+
+// Sends a request, with the token as a bearer token if there is one, and decodes the JSON response into out
+func (s *testServer) do(method, path, token string, body interface{}, out interface{}) int {
+	s.t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			s.t.Fatalf("encoding %s %s: %v", method, path, err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	if out != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("decoding %s %s: %v (%s)", method, path, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+// Like do, failing the test unless the response has the wanted status
+func (s *testServer) expect(want int, method, path, token string, body interface{}, out interface{}) {
+	s.t.Helper()
+
+	var raw json.RawMessage
+	if got := s.do(method, path, token, body, &raw); got != want {
+		s.t.Fatalf("%s %s: got status %d, want %d (%s)", method, path, got, want, raw)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			s.t.Fatalf("decoding %s %s: %v", method, path, err)
+		}
+	}
+}
+
+// Registers a user, verifies their email and signs them in, returning their ID and access token
+func (s *testServer) signUp(email string) (string, string) {
+	s.t.Helper()
+
+	const password = "correct-horse-battery"
+	s.expect(http.StatusCreated, "POST", "/users/register", "", map[string]string{"username": email, "email": email, "password": password}, nil)
+
+	user, err := s.repos.Users.GetByEmail(context.Background(), email)
+	if err != nil {
+		s.t.Fatalf("registered user not stored: %v", err)
+	}
+	if err := s.repos.Users.VerifyEmail(context.Background(), user.ID); err != nil {
+		s.t.Fatalf("verifying email: %v", err)
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	s.expect(http.StatusOK, "POST", "/users/login", "", map[string]string{"email": email, "password": password}, &login)
+	if login.Token == "" {
+		s.t.Fatalf("login returned no token")
+	}
+
+	return user.ID.Hex(), login.Token
+}
+
+// A stored document, by the fields the tests look at
+type document struct {
+	ID      string
+	Name    string
+	Version int64
+}
+
+// Walks a resource through create, get, update, patch, delete and restore, returning its ID
+func (s *testServer) lifecycle(path, token string, create, update, patch map[string]interface{}) string {
+	s.t.Helper()
+
+	var created document
+	s.expect(http.StatusCreated, "POST", path+"/", token, create, &created)
+	if created.ID == "" || created.Version != 1 {
+		s.t.Fatalf("POST %s: got %+v, want an ID at version 1", path, created)
+	}
+	item := path + "/" + created.ID
+
+	var got document
+	s.expect(http.StatusOK, "GET", item, token, nil, &got)
+	if got.ID != created.ID {
+		s.t.Fatalf("GET %s: got ID %s", item, got.ID)
+	}
+
+	s.expect(http.StatusOK, "PUT", item, token, copyFields(update, map[string]interface{}{"Version": got.Version}), nil)
+	s.expect(http.StatusOK, "GET", item, token, nil, &got)
+	if got.Version != 2 {
+		s.t.Fatalf("GET %s after PUT: got version %d, want 2", item, got.Version)
+	}
+
+	var patched document
+	s.expect(http.StatusOK, "PATCH", item, token, copyFields(patch, map[string]interface{}{"Version": got.Version}), &patched)
+	if patched.Version != 3 {
+		s.t.Fatalf("PATCH %s: got version %d, want 3", item, patched.Version)
+	}
+
+	s.expect(http.StatusOK, "DELETE", item, token, nil, nil)
+	s.expect(http.StatusNotFound, "GET", item, token, nil, nil)
+
+	s.expect(http.StatusOK, "POST", item+"/restore", token, nil, nil)
+	s.expect(http.StatusOK, "GET", item, token, nil, &got)
+	if got.ID != created.ID {
+		s.t.Fatalf("GET %s after restore: got ID %s", item, got.ID)
+	}
+
+	return created.ID
+}
+
+func TestCRUDLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+
+	var organisation document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+
+	tournament := map[string]interface{}{
+		"Name":           "Major",
+		"StartDate":      "2026-03-01T00:00:00Z",
+		"EndDate":        "2026-03-05T00:00:00Z",
+		"OrganiserID":    userID,
+		"OrganisationID": organisation.ID,
+	}
+	renamedTournament := copyFields(tournament, map[string]interface{}{"Name": "Major Finals"})
+	tournamentID := s.lifecycle("/tournaments", token, tournament, renamedTournament, map[string]interface{}{"Description": "Top eight"})
+
+	team := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"Name":           name,
+			"OrganiserID":    userID,
+			"OrganisationID": organisation.ID,
+			"TournamentID":   tournamentID,
+		}
+	}
+	team1ID := s.lifecycle("/teams", token, team("Optic"), team("OpTic Texas"), map[string]interface{}{"LogoURL": "https://example.com/optic.png"})
+	team2ID := s.lifecycle("/teams", token, team("FaZe"), team("Atlanta FaZe"), map[string]interface{}{"Players": []string{"Simp", "aBeZy"}})
+
+	match := map[string]interface{}{
+		"TournamentID":   tournamentID,
+		"OrganiserID":    userID,
+		"OrganisationID": organisation.ID,
+		"Team1ID":        team1ID,
+		"Team2ID":        team2ID,
+		"Date":           "2026-03-02T18:00:00Z",
+	}
+	rescheduledMatch := copyFields(match, map[string]interface{}{"Date": "2026-03-03T18:00:00Z"})
+	matchID := s.lifecycle("/matches", token, match, rescheduledMatch, map[string]interface{}{"Date": "2026-03-04T18:00:00Z"})
+
+	matchResult := map[string]interface{}{
+		"MatchID":     matchID,
+		"OrganiserID": userID,
+		"WinnerID":    team1ID,
+		"LoserID":     team2ID,
+		"WinnerScore": 3,
+		"LoserScore":  1,
+	}
+	correctedResult := copyFields(matchResult, map[string]interface{}{"LoserScore": 2})
+	s.lifecycle("/match-results", token, matchResult, correctedResult, map[string]interface{}{"WinnerScore": 3})
+
+	// The tournament keeps its teams and match through all of that
+	var stored struct {
+		Teams   []string
+		Matches []string
+	}
+	s.expect(http.StatusOK, "GET", "/tournaments/"+tournamentID, token, nil, &stored)
+	if len(stored.Teams) != 2 || len(stored.Matches) != 1 || stored.Matches[0] != matchID {
+		t.Fatalf("tournament lists teams %v and matches %v", stored.Teams, stored.Matches)
+	}
+}
+
+func TestCRUDForbiddenToOtherUsers(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	_, otherToken := s.signUp("someone-else@example.com")
+
+	var organisation document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+
+	var tournament document
+	s.expect(http.StatusCreated, "POST", "/tournaments/", token, map[string]interface{}{
+		"Name":           "Major",
+		"StartDate":      "2026-03-01T00:00:00Z",
+		"EndDate":        "2026-03-05T00:00:00Z",
+		"OrganiserID":    userID,
+		"OrganisationID": organisation.ID,
+	}, &tournament)
+
+	item := "/tournaments/" + tournament.ID
+	s.expect(http.StatusForbidden, "GET", item, otherToken, nil, nil)
+	s.expect(http.StatusForbidden, "PATCH", item, otherToken, map[string]interface{}{"Name": "Mine now", "Version": 1}, nil)
+	s.expect(http.StatusForbidden, "DELETE", item, otherToken, nil, nil)
+	s.expect(http.StatusUnauthorized, "GET", item, "", nil, nil)
+}
+
+func TestTwoFactorPolicyOnMemoryRepositories(t *testing.T) {
+	t.Setenv("REQUIRE_2FA_ROLES", "organiser")
+
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+
+	var organisation document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+
+	// Being in an organisation makes the user an organiser, who must turn on two-factor first
+	s.expect(http.StatusForbidden, "POST", "/tournaments/", token, map[string]interface{}{
+		"Name":           "Major",
+		"StartDate":      "2026-03-01T00:00:00Z",
+		"EndDate":        "2026-03-05T00:00:00Z",
+		"OrganiserID":    userID,
+		"OrganisationID": organisation.ID,
+	}, nil)
+}
+
+func TestTwoFactorPolicyOnEveryWriteRoute(t *testing.T) {
+	t.Setenv("REQUIRE_2FA_ROLES", "organiser")
+
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	memberID, _ := s.signUp("member@example.com")
+
+	var organisation document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+
+	matchID := primitive.NewObjectID().Hex()
+	team := map[string]interface{}{"Name": "Optic", "OrganiserID": userID, "OrganisationID": organisation.ID}
+	writes := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"POST", "/teams/", team},
+		{"POST", "/organisations/", map[string]string{"name": "Another org"}},
+		{"POST", "/organisations/" + organisation.ID + "/members", map[string]string{"user_id": memberID, "role": "member"}},
+		{"PUT", "/organisations/" + organisation.ID + "/members/" + memberID, map[string]string{"role": "admin"}},
+		{"DELETE", "/organisations/" + organisation.ID + "/members/" + memberID, nil},
+		{"POST", "/matches/" + matchID + "/live", map[string]interface{}{"map_number": 1}},
+		{"POST", "/matches/" + matchID + "/chat/messages", map[string]string{"content": "gg"}},
+		{"POST", "/matches/" + matchID + "/chat/mutes", map[string]string{"user_id": memberID}},
+		{"POST", "/users/api-keys", map[string]interface{}{"name": "bot", "scopes": []string{"results:write"}}},
+	}
+	for _, write := range writes {
+		var refused struct {
+			Error string `json:"error"`
+		}
+		s.expect(http.StatusForbidden, write.method, write.path, token, write.body, &refused)
+		if refused.Error != models.ErrTwoFactorRequired.Error() {
+			t.Errorf("%s %s: refused with %q, want the two-factor policy", write.method, write.path, refused.Error)
+		}
+	}
+
+	// Their own account stays open, so they can turn two-factor on
+	s.expect(http.StatusOK, "GET", "/users/sessions", token, nil, nil)
+
+	user, err := s.repos.Users.GetByID(context.Background(), mustObjectID(t, userID))
+	if err != nil {
+		t.Fatalf("loading user: %v", err)
+	}
+	if err := s.repos.Users.SetTwoFactorPendingSecret(context.Background(), user.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatalf("setting up two-factor: %v", err)
+	}
+	if err := s.repos.Users.EnableTwoFactor(context.Background(), user.ID, "JBSWY3DPEHPK3PXP", nil, 0); err != nil {
+		t.Fatalf("enabling two-factor: %v", err)
+	}
+
+	s.expect(http.StatusCreated, "POST", "/teams/", token, team, nil)
+	s.createAPIKey(token, "results:write")
+}
+
+func mustObjectID(t *testing.T, hex string) primitive.ObjectID {
+	t.Helper()
+
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		t.Fatalf("parsing ID %q: %v", hex, err)
+	}
+	return id
+}
+
+// A copy of fields with the changes applied
+func copyFields(fields, changes map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		copied[name] = value
+	}
+	for name, value := range changes {
+		copied[name] = value
+	}
+	return copied
+}
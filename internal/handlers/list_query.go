@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+)
+
+// Reads a list's filters, sort, limit and cursor from the query string, writing a 400 and
+// returning false if they aren't valid. organisation_id is left to listParams.
+func listQuery(c *gin.Context, schema models.ListSchema) (models.ListQuery, bool) {
+	values := c.Request.URL.Query()
+	values.Del("organisation_id")
+
+	query, err := models.ParseListQuery(schema, values)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return query, false
+	}
+	return query, true
+}
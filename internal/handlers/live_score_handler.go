@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LiveScoreHandler struct {
+	WebSocketHub *realtimemanager.WebSocketHub
+	LiveScores   *services.LiveScoreService
+	Visibility   *services.VisibilityService
+}
+
+// Handles an incremental score update for the map being played in a match
+func (h *LiveScoreHandler) UpdateLiveScore(c *gin.Context) {
+	matchID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var score models.LiveScore
+	if err := c.ShouldBindJSON(&score); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mapResult, err := h.LiveScores.Save(c, userID, matchID, &score)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// The final update for a map becomes its persisted result, and the outbox broadcasts both
+	if mapResult != nil {
+		c.JSON(http.StatusCreated, mapResult)
+		return
+	}
+
+	// Other live updates go straight to the hub, they are too frequent and short lived for the outbox
+	if messageJSON, err := json.Marshal(score.Message()); err != nil {
+		log.Printf("Error marshaling WebSocket message: %v", err)
+	} else {
+		h.WebSocketHub.Broadcast(messageJSON)
+	}
+
+	c.JSON(http.StatusOK, score)
+}
+
+// Handles getting the latest live score for a match
+func (h *LiveScoreHandler) GetLiveScore(c *gin.Context) {
+	matchID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
+		return
+	}
+
+	if _, err := h.Visibility.Match(c, optionalUserID(c), matchID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	score, err := h.LiveScores.Get(c, matchID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, score)
+}
+
+// Handles getting the results of each map played in a match
+func (h *LiveScoreHandler) GetMapResults(c *gin.Context) {
+	matchID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
+		return
+	}
+
+	if _, err := h.Visibility.Match(c, optionalUserID(c), matchID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	mapResults, err := h.LiveScores.ListMapResults(c, matchID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mapResults)
+}
+
+func NewLiveScoreHandler(webSocketHub *realtimemanager.WebSocketHub, liveScores *services.LiveScoreService, visibility *services.VisibilityService) *LiveScoreHandler {
+	return &LiveScoreHandler{
+		WebSocketHub: webSocketHub,
+		LiveScores:   liveScores,
+		Visibility:   visibility,
+	}
+}
@@ -0,0 +1,145 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+)
+
+// Creates an organisation, a tournament in it and a match between two new teams, returning
+// the match's ID and its teams' IDs
+func (s *testServer) createMatch(userID, token string) (string, string, string) {
+	s.t.Helper()
+
+	var organisation, tournament document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+	s.expect(http.StatusCreated, "POST", "/tournaments/", token, map[string]interface{}{
+		"Name":           "Major",
+		"StartDate":      "2026-03-01T00:00:00Z",
+		"EndDate":        "2026-03-05T00:00:00Z",
+		"OrganiserID":    userID,
+		"OrganisationID": organisation.ID,
+	}, &tournament)
+
+	var team1, team2, match document
+	for name, team := range map[string]*document{"Optic": &team1, "FaZe": &team2} {
+		s.expect(http.StatusCreated, "POST", "/teams/", token, map[string]interface{}{
+			"Name":           name,
+			"OrganiserID":    userID,
+			"OrganisationID": organisation.ID,
+		}, team)
+	}
+	s.expect(http.StatusCreated, "POST", "/matches/", token, map[string]interface{}{
+		"TournamentID":   tournament.ID,
+		"OrganiserID":    userID,
+		"OrganisationID": organisation.ID,
+		"Team1ID":        team1.ID,
+		"Team2ID":        team2.ID,
+	}, &match)
+
+	return match.ID, team1.ID, team2.ID
+}
+
+func TestFinalLiveScoreIsStoredWithItsMapResult(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	matchID, _, team2ID := s.createMatch(userID, token)
+
+	sub := s.hub.Subscribe(realtimemanager.Topic("match", matchID))
+	defer s.hub.Unsubscribe(sub)
+	scoreBroadcasts := func() int {
+		count := 0
+		for {
+			select {
+			case event := <-sub.Events():
+				if event.Action == "live_score_updated" {
+					count++
+				}
+			default:
+				return count
+			}
+		}
+	}
+
+	live := "/matches/" + matchID + "/live"
+	score := func(team1, team2 int, final bool) map[string]interface{} {
+		return map[string]interface{}{"map_number": 1, "mode": "search_and_destroy", "team1_score": team1, "team2_score": team2, "final": final}
+	}
+
+	s.expect(http.StatusOK, "POST", live, token, score(2, 3, false), nil)
+	if got := scoreBroadcasts(); got != 1 {
+		t.Fatalf("live update broadcast %d times, want once", got)
+	}
+
+	var mapResult struct {
+		WinnerID   string `json:"winner_id"`
+		Team2Score int    `json:"team2_score"`
+	}
+	s.expect(http.StatusCreated, "POST", live, token, score(2, 6, true), &mapResult)
+	if mapResult.WinnerID != team2ID || mapResult.Team2Score != 6 {
+		t.Fatalf("got map result %+v, want team 2 winning 6 rounds", mapResult)
+	}
+
+	// A second final score for the map is refused without touching what was stored or telling anyone
+	scoreBroadcasts()
+	s.expect(http.StatusConflict, "POST", live, token, score(6, 0, true), nil)
+	if got := scoreBroadcasts(); got != 0 {
+		t.Fatalf("refused final score broadcast %d times", got)
+	}
+
+	var stored struct {
+		Team1Score int  `json:"team1_score"`
+		Team2Score int  `json:"team2_score"`
+		Final      bool `json:"final"`
+	}
+	s.expect(http.StatusOK, "GET", live, token, nil, &stored)
+	if stored.Team1Score != 2 || stored.Team2Score != 6 || !stored.Final {
+		t.Fatalf("live score after a refused final update is %+v, want the first final score", stored)
+	}
+
+	var mapResults []struct {
+		WinnerID string `json:"winner_id"`
+	}
+	s.expect(http.StatusOK, "GET", "/matches/"+matchID+"/maps", token, nil, &mapResults)
+	if len(mapResults) != 1 || mapResults[0].WinnerID != team2ID {
+		t.Fatalf("got map results %+v, want only the first", mapResults)
+	}
+}
+
+func TestLiveScoresFollowTheirTournamentsVisibility(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	matchID, team1ID, _ := s.createMatch(userID, token)
+	_, otherTeamID, _ := s.createMatch(userID, token)
+
+	live := "/matches/" + matchID + "/live"
+	maps := "/matches/" + matchID + "/maps"
+
+	// Stats can only be for the teams playing
+	stats := func(teamID string) map[string]interface{} {
+		return map[string]interface{}{
+			"map_number": 1, "mode": "search_and_destroy", "team1_score": 1, "team2_score": 0,
+			"player_stats": []map[string]interface{}{{"player": "Shotzzy", "team_id": teamID, "kills": 4}},
+		}
+	}
+	s.expect(http.StatusBadRequest, "POST", live, token, stats(otherTeamID), nil)
+	s.expect(http.StatusOK, "POST", live, token, stats(team1ID), nil)
+
+	// A draft tournament's matches are only followed by its staff
+	s.expect(http.StatusNotFound, "GET", live, "", nil, nil)
+	s.expect(http.StatusNotFound, "GET", maps, "", nil, nil)
+	s.expect(http.StatusOK, "GET", live, token, nil, nil)
+	s.expect(http.StatusOK, "GET", maps, token, nil, nil)
+
+	var match struct {
+		TournamentID string
+	}
+	s.expect(http.StatusOK, "GET", "/matches/"+matchID, token, nil, &match)
+	var tournament document
+	s.expect(http.StatusOK, "GET", "/tournaments/"+match.TournamentID, token, nil, &tournament)
+	s.expect(http.StatusOK, "PATCH", "/tournaments/"+match.TournamentID, token, map[string]interface{}{"Published": true, "Version": tournament.Version}, nil)
+
+	s.expect(http.StatusOK, "GET", live, "", nil, nil)
+	s.expect(http.StatusOK, "GET", maps, "", nil, nil)
+}
@@ -1,20 +1,23 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type MatchHandler struct {}
+type MatchHandler struct {
+	Matches *services.MatchService
+}
 
 // Handlers creation of a new match
 func (h *MatchHandler) CreateMatch(c *gin.Context) {
-	userIDStr, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
@@ -24,155 +27,175 @@ func (h *MatchHandler) CreateMatch(c *gin.Context) {
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
-	newMatch.OrganiserID = userID
-	createdMatch, err := models.CreateMatch(c, &newMatch)
+	createdMatch, err := h.Matches.Create(c, userID, &newMatch)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	// Add the created match to the associated tournament
-	tournamentID := newMatch.TournamentID
-		err = models.AddMatchToTournament(c, createdMatch.ID, tournamentID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
+	setETag(c, createdMatch.Version)
 	c.JSON(http.StatusCreated, createdMatch)
 }
 
-// Handlers getting all matches by organiser id
-func (h *MatchHandler) GetMatchesByOrganiserID(c *gin.Context) {
-	userID, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// Handlers getting the matches in the user's organisations
+func (h *MatchHandler) GetMatchesForUser(c *gin.Context) {
+	userID, organisationID, ok := listParams(c)
+	if !ok {
 		return
 	}
 
-	organiserID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+	query, ok := listQuery(c, models.MatchListSchema)
+	if !ok {
 		return
 	}
 
-	matches, err := models.GetMatchesByOrganiserID(c, organiserID)
+	matches, err := h.Matches.List(c, userID, organisationID, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, matches)
-} 
+}
 
 // handles the retrieval of a match
 func (h *MatchHandler) GetMatchByID(c *gin.Context) {
-	matchID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(matchID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
 		return
 	}
 
-	match, err := models.GetMatchByID(c, id)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	match, err := h.Matches.Get(c, userID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	setETag(c, match.Version)
 	c.JSON(http.StatusOK, match)
 }
 
 // Handles the updating of an existing match.
 func (h *MatchHandler) UpdateMatch(c *gin.Context) {
-	matchID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(matchID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
 		return
 	}
 
 	var updatedMatch models.Match
-
 	if err := c.ShouldBindJSON(&updatedMatch); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userIDStr, err := models.GetUserIDFromContext(c)
+	// An If-Match header takes precedence over a version in the body
+	version, ok := updateVersion(c, updatedMatch.Version)
+	if !ok {
+		return
+	}
+	updatedMatch.Version = version
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Matches.Update(c, userID, id, &updatedMatch); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setETag(c, updatedMatch.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Match updated successfully"})
+}
+
+// Handles a partial update of a match, sent as a JSON merge patch
+func (h *MatchHandler) PatchMatch(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	patch, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := updateVersion(c, patchVersion(patch))
+	if !ok {
 		return
 	}
 
-	if userID != updatedMatch.OrganiserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the organiser of this match"})
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
 	}
 
-	err = models.UpdateMatch(c, id, &updatedMatch)
+	patchedMatch, err := h.Matches.Patch(c, userID, id, version, func(match *models.Match) error {
+		return mergePatch(patch, match)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Match updated successfully"})
+	setETag(c, patchedMatch.Version)
+	c.JSON(http.StatusOK, patchedMatch)
 }
 
 // Handles the deletion of a match by its ID.
 func (h *MatchHandler) DeleteMatch(c *gin.Context) {
-	matchID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(matchID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
 		return
 	}
 
-	userIDStr, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+
+	if err := h.Matches.Delete(c, userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	match, err := models.GetMatchByID(c, id)
+	c.JSON(http.StatusOK, gin.H{"message": "Match deleted successfully"})
+}
+
+// Handles restoring a deleted match along with everything deleted with it
+func (h *MatchHandler) RestoreMatch(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
 		return
 	}
 
-	if userID != match.OrganiserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the organizer of this tournament"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	err = models.DeleteMatch(c, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.Matches.Restore(c, userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Match deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Match restored successfully"})
 }
 
-func NewMatchHandler() *MatchHandler {
-	return &MatchHandler{}
-}
\ No newline at end of file
+func NewMatchHandler(matches *services.MatchService) *MatchHandler {
+	return &MatchHandler{
+		Matches: matches,
+	}
+}
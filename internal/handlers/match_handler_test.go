@@ -0,0 +1,59 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClearingAMatchDateUnschedulesIt(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	matchID, _, _ := s.createMatch(userID, token)
+
+	item := "/matches/" + matchID
+	s.expect(http.StatusOK, "PATCH", item, token, map[string]interface{}{"Date": "2026-03-02T18:00:00Z", "Version": 1}, nil)
+
+	unscheduled := func() int {
+		var page struct {
+			Items []document `json:"items"`
+		}
+		s.expect(http.StatusOK, "GET", "/me/matches?status=unscheduled", token, nil, &page)
+		return len(page.Items)
+	}
+	if got := unscheduled(); got != 0 {
+		t.Fatalf("scheduled match listed as unscheduled")
+	}
+
+	s.expect(http.StatusOK, "PATCH", item, token, map[string]interface{}{"Date": nil, "Version": 2}, nil)
+
+	var match struct {
+		Date time.Time
+	}
+	s.expect(http.StatusOK, "GET", item, token, nil, &match)
+	if !match.Date.IsZero() {
+		t.Fatalf("match still scheduled for %v after clearing its date", match.Date)
+	}
+	if got := unscheduled(); got != 1 {
+		t.Fatalf("got %d unscheduled matches, want the cleared one", got)
+	}
+}
+
+func TestTournamentDatesCannotBeCleared(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+
+	var organisation, tournament document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+	s.expect(http.StatusCreated, "POST", "/tournaments/", token, map[string]interface{}{
+		"Name":           "Major",
+		"StartDate":      "2026-03-01T00:00:00Z",
+		"EndDate":        "2026-03-05T00:00:00Z",
+		"OrganiserID":    userID,
+		"OrganisationID": organisation.ID,
+	}, &tournament)
+
+	for _, field := range []string{"StartDate", "EndDate"} {
+		s.expect(http.StatusBadRequest, "PATCH", "/tournaments/"+tournament.ID, token, map[string]interface{}{field: nil, "Version": 1}, nil)
+	}
+}
@@ -1,63 +1,57 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type MatchResultHandler struct {}
+type MatchResultHandler struct {
+	MatchResults *services.MatchResultService
+}
 
 // Handles the creation of a new match result.
 func (h *MatchResultHandler) CreateMatchResult(c *gin.Context) {
-	userIDStr, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
 	var newMatchResult models.MatchResult
-
 	if err := c.ShouldBindJSON(&newMatchResult); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
-	newMatchResult.OrganiserID = userID
-
-	createdMatchResult, err := models.CreateMatchResult(c, &newMatchResult)
+	createdMatchResult, err := h.MatchResults.Create(c, userID, &newMatchResult)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	setETag(c, createdMatchResult.Version)
 	c.JSON(http.StatusCreated, createdMatchResult)
 }
 
-// Handles getting match results by organiser id
-func (h *MatchResultHandler) GetMatchResultsByOrganiserID(c *gin.Context) {
-	userID, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// Handles getting the match results in the user's organisations
+func (h *MatchResultHandler) GetMatchResultsForUser(c *gin.Context) {
+	userID, organisationID, ok := listParams(c)
+	if !ok {
 		return
 	}
 
-	organiserID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+	query, ok := listQuery(c, models.MatchResultListSchema)
+	if !ok {
 		return
 	}
 
-	matchResults, err := models.GetMatchResultsByOrganiserID(c, organiserID)
+	matchResults, err := h.MatchResults.List(c, userID, organisationID, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -66,107 +60,142 @@ func (h *MatchResultHandler) GetMatchResultsByOrganiserID(c *gin.Context) {
 
 // Handles the retrieval of a match result by its ID.
 func (h *MatchResultHandler) GetMatchResultById(c *gin.Context) {
-	matchResultID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(matchResultID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match Result ID format"})
 		return
 	}
 
-	matchResult, err := models.GetMatchResultByID(c, id)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	matchResult, err := h.MatchResults.Get(c, userID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	setETag(c, matchResult.Version)
 	c.JSON(http.StatusOK, matchResult)
 }
 
 // Handles the updating of an existing match result.
 func (h *MatchResultHandler) UpdateMatchResult(c *gin.Context) {
-	matchResultID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(matchResultID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match Result ID format"})
 		return
 	}
 
 	var updatedMatchResult models.MatchResult
-
 	if err := c.ShouldBindJSON(&updatedMatchResult); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userIDStr, err := models.GetUserIDFromContext(c)
+	// An If-Match header takes precedence over a version in the body
+	version, ok := updateVersion(c, updatedMatchResult.Version)
+	if !ok {
+		return
+	}
+	updatedMatchResult.Version = version
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.MatchResults.Update(c, userID, id, &updatedMatchResult); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setETag(c, updatedMatchResult.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Match Result updated successfully"})
+}
+
+// Handles a partial update of a match result, sent as a JSON merge patch
+func (h *MatchResultHandler) PatchMatchResult(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match Result ID format"})
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	patch, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := updateVersion(c, patchVersion(patch))
+	if !ok {
 		return
 	}
 
-	if userID != updatedMatchResult.OrganiserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the organizer of this team"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	err = models.UpdateMatchResult(c, id, &updatedMatchResult)
+	patchedMatchResult, err := h.MatchResults.Patch(c, userID, id, version, func(matchResult *models.MatchResult) error {
+		return mergePatch(patch, matchResult)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Match Result updated successfully"})
+	setETag(c, patchedMatchResult.Version)
+	c.JSON(http.StatusOK, patchedMatchResult)
 }
 
 // Handles the deletion of a match result by its ID.
 func (h *MatchResultHandler) DeleteMatchResult(c *gin.Context) {
-	matchResultID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(matchResultID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match Result ID format"})
 		return
 	}
 
-	userIDStr, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+
+	if err := h.MatchResults.Delete(c, userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	matchResult, err := models.GetMatchResultByID(c, id)
+	c.JSON(http.StatusOK, gin.H{"message": "Match Result deleted successfully"})
+}
+
+// Handles restoring a deleted match result
+func (h *MatchResultHandler) RestoreMatchResult(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match Result ID format"})
 		return
 	}
 
-	if userID != matchResult.OrganiserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the organizer of this tournament"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	err = models.DeleteMatchResult(c, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.MatchResults.Restore(c, userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Match Result deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Match Result restored successfully"})
 }
 
-func NewMatchResultHandler() *MatchResultHandler {
-	return &MatchResultHandler{}
-}
\ No newline at end of file
+func NewMatchResultHandler(matchResults *services.MatchResultService) *MatchResultHandler {
+	return &MatchResultHandler{
+		MatchResults: matchResults,
+	}
+}
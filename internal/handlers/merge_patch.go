@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+var errMergePatchNotObject = errors.New("The patch must be a JSON object")
+
+// Applies a JSON merge patch (RFC 7396) to a document. Fields in the patch replace the
+// document's, null clears them, and anything not in the patch is left alone. Fields the
+// document doesn't have are refused, and the result must still pass its binding rules.
+func mergePatch(patch []byte, document interface{}) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil || fields == nil {
+		return errMergePatchNotObject
+	}
+
+	// Clear the patched fields first so arrays are replaced rather than decoded over
+	for name := range fields {
+		if err := clearField(document, name); err != nil {
+			return err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patch))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(document); err != nil {
+		return err
+	}
+
+	return binding.Validator.ValidateStruct(document)
+}
+
+// Sets the field encoding/json would decode name into back to its zero value
+func clearField(document interface{}, name string) error {
+	if !clearStructField(reflect.ValueOf(document).Elem(), name) {
+		return fmt.Errorf("Unknown field %q", name)
+	}
+	return nil
+}
+
+// Clears a field by its JSON name, looking inside embedded structs like encoding/json does
+func clearStructField(value reflect.Value, name string) bool {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			if clearStructField(value.Field(i), name) {
+				return true
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+
+		if strings.EqualFold(jsonName, name) {
+			value.Field(i).SetZero()
+			return true
+		}
+	}
+
+	return false
+}
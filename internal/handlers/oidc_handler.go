@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cookie the browser keeps a login's nonce in until the provider sends it back
+const oidcNonceCookie = "oidcNonce"
+
+type OIDCHandler struct {
+	Providers map[string]*auth.OIDCProvider
+	Auth      *services.AuthService
+	Users     *services.UserService
+}
+
+// Handles listing the login providers users can log in with
+func (h *OIDCHandler) GetProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.Providers))
+	for name := range h.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+// Handles starting a login by sending the user to the provider
+func (h *OIDCHandler) Login(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+
+	authURL, nonce, err := h.Auth.StartOIDCLogin(c, provider, nil)
+	if err != nil {
+		log.Printf("Error starting %s login: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not reach the login provider"})
+		return
+	}
+
+	setOIDCNonceCookie(c, nonce, int(models.OIDCLoginStateLifetime.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Handles starting to link a provider to the signed in user's account
+func (h *OIDCHandler) Link(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	authURL, nonce, err := h.Auth.StartOIDCLogin(c, provider, &userID)
+	if err != nil {
+		log.Printf("Error starting %s login: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not reach the login provider"})
+		return
+	}
+
+	setOIDCNonceCookie(c, nonce, int(models.OIDCLoginStateLifetime.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Handles the provider sending the user back, logging them in or finishing linking their
+// account. Only works in the browser that started the login, and linking only while the
+// same user is still signed in.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+
+	// The nonce is only good for one callback
+	nonce, _ := c.Cookie(oidcNonceCookie)
+	setOIDCNonceCookie(c, "", -1)
+
+	var currentUserID primitive.ObjectID
+	if userIDStr, err := getUserIDFromContext(c); err == nil {
+		currentUserID, _ = primitive.ObjectIDFromHex(userIDStr)
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login was cancelled or refused: " + providerError})
+		return
+	}
+
+	result, err := h.Auth.FinishOIDCLogin(c, provider, c.Query("code"), c.Query("state"), nonce, currentUserID, clientInfo(c))
+	if err != nil {
+		if services.KindOf(err) == services.KindInternal {
+			log.Printf("Error finishing %s login: %v", provider.Name, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Could not log in with the provider"})
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	// Linking an account doesn't start a session
+	if result.AuthTokens == nil && !result.TwoFactorRequired {
+		c.JSON(http.StatusOK, gin.H{"message": "Account linked successfully"})
+		return
+	}
+
+	if result.AuthTokens != nil {
+		setAuthCookies(c, result.AuthTokens)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Handles unlinking a provider from the signed in user's account
+func (h *OIDCHandler) Unlink(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Users.UnlinkIdentity(c, userID, c.Param("provider")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Provider unlinked successfully"})
+}
+
+// Sets or, with a negative maxAge, clears the nonce cookie. It is sent on the provider's
+// redirect back, which is a top level navigation, but not on requests other sites make.
+func setOIDCNonceCookie(c *gin.Context, nonce string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcNonceCookie,
+		Value:    nonce,
+		Path:     "/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Gets the provider named in the route, writing the error response and returning false if it isn't configured
+func (h *OIDCHandler) provider(c *gin.Context) (*auth.OIDCProvider, bool) {
+	provider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": auth.ErrOIDCProviderNotFound.Error()})
+		return nil, false
+	}
+	return provider, true
+}
+
+func NewOIDCHandler(providers map[string]*auth.OIDCProvider, authService *services.AuthService, users *services.UserService) *OIDCHandler {
+	return &OIDCHandler{
+		Providers: providers,
+		Auth:      authService,
+		Users:     users,
+	}
+}
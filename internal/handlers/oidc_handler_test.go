@@ -0,0 +1,295 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/routes"
+)
+
+const mockClientID = "tournament-manager"
+
+// An OpenID Connect provider that signs in whoever the test says, checking PKCE like a real one
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+// Who an authorization code signs in, and what the login asked for
+type mockGrant struct {
+	subject, email, nonce, codeChallenge string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	idp := &mockIdP{t: t, key: key, grants: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) provider() *auth.OIDCProvider {
+	return &auth.OIDCProvider{
+		Name:        "mock",
+		IssuerURL:   idp.server.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://localhost/auth/oidc/mock/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+}
+
+// Signs the user in at the authorization URL the API redirected to, returning the
+// callback the provider would send the browser back to
+func (idp *mockIdP) authorize(location, subject, email string) string {
+	idp.t.Helper()
+
+	authURL, err := url.Parse(location)
+	if err != nil || authURL.Path != "/authorize" {
+		idp.t.Fatalf("redirected to %q, want the provider's authorization endpoint", location)
+	}
+	query := authURL.Query()
+
+	code, err := auth.GenerateOIDCRandom()
+	if err != nil {
+		idp.t.Fatalf("generating code: %v", err)
+	}
+	idp.mu.Lock()
+	idp.grants[code] = mockGrant{subject: subject, email: email, nonce: query.Get("nonce"), codeChallenge: query.Get("code_challenge")}
+	idp.mu.Unlock()
+
+	return "/auth/oidc/mock/callback?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	grant, ok := idp.grants[r.FormValue("code")]
+	delete(idp.grants, r.FormValue("code"))
+	idp.mu.Unlock()
+
+	if !ok || r.FormValue("client_id") != mockClientID || auth.PKCEChallenge(r.FormValue("code_verifier")) != grant.codeChallenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            mockClientID,
+		"sub":            grant.subject,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.email,
+		"email_verified": true,
+	})
+	idToken.Header["kid"] = "test"
+
+	signed, err := idToken.SignedString(idp.key)
+	if err != nil {
+		idp.t.Errorf("signing ID token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+// Adds the OIDC routes, logging in with the mock provider
+func (s *testServer) withOIDC(idp *mockIdP) {
+	providers := map[string]*auth.OIDCProvider{"mock": idp.provider()}
+	routes.SetupOIDCRoutes(s.router, handlers.NewOIDCHandler(providers, s.svc.Auth, s.svc.Users))
+}
+
+// A browser: keeps the cookies the API sets and sends them back
+type browser struct {
+	s       *testServer
+	cookies map[string]*http.Cookie
+}
+
+func (s *testServer) newBrowser() *browser {
+	return &browser{s: s, cookies: make(map[string]*http.Cookie)}
+}
+
+func (b *browser) get(path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for _, cookie := range b.cookies {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	b.s.router.ServeHTTP(rec, req)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(b.cookies, cookie.Name)
+		} else {
+			b.cookies[cookie.Name] = cookie
+		}
+	}
+	return rec
+}
+
+// Starts a login, or linking when the browser is signed in, and returns where the API redirected to
+func (b *browser) start(path string) string {
+	b.s.t.Helper()
+
+	rec := b.get(path)
+	if rec.Code != http.StatusFound {
+		b.s.t.Fatalf("GET %s: got status %d, want %d (%s)", path, rec.Code, http.StatusFound, rec.Body.String())
+	}
+
+	nonce, ok := b.cookies["oidcNonce"]
+	if !ok || !nonce.HttpOnly || nonce.SameSite != http.SameSiteLaxMode || nonce.MaxAge <= 0 {
+		b.s.t.Fatalf("GET %s: got nonce cookie %+v, want a short lived HttpOnly SameSite=Lax cookie", path, nonce)
+	}
+	return rec.Header().Get("Location")
+}
+
+// Signs the browser in as a user, like the cookie /users/login sets
+func (b *browser) signIn(token string) {
+	b.cookies["jwtToken"] = &http.Cookie{Name: "jwtToken", Value: token}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	s := newTestServer(t)
+	idp := newMockIdP(t)
+	s.withOIDC(idp)
+
+	browser := s.newBrowser()
+	callback := idp.authorize(browser.start("/auth/oidc/mock/login"), "player-1", "player@example.com")
+
+	rec := browser.get(callback)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback: got status %d, want %d (%s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &login); err != nil || login.Token == "" {
+		t.Fatalf("callback returned no token: %s", rec.Body.String())
+	}
+	if _, ok := browser.cookies["oidcNonce"]; ok {
+		t.Fatalf("nonce cookie kept after the callback")
+	}
+
+	user, err := s.repos.Users.GetByIdentity(context.Background(), "mock", "player-1")
+	if err != nil || user.Email != "player@example.com" {
+		t.Fatalf("no user for the provider's account: %v", err)
+	}
+
+	// The callback can't be replayed
+	if rec := browser.get(callback); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed callback: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCCallbackNeedsTheBrowserThatStartedTheLogin(t *testing.T) {
+	s := newTestServer(t)
+	idp := newMockIdP(t)
+	s.withOIDC(idp)
+
+	// An attacker starts a login with their own account and sends the callback to a victim
+	attacker := s.newBrowser()
+	attackerCallback := idp.authorize(attacker.start("/auth/oidc/mock/login"), "attacker", "attacker@example.com")
+
+	victim := s.newBrowser()
+	if rec := victim.get(attackerCallback); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("callback without a nonce cookie: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// Even when the victim has a login of their own in progress
+	attacker = s.newBrowser()
+	attackerCallback = idp.authorize(attacker.start("/auth/oidc/mock/login"), "attacker", "attacker@example.com")
+	victim.start("/auth/oidc/mock/login")
+	if rec := victim.get(attackerCallback); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("callback with another login's nonce: got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if _, err := s.repos.Users.GetByIdentity(context.Background(), "mock", "attacker"); err == nil {
+		t.Fatalf("refused callbacks created a user")
+	}
+}
+
+func TestOIDCLinkNeedsTheSameUser(t *testing.T) {
+	s := newTestServer(t)
+	idp := newMockIdP(t)
+	s.withOIDC(idp)
+
+	userID, token := s.signUp("linker@example.com")
+	_, otherToken := s.signUp("other@example.com")
+
+	// Linking is refused if nobody, or somebody else, is signed in to the callback
+	for name, callbackToken := range map[string]string{"signed out": "", "another user": otherToken} {
+		browser := s.newBrowser()
+		browser.signIn(token)
+		callback := idp.authorize(browser.start("/auth/oidc/mock/link"), "linked-account", "")
+
+		delete(browser.cookies, "jwtToken")
+		if callbackToken != "" {
+			browser.signIn(callbackToken)
+		}
+		if rec := browser.get(callback); rec.Code != http.StatusForbidden {
+			t.Fatalf("linking callback %s: got status %d, want %d (%s)", name, rec.Code, http.StatusForbidden, rec.Body.String())
+		}
+	}
+	if _, err := s.repos.Users.GetByIdentity(context.Background(), "mock", "linked-account"); err == nil {
+		t.Fatalf("refused callbacks linked the account")
+	}
+
+	browser := s.newBrowser()
+	browser.signIn(token)
+	callback := idp.authorize(browser.start("/auth/oidc/mock/link"), "linked-account", "")
+	if rec := browser.get(callback); rec.Code != http.StatusOK {
+		t.Fatalf("linking callback: got status %d, want %d (%s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	user, err := s.repos.Users.GetByIdentity(context.Background(), "mock", "linked-account")
+	if err != nil || user.ID.Hex() != userID {
+		t.Fatalf("account linked to %v (%v), want %s", user, err, userID)
+	}
+}
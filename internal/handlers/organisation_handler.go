@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OrganisationHandler struct {
+	Organisations *services.OrganisationService
+}
+
+// Handles creating an organisation, with the current user as its owner
+func (h *OrganisationHandler) CreateOrganisation(c *gin.Context) {
+	var newOrganisation struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&newOrganisation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	organisation, err := h.Organisations.Create(c, userID, newOrganisation.Name)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, organisation)
+}
+
+// Handles listing the organisations the current user is a member of
+func (h *OrganisationHandler) GetOrganisations(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	organisations, err := h.Organisations.List(c, userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, organisations)
+}
+
+// Handles getting an organisation the current user is a member of
+func (h *OrganisationHandler) GetOrganisationByID(c *gin.Context) {
+	organisationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Organisation ID format"})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	organisation, err := h.Organisations.Get(c, userID, organisationID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, organisation)
+}
+
+// Handles adding a member to an organisation
+func (h *OrganisationHandler) AddMember(c *gin.Context) {
+	organisationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Organisation ID format"})
+		return
+	}
+
+	var newMember struct {
+		UserID primitive.ObjectID `json:"user_id" binding:"required"`
+		Role   string             `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&newMember); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Organisations.AddMember(c, organisationID, userID, newMember.UserID, newMember.Role); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Member added successfully"})
+}
+
+// Handles changing a member's role in an organisation
+func (h *OrganisationHandler) UpdateMember(c *gin.Context) {
+	organisationID, memberID, ok := organisationMemberParams(c)
+	if !ok {
+		return
+	}
+
+	var updatedMember struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&updatedMember); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Organisations.UpdateMember(c, organisationID, userID, memberID, updatedMember.Role); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// Handles removing a member from an organisation
+func (h *OrganisationHandler) RemoveMember(c *gin.Context) {
+	organisationID, memberID, ok := organisationMemberParams(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Organisations.RemoveMember(c, organisationID, userID, memberID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func organisationMemberParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	organisationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Organisation ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	return organisationID, memberID, true
+}
+
+// Reads the optional organisation_id query parameter used to narrow listings to one organisation
+func organisationQuery(c *gin.Context) (primitive.ObjectID, bool) {
+	organisationIDStr := c.Query("organisation_id")
+	if organisationIDStr == "" {
+		return primitive.NilObjectID, true
+	}
+
+	organisationID, err := primitive.ObjectIDFromHex(organisationIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Organisation ID format"})
+		return primitive.NilObjectID, false
+	}
+	return organisationID, true
+}
+
+// Gets the signed in user and the organisation to narrow a listing to, writing the
+// error response and returning false on failure
+func listParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	organisationID, ok := organisationQuery(c)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return userID, organisationID, true
+}
+
+func NewOrganisationHandler(organisations *services.OrganisationService) *OrganisationHandler {
+	return &OrganisationHandler{
+		Organisations: organisations,
+	}
+}
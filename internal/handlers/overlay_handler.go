@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OverlayHandler struct {
+	Overlays *services.OverlayService
+}
+
+// Handles getting the broadcast overlay snapshot for a match
+func (h *OverlayHandler) GetOverlay(c *gin.Context) {
+	matchID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
+		return
+	}
+
+	snapshot, err := h.Overlays.Get(c, matchID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+func NewOverlayHandler(overlays *services.OverlayService) *OverlayHandler {
+	return &OverlayHandler{
+		Overlays: overlays,
+	}
+}
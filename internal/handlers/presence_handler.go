@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PresenceHandler struct {
+	WebSocketHub *realtimemanager.WebSocketHub
+	Visibility   *services.VisibilityService
+}
+
+// How many are watching, which is all anyone who can see the match or tournament is told
+type presenceCount struct {
+	Topic string `json:"topic"`
+	Count int    `json:"count"`
+}
+
+// Handles getting how many are watching a match
+func (h *PresenceHandler) GetMatchPresence(c *gin.Context) {
+	id, ok := presenceID(c, "Invalid Match ID format")
+	if !ok {
+		return
+	}
+	if _, err := h.Visibility.Match(c, optionalUserID(c), id); err != nil {
+		respondError(c, err)
+		return
+	}
+	h.respondCount(c, realtimemanager.Topic("match", id.Hex()))
+}
+
+// Handles getting how many are watching a tournament
+func (h *PresenceHandler) GetTournamentPresence(c *gin.Context) {
+	id, ok := presenceID(c, "Invalid Tournament ID format")
+	if !ok {
+		return
+	}
+	if _, err := h.Visibility.Tournament(c, optionalUserID(c), id); err != nil {
+		respondError(c, err)
+		return
+	}
+	h.respondCount(c, realtimemanager.Topic("tournament", id.Hex()))
+}
+
+// Handles getting who is watching a match, for the staff of its tournament
+func (h *PresenceHandler) GetMatchViewers(c *gin.Context) {
+	id, ok := presenceID(c, "Invalid Match ID format")
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Visibility.StaffMatch(c, userID, id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.WebSocketHub.Presence(realtimemanager.Topic("match", id.Hex())))
+}
+
+// Handles getting who is watching a tournament, for its staff
+func (h *PresenceHandler) GetTournamentViewers(c *gin.Context) {
+	id, ok := presenceID(c, "Invalid Tournament ID format")
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Visibility.StaffTournament(c, userID, id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.WebSocketHub.Presence(realtimemanager.Topic("tournament", id.Hex())))
+}
+
+func presenceID(c *gin.Context, invalidIDMessage string) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidIDMessage})
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+func (h *PresenceHandler) respondCount(c *gin.Context, topic string) {
+	presence := h.WebSocketHub.Presence(topic)
+	c.JSON(http.StatusOK, presenceCount{Topic: presence.Topic, Count: presence.Count})
+}
+
+// Works out who is behind a realtime connection, anonymous unless the request was authenticated
+func presenceIdentity(c *gin.Context, users *services.UserService) realtimemanager.Identity {
+	userIDStr, err := getUserIDFromContext(c)
+	if err != nil {
+		return realtimemanager.Identity{}
+	}
+
+	identity := realtimemanager.Identity{UserID: userIDStr}
+	if userID, err := primitive.ObjectIDFromHex(userIDStr); err == nil {
+		if user, err := users.Get(c, userID); err == nil {
+			identity.Username = user.Username
+		}
+	}
+
+	return identity
+}
+
+func NewPresenceHandler(webSocketHub *realtimemanager.WebSocketHub, visibility *services.VisibilityService) *PresenceHandler {
+	return &PresenceHandler{
+		WebSocketHub: webSocketHub,
+		Visibility:   visibility,
+	}
+}
@@ -0,0 +1,57 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+)
+
+func TestPresenceOnlyNamesViewersToStaff(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	_, otherToken := s.signUp("spectator@example.com")
+	matchID, _, _ := s.createMatch(userID, token)
+
+	var match struct {
+		TournamentID string
+	}
+	s.expect(http.StatusOK, "GET", "/matches/"+matchID, token, nil, &match)
+
+	sub := s.hub.SubscribeAs(realtimemanager.Identity{UserID: userID, Username: "organiser"}, realtimemanager.Topic("tournament", match.TournamentID))
+	defer s.hub.Unsubscribe(sub)
+
+	count := "/tournaments/" + match.TournamentID + "/presence"
+	users := count + "/users"
+
+	// Drafts aren't given away to anyone outside their staff
+	s.expect(http.StatusNotFound, "GET", count, "", nil, nil)
+	s.expect(http.StatusNotFound, "GET", count, otherToken, nil, nil)
+	s.expect(http.StatusNotFound, "GET", "/matches/"+matchID+"/presence", "", nil, nil)
+	s.expect(http.StatusNotFound, "GET", users, otherToken, nil, nil)
+	s.expect(http.StatusOK, "GET", count, token, nil, nil)
+
+	var tournament document
+	s.expect(http.StatusOK, "GET", "/tournaments/"+match.TournamentID, token, nil, &tournament)
+	s.expect(http.StatusOK, "PATCH", "/tournaments/"+match.TournamentID, token, map[string]interface{}{"Published": true, "Version": tournament.Version}, nil)
+
+	// Once published anyone sees how many are watching, but not who
+	var public map[string]interface{}
+	s.expect(http.StatusOK, "GET", count, "", nil, &public)
+	if public["count"] != float64(1) {
+		t.Fatalf("got public presence %v, want a count of 1", public)
+	}
+	if _, ok := public["users"]; ok {
+		t.Fatalf("public presence names its viewers: %v", public)
+	}
+	s.expect(http.StatusOK, "GET", "/matches/"+matchID+"/presence", "", nil, nil)
+
+	s.expect(http.StatusUnauthorized, "GET", users, "", nil, nil)
+	s.expect(http.StatusForbidden, "GET", users, otherToken, nil, nil)
+
+	var presence realtimemanager.Presence
+	s.expect(http.StatusOK, "GET", users, token, nil, &presence)
+	if len(presence.Users) != 1 || presence.Users[0].UserID != userID {
+		t.Fatalf("got staff presence %+v, want the organiser", presence)
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Serves the read only spectator API. Nothing here needs a signed in user
+type PublicHandler struct {
+	Public *services.PublicService
+}
+
+// Handles listing the published tournaments
+func (h *PublicHandler) GetTournaments(c *gin.Context) {
+	query, ok := listQuery(c, models.PublicTournamentListSchema)
+	if !ok {
+		return
+	}
+
+	tournaments, err := h.Public.ListTournaments(c, query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tournaments)
+}
+
+// Handles getting a published tournament and its teams
+func (h *PublicHandler) GetTournament(c *gin.Context) {
+	id, ok := publicTournamentID(c)
+	if !ok {
+		return
+	}
+
+	tournament, err := h.Public.GetTournament(c, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tournament)
+}
+
+// Handles getting a published tournament's bracket
+func (h *PublicHandler) GetBracket(c *gin.Context) {
+	id, ok := publicTournamentID(c)
+	if !ok {
+		return
+	}
+
+	rounds, err := h.Public.GetBracket(c, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tournament_id": id, "rounds": rounds})
+}
+
+// Handles getting a published tournament's standings
+func (h *PublicHandler) GetStandings(c *gin.Context) {
+	id, ok := publicTournamentID(c)
+	if !ok {
+		return
+	}
+
+	standings, err := h.Public.GetStandings(c, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
+
+// Handles getting a published tournament's schedule
+func (h *PublicHandler) GetSchedule(c *gin.Context) {
+	id, ok := publicTournamentID(c)
+	if !ok {
+		return
+	}
+
+	matches, err := h.Public.GetSchedule(c, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
+
+// Handles getting a match from a published tournament
+func (h *PublicHandler) GetMatch(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Match ID format"})
+		return
+	}
+
+	match, err := h.Public.GetMatch(c, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, match)
+}
+
+// Reads the tournament ID from the path, writing the error response if it is invalid
+func publicTournamentID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Tournament ID format"})
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+func NewPublicHandler(public *services.PublicService) *PublicHandler {
+	return &PublicHandler{
+		Public: public,
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+)
+
+type SearchHandler struct {
+	Search *services.SearchService
+}
+
+// Handles searching the tournaments, teams and players in the user's organisations
+func (h *SearchHandler) SearchAll(c *gin.Context) {
+	userID, organisationID, ok := listParams(c)
+	if !ok {
+		return
+	}
+
+	values := c.Request.URL.Query()
+	values.Del("organisation_id")
+
+	query, err := models.ParseSearchQuery(values)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := h.Search.Search(c, userID, organisationID, query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func NewSearchHandler(search *services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		Search: search,
+	}
+}
@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type searchResults struct {
+	Tournaments []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"tournaments"`
+	Teams []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"teams"`
+	Players []struct {
+		Handle string `json:"handle"`
+	} `json:"players"`
+}
+
+func (s *testServer) search(token, text string) searchResults {
+	s.t.Helper()
+
+	var results searchResults
+	s.expect(http.StatusOK, "GET", "/search?q="+url.QueryEscape(text), token, nil, &results)
+	return results
+}
+
+func TestSearchFindsTyposInOrderWithinTheUsersOrganisations(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	otherID, otherToken := s.signUp("rival@example.com")
+
+	var organisation, otherOrganisation document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+	s.expect(http.StatusCreated, "POST", "/organisations/", otherToken, map[string]string{"name": "Rivals"}, &otherOrganisation)
+
+	tournament := func(organiserID, organisationID, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"Name":           name,
+			"StartDate":      "2026-03-01T00:00:00Z",
+			"EndDate":        "2026-03-05T00:00:00Z",
+			"OrganiserID":    organiserID,
+			"OrganisationID": organisationID,
+		}
+	}
+	s.expect(http.StatusCreated, "POST", "/tournaments/", token, tournament(userID, organisation.ID, "Champs Qualifier"), nil)
+	s.expect(http.StatusCreated, "POST", "/tournaments/", otherToken, tournament(otherID, otherOrganisation.ID, "Champs Invitational"), nil)
+
+	team := func(name string, players ...string) document {
+		var created document
+		s.expect(http.StatusCreated, "POST", "/teams/", token, map[string]interface{}{
+			"Name":           name,
+			"OrganiserID":    userID,
+			"OrganisationID": organisation.ID,
+			"Players":        players,
+		}, &created)
+		return created
+	}
+	team("OpTic Texas", "Shotzzy", "Dashy")
+	first, second := team("Academy"), team("Academy")
+	deleted := team("Academy Reserves")
+	s.expect(http.StatusOK, "DELETE", "/teams/"+deleted.ID, token, nil, nil)
+
+	// Typos in the first letters still find the team and the player
+	if results := s.search(token, "iptic"); len(results.Teams) != 1 || results.Teams[0].Name != "OpTic Texas" {
+		t.Fatalf("searching iptic found teams %+v, want OpTic Texas", results.Teams)
+	}
+	if results := s.search(token, "whotzzy"); len(results.Players) != 1 || results.Players[0].Handle != "Shotzzy" {
+		t.Fatalf("searching whotzzy found players %+v, want Shotzzy", results.Players)
+	}
+
+	// Teams with the same name come back in the same order every time, without the deleted one
+	results := s.search(token, "academy")
+	var ids []string
+	for _, hit := range results.Teams {
+		ids = append(ids, hit.ID)
+	}
+	want := []string{first.ID, second.ID}
+	if second.ID < first.ID {
+		want = []string{second.ID, first.ID}
+	}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("searching academy found teams %v, want %v", ids, want)
+	}
+	for i := 0; i < 10; i++ {
+		if again := s.search(token, "academy"); !reflect.DeepEqual(again, results) {
+			t.Fatalf("searching again got %+v, want %+v", again, results)
+		}
+	}
+
+	// Another organisation's draft isn't found
+	results = s.search(token, "champs")
+	if len(results.Tournaments) != 1 || results.Tournaments[0].Name != "Champs Qualifier" {
+		t.Fatalf("searching champs found tournaments %+v, want only the user's own", results.Tournaments)
+	}
+}
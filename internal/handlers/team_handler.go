@@ -1,70 +1,57 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type TeamHandler struct{}
+type TeamHandler struct {
+	Teams *services.TeamService
+}
 
 // Handler for create team
 func (h *TeamHandler) CreateTeam(c *gin.Context) {
-	userIDStr, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
 	var newTeam models.Team
-
 	if err := c.ShouldBindJSON(&newTeam); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	createdTeam, err := h.Teams.Create(c, userID, &newTeam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
-	newTeam.OrganiserID = userID
-	tournamentID := newTeam.TournamentID
-
-	createdTeam, err := models.CreateTeam(c, &newTeam)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := models.AddTeamToTournament(c, tournamentID, createdTeam.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	setETag(c, createdTeam.Version)
 	c.JSON(http.StatusCreated, createdTeam)
 }
 
-// Handler to get teams by organiser ID
-func(h* TeamHandler) GetTeamsByOrganiserID( c *gin.Context) {
-	userID, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// Handler to get the teams in the user's organisations
+func (h *TeamHandler) GetTeamsForUser(c *gin.Context) {
+	userID, organisationID, ok := listParams(c)
+	if !ok {
 		return
 	}
 
-	organiserID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+	query, ok := listQuery(c, models.TeamListSchema)
+	if !ok {
 		return
 	}
 
-	teams, err := models.GetTeamsByOrganiserID(c, organiserID)
+	teams, err := h.Teams.List(c, userID, organisationID, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -73,105 +60,142 @@ func(h* TeamHandler) GetTeamsByOrganiserID( c *gin.Context) {
 
 // Handler to for getting a team by ID
 func (h *TeamHandler) GetTeamByID(c *gin.Context) {
-	teamID := c.Param("id")
-
-	objectID, err := primitive.ObjectIDFromHex(teamID)
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID format"})
 		return
 	}
 
-	team, err := models.GetTeamByID(c, objectID)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	team, err := h.Teams.Get(c, userID, objectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	setETag(c, team.Version)
 	c.JSON(http.StatusOK, team)
 }
 
 // Handler for updating a team
 func (h *TeamHandler) UpdateTeam(c *gin.Context) {
-	teamID := c.Param("id")
-
-	objectID, err := primitive.ObjectIDFromHex(teamID)
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID format"})
 		return
 	}
 
 	var updatedTeam models.Team
-
 	if err := c.ShouldBindJSON(&updatedTeam); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userIDStr, err := models.GetUserIDFromContext(c)
+	// An If-Match header takes precedence over a version in the body
+	version, ok := updateVersion(c, updatedTeam.Version)
+	if !ok {
+		return
+	}
+	updatedTeam.Version = version
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Teams.Update(c, userID, objectID, &updatedTeam); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setETag(c, updatedTeam.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Team successfully updated"})
+}
+
+// Handles a partial update of a team, sent as a JSON merge patch
+func (h *TeamHandler) PatchTeam(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID format"})
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	patch, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if userID != updatedTeam.OrganiserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the organizer of this team"})
+	version, ok := updateVersion(c, patchVersion(patch))
+	if !ok {
 		return
 	}
 
-	if err := models.UpdateTeam(c, objectID, &updatedTeam); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Team successfully updated"})
+	patchedTeam, err := h.Teams.Patch(c, userID, id, version, func(team *models.Team) error {
+		return mergePatch(patch, team)
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setETag(c, patchedTeam.Version)
+	c.JSON(http.StatusOK, patchedTeam)
 }
 
 // Handler to delete team
 func (h *TeamHandler) DeleteTeam(c *gin.Context) {
-	teamID := c.Param("id")
-
-	objectID, err := primitive.ObjectIDFromHex(teamID)
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID format"})
 		return
 	}
 
-	userIDStr, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+
+	if err := h.Teams.Delete(c, userID, objectID); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	team, err := models.GetTeamByID(c, objectID)
+	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
+}
+
+// Handles restoring a deleted team along with everything deleted with it
+func (h *TeamHandler) RestoreTeam(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID format"})
 		return
 	}
 
-	if userID != team.OrganiserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the organizer of this tournament"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	if err := models.DeleteTeam(c, objectID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.Teams.Restore(c, userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Team restored successfully"})
 }
 
-func NewTeamHandler() *TeamHandler {
-	return &TeamHandler{}
+func NewTeamHandler(teams *services.TeamService) *TeamHandler {
+	return &TeamHandler{
+		Teams: teams,
+	}
 }
@@ -1,20 +1,23 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type TournamentHandler struct {}
+type TournamentHandler struct {
+	Tournaments *services.TournamentService
+}
 
 // Handles creationg of a new tournament
-func (h *TournamentHandler) CreateTournament(c * gin.Context) {
-	userIDStr, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+func (h *TournamentHandler) CreateTournament(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
@@ -24,151 +27,176 @@ func (h *TournamentHandler) CreateTournament(c * gin.Context) {
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
-	newTournament.OrganiserID = userID
-	createdTournament, err := models.CreateTournament(c, &newTournament)
+	createdTournament, err := h.Tournaments.Create(c, userID, &newTournament)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	setETag(c, createdTournament.Version)
 	c.JSON(http.StatusCreated, createdTournament)
-
 }
 
-// Handles getting all tournaments by organiser ID
-func (h *TournamentHandler) GetTournamentsByOrganiserID(c *gin.Context) {
-	userID, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// Handles getting the tournaments in the user's organisations
+func (h *TournamentHandler) GetTournamentsForUser(c *gin.Context) {
+	userID, organisationID, ok := listParams(c)
+	if !ok {
 		return
 	}
 
-	organiserID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invaliad User ID format"})
+	query, ok := listQuery(c, models.TournamentListSchema)
+	if !ok {
 		return
 	}
 
-	tournaments, err := models.GetTournamentsByOrganiserID(c, organiserID)
+	tournaments, err := h.Tournaments.List(c, userID, organisationID, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, tournaments)
 }
 
-// handles the retrieval of a tournament by id
+// handles the retrieval of a tournament by id. Spectators read through the
+// public API, this is for the people managing it.
 func (h *TournamentHandler) GetTournamentByID(c *gin.Context) {
-	tournamentID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(tournamentID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Tournament ID format"})
 		return
 	}
 
-	tournament, err := models.GetTournamentByID(c, id)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tournament, err := h.Tournaments.Get(c, userID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	setETag(c, tournament.Version)
 	c.JSON(http.StatusOK, tournament)
 }
 
 // Handles updating of a tournament
 func (h *TournamentHandler) UpdateTournament(c *gin.Context) {
-	tournamentID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(tournamentID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Tournament ID format"})
 		return
 	}
 
 	var updatedTournament models.Tournament
-
 	if err := c.ShouldBindJSON(&updatedTournament); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Ensure that the user making the request is the tournament organizer
-	userIDStr, err := models.GetUserIDFromContext(c)
+	// An If-Match header takes precedence over a version in the body
+	version, ok := updateVersion(c, updatedTournament.Version)
+	if !ok {
+		return
+	}
+	updatedTournament.Version = version
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Tournaments.Update(c, userID, id, &updatedTournament); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setETag(c, updatedTournament.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Tournament updated successfully"})
+}
+
+// Handles a partial update of a tournament, sent as a JSON merge patch
+func (h *TournamentHandler) PatchTournament(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Tournament ID format"})
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	patch, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := updateVersion(c, patchVersion(patch))
+	if !ok {
 		return
 	}
 
-	if userID != updatedTournament.OrganiserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the organizer of this tournament"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	err = models.UpdateTournament(c, id, &updatedTournament)
+	patchedTournament, err := h.Tournaments.Patch(c, userID, id, version, func(tournament *models.Tournament) error {
+		return mergePatch(patch, tournament)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Tournament updated successfully"})
+	setETag(c, patchedTournament.Version)
+	c.JSON(http.StatusOK, patchedTournament)
 }
 
 // Handles the deletion of a tournament by its ID
 func (h *TournamentHandler) DeleteTournament(c *gin.Context) {
-	tournamentID := c.Param("id")
-
-	id, err := primitive.ObjectIDFromHex(tournamentID)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Tournament ID format"})
 		return
 	}
 
-	// Ensure that the user making the request is the tournament organizer
-	userIDStr, err := models.GetUserIDFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+
+	if err := h.Tournaments.Delete(c, userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	tournament, err := models.GetTournamentByID(c, id)
+	c.JSON(http.StatusOK, gin.H{"message": "Tournament deleted successfully"})
+}
+
+// Handles restoring a deleted tournament along with everything deleted with it
+func (h *TournamentHandler) RestoreTournament(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Tournament ID format"})
 		return
 	}
 
-	if userID != tournament.OrganiserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the organizer of this tournament"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	err = models.DeleteTournament(c, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.Tournaments.Restore(c, userID, id); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Tournament deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Tournament restored successfully"})
 }
 
-func NewTournamentHandler() *TournamentHandler {
-	return &TournamentHandler{}
-}
\ No newline at end of file
+func NewTournamentHandler(tournaments *services.TournamentService) *TournamentHandler {
+	return &TournamentHandler{
+		Tournaments: tournaments,
+	}
+}
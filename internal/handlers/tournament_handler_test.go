@@ -0,0 +1,82 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTournamentTeamsAndMatchesOnlyFollowTheirSide(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+	matchID, team1ID, _ := s.createMatch(userID, token)
+
+	var match struct {
+		TournamentID string
+	}
+	s.expect(http.StatusOK, "GET", "/matches/"+matchID, token, nil, &match)
+	item := "/tournaments/" + match.TournamentID
+
+	// Teams join by pointing at the tournament
+	s.expect(http.StatusOK, "PATCH", "/teams/"+team1ID, token, map[string]interface{}{"TournamentID": match.TournamentID, "Version": 1}, nil)
+
+	var tournament map[string]interface{}
+	s.expect(http.StatusOK, "GET", item, token, nil, &tournament)
+
+	// Patching the lists is refused
+	for _, field := range []string{"Teams", "Matches"} {
+		s.expect(http.StatusBadRequest, "PATCH", item, token, map[string]interface{}{field: []string{}, "Version": tournament["Version"]}, nil)
+	}
+
+	// Replacing the tournament keeps them
+	replaced := copyFields(tournament, map[string]interface{}{"Name": "Renamed", "Teams": []string{}, "Matches": nil})
+	s.expect(http.StatusOK, "PUT", item, token, replaced, nil)
+
+	var stored struct {
+		Name    string
+		Teams   []string
+		Matches []string
+	}
+	s.expect(http.StatusOK, "GET", item, token, nil, &stored)
+	if stored.Name != "Renamed" || len(stored.Teams) != 1 || stored.Teams[0] != team1ID || len(stored.Matches) != 1 || stored.Matches[0] != matchID {
+		t.Fatalf("got tournament %+v, want it renamed with its team and match kept", stored)
+	}
+}
+
+func TestUpdatesAreMadeAgainstAVersion(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("organiser@example.com")
+
+	var organisation document
+	s.expect(http.StatusCreated, "POST", "/organisations/", token, map[string]string{"name": "Org"}, &organisation)
+	tournament := map[string]interface{}{
+		"Name":           "Major",
+		"StartDate":      "2026-03-01T00:00:00Z",
+		"EndDate":        "2026-03-05T00:00:00Z",
+		"OrganiserID":    userID,
+		"OrganisationID": organisation.ID,
+	}
+	var created document
+	s.expect(http.StatusCreated, "POST", "/tournaments/", token, tournament, &created)
+	item := "/tournaments/" + created.ID
+
+	// Without a version an update would overwrite whatever is stored, so it is refused
+	s.expect(http.StatusPreconditionRequired, "PUT", item, token, copyFields(tournament, map[string]interface{}{"Name": "Blind"}), nil)
+	s.expect(http.StatusPreconditionRequired, "PATCH", item, token, map[string]interface{}{"Name": "Blind"}, nil)
+
+	// Two clients replace the version they both loaded, and the second is told what the first stored
+	s.expect(http.StatusOK, "PUT", item, token, copyFields(tournament, map[string]interface{}{"Name": "First", "Version": created.Version}), nil)
+
+	var conflict struct {
+		Current document
+	}
+	s.expect(http.StatusConflict, "PUT", item, token, copyFields(tournament, map[string]interface{}{"Name": "Second", "Version": created.Version}), &conflict)
+	if conflict.Current.Name != "First" || conflict.Current.Version != created.Version+1 {
+		t.Fatalf("stale update got current %+v, want the first update", conflict.Current)
+	}
+
+	var stored document
+	s.expect(http.StatusOK, "GET", item, token, nil, &stored)
+	if stored.Name != "First" {
+		t.Fatalf("stale update overwrote the tournament with %q", stored.Name)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+)
+
+// Handles the second step of logging in, checking the user's two-factor code
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var twoFactorLogin struct {
+		TwoFactorToken string `json:"two_factor_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&twoFactorLogin); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.Auth.CompleteTwoFactorLogin(c, twoFactorLogin.TwoFactorToken, twoFactorLogin.Code, clientInfo(c))
+	if err != nil {
+		if services.KindOf(err) == services.KindInternal {
+			log.Println("could not complete two-factor login:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	setAuthCookies(c, tokens)
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Handles starting two-factor enrolment, returning the secret and the URI to show as a QR code
+func (h *UserHandler) SetupTwoFactor(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	setup, err := h.Users.SetupTwoFactor(c, userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// Handles confirming two-factor enrolment with a code, returning the recovery codes
+func (h *UserHandler) EnableTwoFactor(c *gin.Context) {
+	var enableRequest struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&enableRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.Users.EnableTwoFactor(c, userID, enableRequest.Code)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": recoveryCodes})
+}
+
+// Handles turning off two-factor authentication
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	var disableRequest struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&disableRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Users.DisableTwoFactor(c, userID, disableRequest.Code); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// Handles replacing the user's recovery codes
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var regenerateRequest struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&regenerateRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.Users.RegenerateRecoveryCodes(c, userID, regenerateRequest.Code)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// Middleware for routes that the two-factor policy protects. Users the policy applies to
+// are refused until they have turned on two-factor authentication.
+func TwoFactorPolicyMiddleware(users *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		if err := users.CheckTwoFactorPolicy(c, userID); err != nil {
+			if services.KindOf(err) == services.KindNotFound {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			} else {
+				respondError(c, err)
+			}
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
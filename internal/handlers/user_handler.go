@@ -1,13 +1,21 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type UserHandler struct{}
+type UserHandler struct {
+	Users *services.UserService
+	Auth  *services.AuthService
+}
 
 // Handles user registration
 func (h *UserHandler) RegisterUser(c *gin.Context) {
@@ -18,12 +26,12 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 		return
 	}
 
-	if err := models.RegisterUser(c, &newUser); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.Users.Register(c, &newUser); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered Successfully"})
+	c.JSON(http.StatusCreated, gin.H{"message": "User registered Successfully. Check your email to verify your address."})
 }
 
 // Handles user login
@@ -38,18 +46,159 @@ func (h *UserHandler) LoginUser(c *gin.Context) {
 		return
 	}
 
-	token, err := models.LoginUser(c, &loginUser)
+	result, err := h.Auth.Login(c, loginUser.Email, loginUser.Password, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		if services.KindOf(err) == services.KindInternal {
+			log.Println("could not log in user:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	if result.AuthTokens != nil {
+		setAuthCookies(c, result.AuthTokens)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Handles swapping a refresh token for a new access token and refresh token
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var refreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// The refresh token can come from the body or the cookie set at login
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&refreshRequest); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if refreshRequest.RefreshToken == "" {
+		refreshRequest.RefreshToken, _ = c.Cookie("refreshToken")
+	}
+	if refreshRequest.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is missing"})
+		return
+	}
+
+	tokens, err := h.Auth.Refresh(c, refreshRequest.RefreshToken, clientInfo(c))
+	if err != nil {
+		clearAuthCookies(c)
+		respondError(c, err)
+		return
+	}
+
+	setAuthCookies(c, tokens)
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Handles verifying a user's email address with the token from their verification email
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var verifyRequest struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&verifyRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Users.VerifyEmail(c, verifyRequest.Token); err != nil {
+		if errors.Is(err, models.ErrInvalidAccountToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// Handles sending a new verification email
+func (h *UserHandler) ResendVerificationEmail(c *gin.Context) {
+	var resendRequest struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&resendRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Users.ResendVerificationEmail(c, resendRequest.Email); err != nil {
+		log.Println("could not resend verification email:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email belongs to an unverified account, a verification link has been sent"})
+}
+
+// Handles requesting a password reset email
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var forgotRequest struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&forgotRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	if err := h.Users.RequestPasswordReset(c, forgotRequest.Email); err != nil {
+		log.Println("could not send password reset email:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send password reset email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email belongs to an account, a password reset link has been sent"})
 }
 
-// Handles user logout and clears cookie
+// Handles setting a new password with the token from a password reset email
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var resetRequest struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&resetRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Users.ResetPassword(c, resetRequest.Token, resetRequest.Password); err != nil {
+		if errors.Is(err, models.ErrInvalidAccountToken) || errors.Is(err, models.ErrPasswordTooShort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// Handles user logout, revoking the session and clearing the token cookies
 func (h *UserHandler) LogoutUser(c *gin.Context) {
-	models.LogoutUser(c)
+	// Revoke the session the access token belongs to, or failing that the refresh token's
+	var userID, sessionID primitive.ObjectID
+	if userIDStr, err := getUserIDFromContext(c); err == nil {
+		userID, _ = primitive.ObjectIDFromHex(userIDStr)
+		sessionID, _ = primitive.ObjectIDFromHex(c.GetString("session_id"))
+	}
+	refreshToken, _ := c.Cookie("refreshToken")
+
+	if err := h.Auth.Logout(c, userID, sessionID, refreshToken); err != nil {
+		log.Println("could not revoke session:", err)
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
 // Handles user profile updates
@@ -64,26 +213,193 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Users.Update(c, userID, updateUser.Username, updateUser.Password); err != nil {
+		if services.KindOf(err) == services.KindInternal {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
+
+// Handles listing the current user's active sessions
+func (h *UserHandler) GetSessions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.Users.ListSessions(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentSessionID, _ := c.Get("session_id")
+	for _, session := range sessions {
+		session.Current = session.ID.Hex() == currentSessionID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// Handles revoking one of the current user's sessions
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID format"})
+		return
+	}
+
+	if err := h.Users.RevokeSession(c, userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// Handles revoking all of the current user's sessions
+func (h *UserHandler) RevokeAllSessions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Users.RevokeAllSessions(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked successfully"})
+}
+
+// Handles creating an API key for the current user. The key is only returned once.
+func (h *UserHandler) CreateAPIKey(c *gin.Context) {
+	var apiKeyRequest struct {
+		Name      string     `json:"name" binding:"required"`
+		Scopes    []string   `json:"scopes" binding:"required"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.ShouldBindJSON(&apiKeyRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	apiKey, key, err := h.Users.CreateAPIKey(c, userID, apiKeyRequest.Name, apiKeyRequest.Scopes, apiKeyRequest.ExpiresAt)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": apiKey, "key": key})
+}
+
+// Handles listing the current user's API keys
+func (h *UserHandler) GetAPIKeys(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	apiKeys, err := h.Users.ListAPIKeys(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, apiKeys)
+}
+
+// Handles revoking one of the current user's API keys
+func (h *UserHandler) RevokeAPIKey(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	keyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID format"})
+		return
+	}
+
+	if err := h.Users.RevokeAPIKey(c, userID, keyID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// Gets the ID of the signed in user, as set by the auth middleware
+func getUserIDFromContext(c *gin.Context) (string, error) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
+		return "", errors.New("User ID not found in context")
 	}
 
 	userIDStr, ok := userID.(string)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
+		return "", errors.New("User ID is not a string")
 	}
 
-	err := models.UpdateUser(c, userIDStr, &updateUser)
+	return userIDStr, nil
+}
+
+// Gets the signed in user's ID on routes anyone can use, or a zero ID if nobody is signed in
+func optionalUserID(c *gin.Context) primitive.ObjectID {
+	userIDStr, err := getUserIDFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-		return
+		return primitive.NilObjectID
+	}
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		return primitive.NilObjectID
 	}
+	return userID
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+// Gets the signed in user's ID, writing the error response and returning false if it is missing
+func currentUserID(c *gin.Context) (primitive.ObjectID, bool) {
+	userIDStr, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return primitive.NilObjectID, false
+	}
+
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return primitive.NilObjectID, false
+	}
+
+	return userID, true
 }
 
-func NewUserHandler() *UserHandler {
-	return &UserHandler{}
+func NewUserHandler(users *services.UserService, authService *services.AuthService) *UserHandler {
+	return &UserHandler{
+		Users: users,
+		Auth:  authService,
+	}
 }
@@ -2,20 +2,31 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type WebSocketHandler struct {
 	WebSocketHub *realtimemanager.WebSocketHub
+	Users        *services.UserService
+	Chat         *services.ChatService
 }
 
 func (wh *WebSocketHandler) HandleWebSocketMessages(c *gin.Context, conn *websocket.Conn) {
 	defer conn.Close()
 
+	// Register the connection with the hub so it receives broadcasts
+	sub := wh.WebSocketHub.AddClient(conn, presenceIdentity(c, wh.Users))
+	defer wh.WebSocketHub.RemoveClient(conn)
+
 	for {
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
@@ -37,79 +48,104 @@ func (wh *WebSocketHandler) HandleWebSocketMessages(c *gin.Context, conn *websoc
 
 		// Handle WebSocket messages based on the "action"
 		switch action {
-		case "tournament_created":
-			// Handle tournament creation and broadcast to clients
-			wh.handleTournamentCreated(msg)
-		case "tournament_updated":
-			// Handle tournament updates and broadcast to clients
-			wh.handleTournamentUpdated(msg)
-		case "match_result_created":
-			// Handle match result creation and broadcast to clients
-			wh.handleMatchResultCreated(msg)
-		case "match_result_updated":
-			// Handle match result updates and broadcast to clients
-			wh.handleMatchResultUpdated(msg)
-		case "team_created":
-			// Handle team creation and broadcast to clients
-			wh.handleTeamCreated(msg)
-		case "team_updated":
-			// Handle team updates and broadcast to clients
-			wh.handleTeamUpdated(msg)
-		case "match_created":
-			// Handle match creation and broadcast to clients
-			wh.handleMatchCreated(msg)
-		case "match_updated":
-			// Handle match updates and broadcast to clients
-			wh.handleMatchUpdated(msg)
+		case "subscribe":
+			// Limit the broadcasts this client receives to the given topics
+			wh.WebSocketHub.AddTopics(sub, publicTopics(messageTopics(message))...)
+		case "unsubscribe":
+			wh.WebSocketHub.RemoveTopics(sub, messageTopics(message)...)
+		case "chat_join":
+			// Join a match's lobby chat if the user is allowed to read it
+			if match, _, err := wh.chatAccess(c, message); err != nil {
+				wh.sendError(sub, action, err)
+			} else {
+				wh.WebSocketHub.AddTopics(sub, models.ChatTopic(match.ID))
+			}
+		case "chat_leave":
+			if matchID, err := primitive.ObjectIDFromHex(fmt.Sprint(message["match_id"])); err == nil {
+				wh.WebSocketHub.RemoveTopics(sub, models.ChatTopic(matchID))
+			}
+		case "chat_message":
+			// Post to a match's lobby chat, the message reaches members through the chat topic
+			match, access, err := wh.chatAccess(c, message)
+			if err == nil {
+				body, _ := message["body"].(string)
+				_, err = wh.Chat.Post(c, match, access, body)
+			}
+			if err != nil {
+				wh.sendError(sub, action, err)
+			}
 		default:
 			log.Printf("Unknown WebSocket action: %s", action)
 		}
 	}
 }
 
-// Define handler functions for each message type
-func (wh *WebSocketHandler) handleTournamentCreated(msg []byte) {
-	// Handle tournament creation and broadcast to clients
-	wh.WebSocketHub.Broadcast(msg)
-}
+// Reads the "topics" array from a subscribe or unsubscribe message
+func messageTopics(message map[string]interface{}) []string {
+	raw, ok := message["topics"].([]interface{})
+	if !ok {
+		return nil
+	}
 
-func (wh *WebSocketHandler) handleTournamentUpdated(msg []byte) {
-	// Handle tournament updates and broadcast to clients
-	wh.WebSocketHub.Broadcast(msg)
+	var topics []string
+	for _, topic := range raw {
+		if topicStr, ok := topic.(string); ok {
+			topics = append(topics, topicStr)
+		}
+	}
+	return topics
 }
 
-func (wh *WebSocketHandler) handleMatchResultCreated(msg []byte) {
-	// Handle match result creation and broadcast to clients
-	wh.WebSocketHub.Broadcast(msg)
+// Drops private topics, which can only be joined through their own actions such as chat_join
+func publicTopics(topics []string) []string {
+	var allowed []string
+	for _, topic := range topics {
+		if !realtimemanager.IsPrivateTopic(topic) {
+			allowed = append(allowed, topic)
+		}
+	}
+	return allowed
 }
 
-func (wh *WebSocketHandler) handleMatchResultUpdated(msg []byte) {
-	// Handle match result updates and broadcast to clients
-	wh.WebSocketHub.Broadcast(msg)
-}
+// Loads the match named in a chat message and checks the connected user can use its chat
+func (wh *WebSocketHandler) chatAccess(c *gin.Context, message map[string]interface{}) (*models.Match, *models.ChatAccess, error) {
+	userIDStr, err := getUserIDFromContext(c)
+	if err != nil {
+		return nil, nil, errors.New("You must be signed in to use chat")
+	}
 
-func (wh *WebSocketHandler) handleTeamCreated(msg []byte) {
-	// Handle team creation and broadcast to clients
-	wh.WebSocketHub.Broadcast(msg)
-}
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		return nil, nil, errors.New("Invalid user ID format")
+	}
 
-func (wh *WebSocketHandler) handleTeamUpdated(msg []byte) {
-	// Handle team updates and broadcast to clients
-	wh.WebSocketHub.Broadcast(msg)
-}
+	matchID, err := primitive.ObjectIDFromHex(fmt.Sprint(message["match_id"]))
+	if err != nil {
+		return nil, nil, errors.New("Invalid Match ID format")
+	}
 
-func (wh *WebSocketHandler) handleMatchCreated(msg []byte) {
-	// Handle match creation and broadcast to clients
-	wh.WebSocketHub.Broadcast(msg)
+	return wh.Chat.Access(c, userID, matchID)
 }
 
-func (wh *WebSocketHandler) handleMatchUpdated(msg []byte) {
-	// Handle match updates and broadcast to clients
-	wh.WebSocketHub.Broadcast(msg)
+// Sends an error back to the client that sent a message
+func (wh *WebSocketHandler) sendError(sub *realtimemanager.Subscription, action string, err error) {
+	messageJSON, marshalErr := json.Marshal(map[string]interface{}{
+		"action": "error",
+		"for":    action,
+		"error":  err.Error(),
+	})
+	if marshalErr != nil {
+		log.Printf("WebSocket error marshal error: %v", marshalErr)
+		return
+	}
+
+	wh.WebSocketHub.SendTo(sub, realtimemanager.Event{Action: "error", Data: messageJSON})
 }
 
-func NewWebSocketHandler(webSocketHub *realtimemanager.WebSocketHub) *WebSocketHandler {
+func NewWebSocketHandler(webSocketHub *realtimemanager.WebSocketHub, users *services.UserService, chat *services.ChatService) *WebSocketHandler {
 	return &WebSocketHandler{
 		WebSocketHub: webSocketHub,
+		Users:        users,
+		Chat:         chat,
 	}
 }
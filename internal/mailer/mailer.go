@@ -0,0 +1,101 @@
+// package for sending account emails such as verification and password reset links
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An email to send
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails. SMTPMailer is used in production and LogMailer during development.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Sends email through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	var smtpAuth smtp.Auth
+	if m.Username != "" {
+		smtpAuth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	body := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + message.To,
+		"Subject: " + message.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		message.Body,
+	}, "\r\n")
+
+	return smtp.SendMail(m.Host+":"+m.Port, smtpAuth, m.From, []string{message.To}, []byte(body))
+}
+
+// Writes emails to a file, or the log if no file is set, so the flows work offline
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	entry := fmt.Sprintf("---- %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().UTC().Format(time.RFC3339), message.To, message.Subject, message.Body)
+
+	if m.Path == "" {
+		log.Printf("Email not sent, logging instead:\n%s", entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
+
+// Creates the mailer configured by the environment. MAIL_DRIVER=smtp uses the SMTP_*
+// variables, anything else writes emails to MAIL_LOG_FILE or the log.
+func NewMailerFromEnv() Mailer {
+	if os.Getenv("MAIL_DRIVER") == "smtp" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	}
+
+	return &LogMailer{Path: os.Getenv("MAIL_LOG_FILE")}
+}
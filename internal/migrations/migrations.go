@@ -0,0 +1,133 @@
+// Package migrations keeps the database's indexes and document shapes up to date. Each
+// migration is a numbered Go step that runs once, and the ones that have run are recorded
+// in the migrations collection, so they can run at startup or from cmd/migrate.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// A step that changes the database. Up must be safe to run again if it fails part way.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// A migration as recorded in the migrations collection
+type Record struct {
+	Version     int        `bson:"_id" json:"version"`
+	Description string     `bson:"description" json:"description"`
+	StartedAt   time.Time  `bson:"started_at" json:"started_at"`
+	AppliedAt   *time.Time `bson:"applied_at,omitempty" json:"applied_at,omitempty"`
+}
+
+// A migration that was claimed but never finished, because another instance is running
+// it or the process running it stopped. Delete its record to run it again.
+var ErrMigrationInProgress = errors.New("Migration was started but hasn't finished")
+
+func migrationsCollection(db *mongo.Database) *mongo.Collection {
+	return db.Collection("migrations")
+}
+
+// Gets the recorded migrations, in version order
+func Applied(ctx context.Context, db *mongo.Database) ([]Record, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := migrationsCollection(db).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := []Record{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Runs the migrations that haven't been applied, in version order, and returns them.
+// Each one is claimed before it runs so two instances starting together don't both run it.
+func Run(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	records, err := Applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[int]Record, len(records))
+	for _, record := range records {
+		recorded[record.Version] = record
+	}
+
+	ran := []Migration{}
+	for _, migration := range All() {
+		if record, ok := recorded[migration.Version]; ok {
+			if record.AppliedAt == nil {
+				return ran, fmt.Errorf("%d %s: %w", migration.Version, migration.Description, ErrMigrationInProgress)
+			}
+			continue
+		}
+
+		if err := apply(ctx, db, migration); err != nil {
+			return ran, fmt.Errorf("%d %s: %w", migration.Version, migration.Description, err)
+		}
+		log.Printf("Applied migration %d: %s", migration.Version, migration.Description)
+		ran = append(ran, migration)
+	}
+
+	return ran, nil
+}
+
+// Claims a migration, runs it and marks it applied. A failed migration is unclaimed so
+// it runs again next time.
+func apply(ctx context.Context, db *mongo.Database, migration Migration) error {
+	collection := migrationsCollection(db)
+
+	record := Record{
+		Version:     migration.Version,
+		Description: migration.Description,
+		StartedAt:   time.Now().UTC(),
+	}
+	if _, err := collection.InsertOne(ctx, record); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrMigrationInProgress
+		}
+		return err
+	}
+
+	if err := migration.Up(ctx, db); err != nil {
+		if _, deleteErr := collection.DeleteOne(ctx, bson.M{"_id": migration.Version}); deleteErr != nil {
+			log.Printf("Failed to unclaim migration %d: %v", migration.Version, deleteErr)
+		}
+		return err
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": migration.Version}, bson.M{"$set": bson.M{"applied_at": time.Now().UTC()}})
+	return err
+}
+
+// Deletes the record of a migration that was started but never finished, so the next
+// run starts it again. Only use this once whatever was running it has stopped.
+func Release(ctx context.Context, db *mongo.Database, version int) error {
+	result, err := migrationsCollection(db).DeleteOne(ctx, bson.M{"_id": version, "applied_at": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("Migration %d isn't in progress", version)
+	}
+	return nil
+}
+
+// Gets every migration, in version order
+func All() []Migration {
+	return migrations
+}
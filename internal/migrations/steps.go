@@ -0,0 +1,279 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Every migration, in the order they run. Add new ones to the end with the next version,
+// and never change one that has been released.
+var migrations = []Migration{
+	{Version: 1, Description: "Create indexes", Up: createIndexes},
+	{Version: 2, Description: "Backfill entity versions", Up: backfillVersions},
+	{Version: 3, Description: "Convert dates to BSON dates and add timestamps", Up: typeDates},
+	{Version: 4, Description: "Create search indexes", Up: createSearchIndexes},
+	{Version: 5, Description: "Backfill and index search keys", Up: backfillSearchKeys},
+}
+
+// The indexes each collection is queried by
+var indexes = map[string][]mongo.IndexModel{
+	"users": {
+		// Users created through an OpenID provider can have no email, so only set ones are unique
+		{
+			Keys: bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName("email_unique").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"email": bson.M{"$gt": ""}}),
+		},
+	},
+	"tournaments": {
+		{Keys: bson.D{{Key: "organiser_id", Value: 1}}},
+		{Keys: bson.D{{Key: "organisation_id", Value: 1}}},
+		{Keys: bson.D{{Key: "deletion_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+	"teams": {
+		{Keys: bson.D{{Key: "organiser_id", Value: 1}}},
+		{Keys: bson.D{{Key: "organisation_id", Value: 1}}},
+		// Standings list a tournament's teams by name
+		{Keys: bson.D{{Key: "tournament_id", Value: 1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "deletion_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+	"matches": {
+		{Keys: bson.D{{Key: "organiser_id", Value: 1}}},
+		{Keys: bson.D{{Key: "organisation_id", Value: 1}}},
+		// Standings and schedules list a tournament's matches by date
+		{Keys: bson.D{{Key: "tournament_id", Value: 1}, {Key: "date", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "team1_id", Value: 1}}},
+		{Keys: bson.D{{Key: "team2_id", Value: 1}}},
+		{Keys: bson.D{{Key: "deletion_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+	"match_results": {
+		{Keys: bson.D{{Key: "organiser_id", Value: 1}}},
+		{Keys: bson.D{{Key: "organisation_id", Value: 1}}},
+		// Standings look up each match's result
+		{Keys: bson.D{{Key: "match_id", Value: 1}, {Key: "deleted_at", Value: 1}}},
+		{Keys: bson.D{{Key: "deletion_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+}
+
+// Creates the indexes. Creating an index that already exists does nothing, so this can
+// run again safely.
+func createIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, name := range []string{"users", "tournaments", "teams", "matches", "match_results"} {
+		if _, err := db.Collection(name).Indexes().CreateMany(ctx, indexes[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sets a version on tournaments, teams, matches and match results stored before they were
+// versioned, so they can be updated with If-Match
+func backfillVersions(ctx context.Context, db *mongo.Database) error {
+	// nil also matches documents with no version
+	filter := bson.M{"version": bson.M{"$in": bson.A{0, nil}}}
+	for _, name := range []string{"tournaments", "teams", "matches", "match_results"} {
+		if _, err := db.Collection(name).UpdateMany(ctx, filter, bson.M{"$set": bson.M{"version": 1}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Layouts dates were entered in before they were typed, tried in order. Dates without a
+// zone are taken to be UTC.
+var legacyDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02/01/2006 15:04",
+	"02/01/2006",
+}
+
+// Layouts with the day first. Some were entered month first, so a date in one of these
+// is only read if it can't be the other way round.
+var dayFirstLayouts = map[string]bool{
+	"02/01/2006 15:04": true,
+	"02/01/2006":       true,
+}
+
+// Reads a legacy date. ambiguous is set instead of ok for a day first date that reads as a
+// different date month first, such as 03/04/2006.
+func parseLegacyDate(value string) (date time.Time, ok, ambiguous bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range legacyDateLayouts {
+		date, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if dayFirstLayouts[layout] && date.Day() <= 12 && date.Day() != int(date.Month()) {
+			return time.Time{}, false, true
+		}
+		return date.UTC().Truncate(time.Millisecond), true, false
+	}
+	return time.Time{}, false, false
+}
+
+// Converts the free-form string dates on tournaments and matches to BSON dates in UTC, sets
+// tournaments' time zone to UTC, and sets created_at and updated_at from the document's ID
+// on everything that has none. A date that can't be read, or could be read either way
+// round, is moved to legacy_<field> so nothing is lost, and left for an organiser to set again.
+func typeDates(ctx context.Context, db *mongo.Database) error {
+	for _, field := range []struct{ collection, name string }{
+		{"tournaments", "start_date"},
+		{"tournaments", "end_date"},
+		{"matches", "date"},
+	} {
+		if err := typeDateField(ctx, db.Collection(field.collection), field.name); err != nil {
+			return err
+		}
+	}
+
+	timeZone := bson.M{"$in": bson.A{"", nil}}
+	if _, err := db.Collection("tournaments").UpdateMany(ctx, bson.M{"time_zone": timeZone}, bson.M{"$set": bson.M{"time_zone": "UTC"}}); err != nil {
+		return err
+	}
+
+	// An ObjectID holds the time it was made, which is when the document was created
+	backfill := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"created_at": bson.M{"$ifNull": bson.A{"$created_at", bson.M{"$toDate": "$_id"}}},
+		"updated_at": bson.M{"$ifNull": bson.A{"$updated_at", bson.M{"$ifNull": bson.A{"$created_at", bson.M{"$toDate": "$_id"}}}}},
+	}}}}
+	for _, name := range []string{"tournaments", "teams", "matches", "match_results", "users", "organisations"} {
+		filter := bson.M{"$or": bson.A{
+			bson.M{"created_at": bson.M{"$exists": false}},
+			bson.M{"updated_at": bson.M{"$exists": false}},
+		}}
+		if _, err := db.Collection(name).UpdateMany(ctx, filter, backfill); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func typeDateField(ctx context.Context, collection *mongo.Collection, field string) error {
+	cursor, err := collection.Find(ctx, bson.M{field: bson.M{"$type": "string"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document bson.M
+		if err := cursor.Decode(&document); err != nil {
+			return err
+		}
+		value, _ := document[field].(string)
+
+		update := bson.M{"$unset": bson.M{field: ""}}
+		date, ok, ambiguous := parseLegacyDate(value)
+		switch {
+		case ok:
+			update = bson.M{"$set": bson.M{field: date}}
+		case ambiguous:
+			update["$set"] = bson.M{"legacy_" + field: value}
+			log.Printf("Moved ambiguous %s %q on %s %v to legacy_%s, it could be day or month first", field, value, collection.Name(), document["_id"], field)
+		case strings.TrimSpace(value) != "":
+			update["$set"] = bson.M{"legacy_" + field: value}
+			log.Printf("Moved unreadable %s %q on %s %v to legacy_%s", field, value, collection.Name(), document["_id"], field)
+		}
+
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": document["_id"]}, update); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// The text indexes search uses. A collection can only have one. Names count for more than
+// descriptions and player handles, and the language is none so gamertags aren't stemmed
+// or dropped as stop words.
+var searchIndexes = map[string]mongo.IndexModel{
+	"tournaments": {
+		Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+		Options: options.Index().SetName("search").SetDefaultLanguage("none").
+			SetWeights(bson.M{"name": 10, "description": 1}),
+	},
+	"teams": {
+		Keys: bson.D{{Key: "name", Value: "text"}, {Key: "players", Value: "text"}},
+		Options: options.Index().SetName("search").SetDefaultLanguage("none").
+			SetWeights(bson.M{"name": 10, "players": 5}),
+	},
+}
+
+func createSearchIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, name := range []string{"tournaments", "teams"} {
+		if _, err := db.Collection(name).Indexes().CreateOne(ctx, searchIndexes[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The fields each collection's search keys are made from
+var searchKeyFields = map[string][]string{
+	"tournaments": {"name", "description"},
+	"teams":       {"name", "players"},
+}
+
+// Sets search keys on the tournaments and teams stored before search used them, and
+// indexes them
+func backfillSearchKeys(ctx context.Context, db *mongo.Database) error {
+	for _, name := range []string{"tournaments", "teams"} {
+		collection := db.Collection(name)
+		if _, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "search_keys", Value: 1}}}); err != nil {
+			return err
+		}
+
+		cursor, err := collection.Find(ctx, bson.M{"search_keys": bson.M{"$exists": false}})
+		if err != nil {
+			return err
+		}
+
+		for cursor.Next(ctx) {
+			var document bson.M
+			if err := cursor.Decode(&document); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+
+			var texts []string
+			for _, field := range searchKeyFields[name] {
+				switch value := document[field].(type) {
+				case string:
+					texts = append(texts, value)
+				case bson.A:
+					for _, item := range value {
+						if text, ok := item.(string); ok {
+							texts = append(texts, text)
+						}
+					}
+				}
+			}
+
+			update := bson.M{"$set": bson.M{"search_keys": models.SearchKeys(texts...)}}
+			if _, err := collection.UpdateOne(ctx, bson.M{"_id": document["_id"]}, update); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+		}
+
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLegacyDate(t *testing.T) {
+	for _, test := range []struct {
+		value         string
+		want          time.Time
+		ok, ambiguous bool
+	}{
+		{value: "2026-03-04T18:00:00+02:00", want: time.Date(2026, 3, 4, 16, 0, 0, 0, time.UTC), ok: true},
+		{value: "2026-03-04 18:00", want: time.Date(2026, 3, 4, 18, 0, 0, 0, time.UTC), ok: true},
+		// Only day first when the day can't be a month
+		{value: "25/03/2026", want: time.Date(2026, 3, 25, 0, 0, 0, 0, time.UTC), ok: true},
+		{value: "13/01/2026 18:30", want: time.Date(2026, 1, 13, 18, 30, 0, 0, time.UTC), ok: true},
+		// Or when either way round is the same date
+		{value: "04/04/2026", want: time.Date(2026, 4, 4, 0, 0, 0, 0, time.UTC), ok: true},
+		{value: "03/04/2026", ambiguous: true},
+		{value: "12/01/2026 18:30", ambiguous: true},
+		// Month first with a day over 12 doesn't read as either
+		{value: "03/25/2026"},
+		{value: "next tuesday"},
+	} {
+		date, ok, ambiguous := parseLegacyDate(test.value)
+		if ok != test.ok || ambiguous != test.ambiguous || !date.Equal(test.want) {
+			t.Errorf("parseLegacyDate(%q) = %v, %v, %v, want %v, %v, %v", test.value, date, ok, ambiguous, test.want, test.ok, test.ambiguous)
+		}
+	}
+}
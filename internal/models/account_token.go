@@ -0,0 +1,195 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/mailer"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// What an account token can be used for
+const (
+	TokenPurposeVerifyEmail    = "verify_email"
+	TokenPurposePasswordReset  = "password_reset"
+	TokenPurposeTwoFactorLogin = "two_factor_login"
+)
+
+// How long account tokens last before they have to be requested again
+const (
+	VerifyEmailTokenLifetime   = 24 * time.Hour
+	PasswordResetTokenLifetime = time.Hour
+)
+
+// A single use token emailed to a user to verify their address or reset their password.
+// Only a hash of the token is stored.
+type AccountToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Purpose   string             `bson:"purpose"`
+	TokenHash string             `bson:"token_hash"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+}
+
+var (
+	ErrInvalidAccountToken = errors.New("Invalid or expired token")
+	ErrPasswordTooShort    = errors.New("Password must be at least 8 characters")
+)
+
+// Base URL of the frontend the links in account emails point to
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// Creates a token for a user, replacing any unused token they have for the same purpose
+func createAccountToken(c context.Context, tokens AccountTokenRepository, userID primitive.ObjectID, purpose string, lifetime time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now().UTC()
+	accountToken := &AccountToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	}
+	if err := tokens.Replace(c, accountToken); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Gets a token that hasn't been used or expired, without using it up
+func findAccountToken(c context.Context, tokens AccountTokenRepository, token, purpose string) (*AccountToken, error) {
+	if token == "" {
+		return nil, ErrInvalidAccountToken
+	}
+
+	return tokens.Find(c, hashToken(token), purpose)
+}
+
+// Marks a token as used and returns it, as long as it has not been used or expired already
+func consumeAccountToken(c context.Context, tokens AccountTokenRepository, token, purpose string) (*AccountToken, error) {
+	if token == "" {
+		return nil, ErrInvalidAccountToken
+	}
+
+	return tokens.Consume(c, hashToken(token), purpose)
+}
+
+// Emails a user a link to verify their email address
+func SendVerificationEmail(c context.Context, tokens AccountTokenRepository, m mailer.Mailer, user *User) error {
+	token, err := createAccountToken(c, tokens, user.ID, TokenPurposeVerifyEmail, VerifyEmailTokenLifetime)
+	if err != nil {
+		return err
+	}
+
+	return m.Send(c, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below. It expires in 24 hours.\n\n%s/verify-email?token=%s\n",
+			user.Username, appURL(), token),
+	})
+}
+
+// Sends a new verification email if the address belongs to an unverified user.
+// Does nothing for unknown or verified addresses so callers cannot tell which emails are registered.
+func ResendVerificationEmail(c context.Context, repos *Repositories, m mailer.Mailer, email string) error {
+	user, err := repos.Users.GetByEmail(c, email)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	return SendVerificationEmail(c, repos.AccountTokens, m, user)
+}
+
+// Marks the email address of the user a verification token was sent to as verified
+func VerifyEmail(c context.Context, repos *Repositories, token string) error {
+	accountToken, err := consumeAccountToken(c, repos.AccountTokens, token, TokenPurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	if err := repos.Users.VerifyEmail(c, accountToken.UserID); err != nil {
+		if err == ErrUserNotFound {
+			return ErrInvalidAccountToken
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Emails a password reset link if the address belongs to a user. Does nothing for
+// unknown addresses so callers cannot tell which emails are registered.
+func RequestPasswordReset(c context.Context, repos *Repositories, m mailer.Mailer, email string) error {
+	user, err := repos.Users.GetByEmail(c, email)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil
+		}
+		return err
+	}
+
+	token, err := createAccountToken(c, repos.AccountTokens, user.ID, TokenPurposePasswordReset, PasswordResetTokenLifetime)
+	if err != nil {
+		return err
+	}
+
+	return m.Send(c, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. Open the link below to choose a new one. It expires in 1 hour.\n\n%s/reset-password?token=%s\n\nIf this wasn't you, you can ignore this email.\n",
+			user.Username, appURL(), token),
+	})
+}
+
+// Sets a new password using a reset token and signs the user out everywhere
+func ResetPassword(c context.Context, repos *Repositories, token, password string) error {
+	if len(password) < minPasswordLength {
+		return ErrPasswordTooShort
+	}
+
+	accountToken, err := consumeAccountToken(c, repos.AccountTokens, token, TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// Following the emailed link proves the user owns the address
+	if err := repos.Users.ResetPassword(c, accountToken.UserID, string(hashedPassword)); err != nil {
+		if err == ErrUserNotFound {
+			return ErrInvalidAccountToken
+		}
+		return err
+	}
+
+	return RevokeAllSessions(c, repos.Sessions, accountToken.UserID)
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How long API keys last when no expiry is given, and the longest they can last
+const (
+	DefaultAPIKeyLifetime = 90 * 24 * time.Hour
+	MaxAPIKeyLifetime     = 365 * 24 * time.Hour
+)
+
+// Last used times are only written this often, so busy keys don't write on every request
+const apiKeyLastUsedInterval = time.Minute
+
+// A named key bots and integrations use instead of logging in. Keys look like
+// "ctm_<id>_<secret>" and only a hash of the whole key is stored.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name       string             `bson:"name" json:"name"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+var (
+	ErrInvalidScope          = errors.New("Invalid scope")
+	ErrScopeNotManaged       = errors.New("You can only create keys for tournaments you manage")
+	ErrAPIKeyNameRequired    = errors.New("API key name is required")
+	ErrAPIKeyScopesRequired  = errors.New("API key needs at least one scope")
+	ErrAPIKeyExpiryInPast    = errors.New("API key expiry must be in the future")
+	ErrAPIKeyLifetimeTooLong = errors.New("API keys cannot last longer than a year")
+	ErrAPIKeyNotFound        = errors.New("API key not found")
+)
+
+// Checks a scope is one API keys can have. Tournament scopes need the user to be able
+// to manage the tournament or be staff for it.
+func validateAPIKeyScope(c context.Context, repos *Repositories, userID primitive.ObjectID, scope string) error {
+	switch scope {
+	case auth.ScopeResultsWrite:
+		return nil
+	}
+
+	tournamentIDStr, ok := strings.CutPrefix(scope, "tournament:")
+	if !ok {
+		return ErrInvalidScope
+	}
+	tournamentIDStr, ok = strings.CutSuffix(tournamentIDStr, ":manage")
+	if !ok {
+		return ErrInvalidScope
+	}
+	tournamentID, err := primitive.ObjectIDFromHex(tournamentIDStr)
+	if err != nil {
+		return ErrInvalidScope
+	}
+
+	tournament, err := repos.Tournaments.GetByID(c, tournamentID)
+	if err != nil {
+		return err
+	}
+	canManage, err := CanManageResource(c, repos.Organisations, tournament.OrganisationID, tournament.OrganiserID, userID)
+	if err != nil {
+		return err
+	}
+	if !canManage && !containsObjectID(tournament.StaffIDs, userID) {
+		return ErrScopeNotManaged
+	}
+
+	return nil
+}
+
+// Creates an API key for a user. The key itself is only returned here, it can't be shown again.
+func CreateAPIKey(c context.Context, repos *Repositories, userID primitive.ObjectID, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrAPIKeyNameRequired
+	}
+	if len(scopes) == 0 {
+		return nil, "", ErrAPIKeyScopesRequired
+	}
+	for _, scope := range scopes {
+		if err := validateAPIKeyScope(c, repos, userID, scope); err != nil {
+			return nil, "", err
+		}
+	}
+
+	now := time.Now().UTC()
+	expiry := now.Add(DefaultAPIKeyLifetime)
+	if expiresAt != nil {
+		expiry = expiresAt.UTC()
+	}
+	if !expiry.After(now) {
+		return nil, "", ErrAPIKeyExpiryInPast
+	}
+	if expiry.After(now.Add(MaxAPIKeyLifetime)) {
+		return nil, "", ErrAPIKeyLifetimeTooLong
+	}
+
+	apiKey := &APIKey{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: expiry,
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	key := auth.APIKeyPrefix + apiKey.ID.Hex() + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	apiKey.KeyHash = hashToken(key)
+
+	if err := repos.APIKeys.Create(c, apiKey); err != nil {
+		return nil, "", err
+	}
+
+	return apiKey, key, nil
+}
+
+// Checks API keys for auth.AuthMiddleware
+type APIKeyStore struct {
+	APIKeys APIKeyRepository
+}
+
+func (s APIKeyStore) VerifyAPIKey(c context.Context, key string) (*auth.APIKeyPrincipal, error) {
+	keyIDStr, _, found := strings.Cut(strings.TrimPrefix(key, auth.APIKeyPrefix), "_")
+	if !found {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	keyID, err := primitive.ObjectIDFromHex(keyIDStr)
+	if err != nil {
+		return nil, auth.ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.APIKeys.GetByID(c, keyID)
+	if err != nil {
+		if err == ErrAPIKeyNotFound {
+			return nil, auth.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashToken(key))) != 1 {
+		return nil, auth.ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	if apiKey.RevokedAt != nil || now.After(apiKey.ExpiresAt) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+
+	if err := s.APIKeys.MarkUsed(c, keyID, apiKeyLastUsedInterval); err != nil {
+		return nil, err
+	}
+
+	return &auth.APIKeyPrincipal{
+		KeyID:  apiKey.ID.Hex(),
+		UserID: apiKey.UserID.Hex(),
+		Scopes: apiKey.Scopes,
+	}, nil
+}
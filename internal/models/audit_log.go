@@ -0,0 +1,31 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditActionAccountLockedOut = "account_locked_out"
+	AuditActionIPLockedOut      = "ip_locked_out"
+)
+
+// A security relevant event, kept for organisers and admins to review
+type AuditLog struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Action    string                 `bson:"action" json:"action"`
+	UserID    *primitive.ObjectID    `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Email     string                 `bson:"email,omitempty" json:"email,omitempty"`
+	IPAddress string                 `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+	Details   map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt time.Time              `bson:"created_at" json:"created_at"`
+}
+
+// Saves an entry to the audit log
+func RecordAuditLog(c context.Context, auditLogs AuditLogRepository, entry *AuditLog) error {
+	entry.CreatedAt = time.Now().UTC()
+	return auditLogs.Create(c, entry)
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Longest chat message that can be posted
+const maxChatMessageLength = 1000
+
+// Page size limits for chat history
+const (
+	defaultChatPageSize = 50
+	maxChatPageSize     = 200
+)
+
+// A message posted in a match's lobby chat
+type ChatMessage struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	MatchID   primitive.ObjectID  `bson:"match_id" json:"match_id"`
+	UserID    primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	Username  string              `bson:"username" json:"username"`
+	Body      string              `bson:"body" json:"body"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+	DeletedAt *time.Time          `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy *primitive.ObjectID `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+}
+
+// Stops a user posting in a match's chat until a given time
+type ChatMute struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MatchID primitive.ObjectID `bson:"match_id" json:"match_id"`
+	UserID  primitive.ObjectID `bson:"user_id" json:"user_id"`
+	MutedBy primitive.ObjectID `bson:"muted_by" json:"muted_by"`
+	Reason  string             `bson:"reason" json:"reason"`
+	Until   time.Time          `bson:"until" json:"until"`
+}
+
+// What a user is allowed to do in a match's chat
+type ChatAccess struct {
+	User *User
+	// The user plays for one of the teams, referees the match or is tournament staff
+	Member bool
+	// The user can delete messages and mute members
+	Moderator bool
+}
+
+var (
+	ErrChatForbidden       = errors.New("You do not have access to this match's chat")
+	ErrChatMuted           = errors.New("You are muted in this match's chat")
+	ErrChatMessageEmpty    = errors.New("Message cannot be empty")
+	ErrChatMessageTooLong  = errors.New("Message cannot be longer than 1000 characters")
+	ErrChatMessageNotFound = errors.New("Message not found")
+	ErrInvalidMuteDuration = errors.New("Mute duration must be positive")
+)
+
+// Topic chat events for a match are published on
+func ChatTopic(matchID primitive.ObjectID) string {
+	return realtimemanager.Topic("chat", matchID.Hex())
+}
+
+func containsObjectID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Works out whether a user can read, post in or moderate a match's chat. Players of
+// either team are members, referees, the organisation's members and tournament staff are moderators.
+func GetChatAccess(c context.Context, repos *Repositories, match *Match, userID primitive.ObjectID) (*ChatAccess, error) {
+	user, err := repos.Users.GetByID(c, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &ChatAccess{User: user}
+
+	canManage, err := CanManageResource(c, repos.Organisations, match.OrganisationID, match.OrganiserID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if canManage || containsObjectID(match.RefereeIDs, userID) {
+		access.Moderator = true
+	} else if tournament, err := repos.Tournaments.GetByID(c, match.TournamentID); err == nil {
+		if userID == tournament.OrganiserID || containsObjectID(tournament.StaffIDs, userID) {
+			access.Moderator = true
+		}
+	}
+
+	if access.Moderator {
+		access.Member = true
+		return access, nil
+	}
+
+	for _, teamID := range []primitive.ObjectID{match.Team1ID, match.Team2ID} {
+		team, err := repos.Teams.GetByID(c, teamID)
+		if err != nil {
+			continue
+		}
+		for _, player := range team.Players {
+			if strings.EqualFold(strings.TrimSpace(player), user.Username) {
+				access.Member = true
+				return access, nil
+			}
+		}
+	}
+
+	return access, nil
+}
+
+// Saves a chat message and records its event for the match's chat topic
+func CreateChatMessage(c context.Context, chat ChatRepository, match *Match, access *ChatAccess, body string) (*ChatMessage, error) {
+	if !access.Member {
+		return nil, ErrChatForbidden
+	}
+
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrChatMessageEmpty
+	}
+	if len(body) > maxChatMessageLength {
+		return nil, ErrChatMessageTooLong
+	}
+
+	mute, err := chat.GetActiveMute(c, match.ID, access.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mute != nil {
+		return nil, ErrChatMuted
+	}
+
+	chatMessage := &ChatMessage{
+		MatchID:   match.ID,
+		UserID:    access.User.ID,
+		Username:  access.User.Username,
+		Body:      body,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := chat.CreateMessage(c, chatMessage); err != nil {
+		return nil, err
+	}
+
+	return chatMessage, nil
+}
+
+// Gets a page of a match's chat history, newest first. Pass the oldest message ID
+// from the previous page as before to get the page after it.
+func GetChatMessages(c context.Context, chat ChatRepository, matchID primitive.ObjectID, before primitive.ObjectID, limit int) ([]*ChatMessage, error) {
+	if limit <= 0 {
+		limit = defaultChatPageSize
+	}
+	if limit > maxChatPageSize {
+		limit = maxChatPageSize
+	}
+
+	return chat.ListMessages(c, matchID, before, limit)
+}
+
+// Removes a message's text, keeping a record of who deleted it
+func DeleteChatMessage(c context.Context, chat ChatRepository, match *Match, access *ChatAccess, messageID primitive.ObjectID) error {
+	if !access.Moderator {
+		return ErrChatForbidden
+	}
+
+	return chat.DeleteMessage(c, match.ID, messageID, access.User.ID)
+}
+
+// Mutes a member of a match's chat for a while
+func MuteChatUser(c context.Context, chat ChatRepository, match *Match, access *ChatAccess, userID primitive.ObjectID, duration time.Duration, reason string) (*ChatMute, error) {
+	if !access.Moderator {
+		return nil, ErrChatForbidden
+	}
+	if duration <= 0 {
+		return nil, ErrInvalidMuteDuration
+	}
+
+	mute := &ChatMute{
+		MatchID: match.ID,
+		UserID:  userID,
+		MutedBy: access.User.ID,
+		Reason:  reason,
+		Until:   time.Now().UTC().Add(duration),
+	}
+
+	if err := chat.Mute(c, mute); err != nil {
+		return nil, err
+	}
+
+	return mute, nil
+}
+
+// Lifts a user's mute in a match's chat
+func UnmuteChatUser(c context.Context, chat ChatRepository, match *Match, access *ChatAccess, userID primitive.ObjectID) error {
+	if !access.Moderator {
+		return ErrChatForbidden
+	}
+
+	return chat.Unmute(c, match.ID, userID, access.User.ID)
+}
@@ -0,0 +1,563 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Tournaments, teams, matches and match results are soft deleted: they are marked with
+// deleted_at and left out of every query until they are restored or purged. Everything
+// removed by one delete shares a deletion_id, so restoring any of it restores all of it.
+
+var (
+	ErrTournamentHasTeams   = errors.New("Tournament still has teams")
+	ErrTournamentHasMatches = errors.New("Tournament still has matches")
+	ErrTeamHasMatches       = errors.New("Team still has matches")
+	ErrMatchHasResults      = errors.New("Match still has results")
+	ErrParentDeleted        = errors.New("Restore the tournament, team or match this belongs to first")
+	ErrInvalidCascadeAction = errors.New("Invalid cascade action")
+)
+
+// Marks a soft deleted document
+type Deletion struct {
+	DeletedAt  *time.Time         `bson:"deleted_at,omitempty" json:"-"`
+	DeletionID primitive.ObjectID `bson:"deletion_id,omitempty" json:"-"`
+}
+
+func (d *Deletion) deleted() bool {
+	return d.DeletedAt != nil
+}
+
+// What happens to the documents that refer to one being deleted
+type CascadeAction string
+
+const (
+	// Deletes them too
+	CascadeDelete CascadeAction = "delete"
+	// Keeps them but clears their reference
+	CascadeDetach CascadeAction = "detach"
+	// Refuses the delete while any exist
+	CascadeRestrict CascadeAction = "restrict"
+)
+
+// How deletes cascade. Teams and matches can be detached from a tournament, but a match
+// can't be kept without its teams or a result without its match.
+type CascadeRules struct {
+	TournamentTeams   CascadeAction
+	TournamentMatches CascadeAction
+	TeamMatches       CascadeAction
+	MatchResults      CascadeAction
+}
+
+// Teams outlive their tournament, a tournament's matches and results go with it, and a
+// team can't be deleted while it still has matches
+func DefaultCascadeRules() CascadeRules {
+	return CascadeRules{
+		TournamentTeams:   CascadeDetach,
+		TournamentMatches: CascadeDelete,
+		TeamMatches:       CascadeRestrict,
+		MatchResults:      CascadeDelete,
+	}
+}
+
+// Reads the rules from CASCADE_TOURNAMENT_TEAMS, CASCADE_TOURNAMENT_MATCHES,
+// CASCADE_TEAM_MATCHES and CASCADE_MATCH_RESULTS, using the defaults for any not set
+func CascadeRulesFromEnv() (CascadeRules, error) {
+	rules := CascadeRules{
+		TournamentTeams:   CascadeAction(strings.ToLower(strings.TrimSpace(os.Getenv("CASCADE_TOURNAMENT_TEAMS")))),
+		TournamentMatches: CascadeAction(strings.ToLower(strings.TrimSpace(os.Getenv("CASCADE_TOURNAMENT_MATCHES")))),
+		TeamMatches:       CascadeAction(strings.ToLower(strings.TrimSpace(os.Getenv("CASCADE_TEAM_MATCHES")))),
+		MatchResults:      CascadeAction(strings.ToLower(strings.TrimSpace(os.Getenv("CASCADE_MATCH_RESULTS")))),
+	}.withDefaults()
+
+	return rules, rules.Validate()
+}
+
+func (r CascadeRules) withDefaults() CascadeRules {
+	defaults := DefaultCascadeRules()
+	if r.TournamentTeams == "" {
+		r.TournamentTeams = defaults.TournamentTeams
+	}
+	if r.TournamentMatches == "" {
+		r.TournamentMatches = defaults.TournamentMatches
+	}
+	if r.TeamMatches == "" {
+		r.TeamMatches = defaults.TeamMatches
+	}
+	if r.MatchResults == "" {
+		r.MatchResults = defaults.MatchResults
+	}
+	return r
+}
+
+// Checks every rule is an action its documents allow
+func (r CascadeRules) Validate() error {
+	for name, rule := range map[string]struct {
+		action   CascadeAction
+		detached bool
+	}{
+		"tournament teams":   {r.TournamentTeams, true},
+		"tournament matches": {r.TournamentMatches, true},
+		"team matches":       {r.TeamMatches, false},
+		"match results":      {r.MatchResults, false},
+	} {
+		switch rule.action {
+		case CascadeDelete, CascadeRestrict:
+		case CascadeDetach:
+			if !rule.detached {
+				return fmt.Errorf("%w for %s: %s", ErrInvalidCascadeAction, name, rule.action)
+			}
+		default:
+			return fmt.Errorf("%w for %s: %s", ErrInvalidCascadeAction, name, rule.action)
+		}
+	}
+	return nil
+}
+
+// Finds the documents that refer to one being deleted, leaving out deleted ones, and
+// whether a document is still there
+type cascadeReader interface {
+	teamsInTournament(c context.Context, tournamentID primitive.ObjectID) ([]primitive.ObjectID, error)
+	matchesInTournament(c context.Context, tournamentID primitive.ObjectID) ([]primitive.ObjectID, error)
+	matchesForTeam(c context.Context, teamID primitive.ObjectID) ([]primitive.ObjectID, error)
+	resultsForMatch(c context.Context, matchID primitive.ObjectID) ([]primitive.ObjectID, error)
+	isLive(c context.Context, collection string, id primitive.ObjectID) (bool, error)
+}
+
+// Everything one delete changes, worked out before anything is written so the Mongo and
+// memory stores apply the same rules
+type deletionPlan struct {
+	rules  CascadeRules
+	reader cascadeReader
+
+	tournaments     []primitive.ObjectID
+	teams           []primitive.ObjectID
+	matches         []primitive.ObjectID
+	matchResults    []primitive.ObjectID
+	detachedTeams   []primitive.ObjectID
+	detachedMatches []primitive.ObjectID
+}
+
+func newDeletionPlan(rules CascadeRules, reader cascadeReader) *deletionPlan {
+	return &deletionPlan{rules: rules.withDefaults(), reader: reader}
+}
+
+// Plans deleting a document from one of the soft deleted collections
+func (p *deletionPlan) delete(c context.Context, collection string, id primitive.ObjectID) error {
+	switch collection {
+	case "tournaments":
+		return p.deleteTournament(c, id)
+	case "teams":
+		return p.deleteTeam(c, id)
+	case "matches":
+		return p.deleteMatch(c, id)
+	default:
+		p.matchResults = append(p.matchResults, id)
+		return nil
+	}
+}
+
+// Matches are handled before teams, so teams don't count matches going with the tournament
+func (p *deletionPlan) deleteTournament(c context.Context, id primitive.ObjectID) error {
+	p.tournaments = append(p.tournaments, id)
+
+	matchIDs, err := p.reader.matchesInTournament(c, id)
+	if err != nil {
+		return err
+	}
+	switch p.rules.TournamentMatches {
+	case CascadeRestrict:
+		if len(matchIDs) > 0 {
+			return ErrTournamentHasMatches
+		}
+	case CascadeDetach:
+		p.detachedMatches = append(p.detachedMatches, matchIDs...)
+	default:
+		for _, matchID := range matchIDs {
+			if err := p.deleteMatch(c, matchID); err != nil {
+				return err
+			}
+		}
+	}
+
+	teamIDs, err := p.reader.teamsInTournament(c, id)
+	if err != nil {
+		return err
+	}
+	switch p.rules.TournamentTeams {
+	case CascadeRestrict:
+		if len(teamIDs) > 0 {
+			return ErrTournamentHasTeams
+		}
+	case CascadeDetach:
+		p.detachedTeams = append(p.detachedTeams, teamIDs...)
+	default:
+		for _, teamID := range teamIDs {
+			if err := p.deleteTeam(c, teamID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *deletionPlan) deleteTeam(c context.Context, id primitive.ObjectID) error {
+	p.teams = append(p.teams, id)
+
+	matchIDs, err := p.reader.matchesForTeam(c, id)
+	if err != nil {
+		return err
+	}
+	for _, matchID := range matchIDs {
+		if containsObjectID(p.matches, matchID) {
+			continue
+		}
+		if p.rules.TeamMatches == CascadeRestrict {
+			return ErrTeamHasMatches
+		}
+		if err := p.deleteMatch(c, matchID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *deletionPlan) deleteMatch(c context.Context, id primitive.ObjectID) error {
+	if containsObjectID(p.matches, id) {
+		return nil
+	}
+	p.matches = append(p.matches, id)
+
+	resultIDs, err := p.reader.resultsForMatch(c, id)
+	if err != nil {
+		return err
+	}
+	if len(resultIDs) > 0 && p.rules.MatchResults == CascadeRestrict {
+		return ErrMatchHasResults
+	}
+	p.matchResults = append(p.matchResults, resultIDs...)
+
+	return nil
+}
+
+// Teams and matches that no tournament should list any more
+func (p *deletionPlan) unlistedTeams() []primitive.ObjectID {
+	return append(append([]primitive.ObjectID{}, p.teams...), p.detachedTeams...)
+}
+
+func (p *deletionPlan) unlistedMatches() []primitive.ObjectID {
+	return append(append([]primitive.ObjectID{}, p.matches...), p.detachedMatches...)
+}
+
+// The documents deleted together
+type deletionGroup struct {
+	tournaments  []*Tournament
+	teams        []*Team
+	matches      []*Match
+	matchResults []*MatchResult
+}
+
+// Checks everything a group refers to is either still there or being restored with it
+func (g *deletionGroup) checkParents(c context.Context, reader cascadeReader) error {
+	var tournamentIDs, teamIDs, matchIDs []primitive.ObjectID
+	for _, tournament := range g.tournaments {
+		tournamentIDs = append(tournamentIDs, tournament.ID)
+	}
+	for _, team := range g.teams {
+		teamIDs = append(teamIDs, team.ID)
+	}
+	for _, match := range g.matches {
+		matchIDs = append(matchIDs, match.ID)
+	}
+
+	check := func(collection string, id primitive.ObjectID, restoring []primitive.ObjectID) error {
+		if id.IsZero() || containsObjectID(restoring, id) {
+			return nil
+		}
+		live, err := reader.isLive(c, collection, id)
+		if err != nil {
+			return err
+		}
+		if !live {
+			return ErrParentDeleted
+		}
+		return nil
+	}
+
+	for _, team := range g.teams {
+		if err := check("tournaments", team.TournamentID, tournamentIDs); err != nil {
+			return err
+		}
+	}
+	for _, match := range g.matches {
+		if err := check("tournaments", match.TournamentID, tournamentIDs); err != nil {
+			return err
+		}
+		if err := check("teams", match.Team1ID, teamIDs); err != nil {
+			return err
+		}
+		if err := check("teams", match.Team2ID, teamIDs); err != nil {
+			return err
+		}
+	}
+	for _, matchResult := range g.matchResults {
+		if err := check("matches", matchResult.MatchID, matchIDs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The soft deleted collections, with the name their events use, children first so
+// purging never leaves a child without its parent
+var deletableCollections = []struct {
+	collection string
+	name       string
+}{
+	{"match_results", "match_result"},
+	{"matches", "match"},
+	{"teams", "team"},
+	{"tournaments", "tournament"},
+}
+
+func deletableName(collection string) string {
+	for _, deletable := range deletableCollections {
+		if deletable.collection == collection {
+			return deletable.name
+		}
+	}
+	return collection
+}
+
+// Adds a filter that leaves out soft deleted documents
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+func collectionNamed(name string) *mongo.Collection {
+	return database.GetMongoClient().Database("esports-tournament-manager").Collection(name)
+}
+
+// Reads the cascade inside the delete's transaction
+type mongoCascadeReader struct{}
+
+func (mongoCascadeReader) liveIDs(c context.Context, collection string, filter bson.M) ([]primitive.ObjectID, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := collectionNamed(collection).Find(c, notDeleted(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(c)
+
+	var ids []primitive.ObjectID
+	for cursor.Next(c) {
+		var document struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		ids = append(ids, document.ID)
+	}
+
+	return ids, cursor.Err()
+}
+
+func (r mongoCascadeReader) teamsInTournament(c context.Context, tournamentID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return r.liveIDs(c, "teams", bson.M{"tournament_id": tournamentID})
+}
+
+func (r mongoCascadeReader) matchesInTournament(c context.Context, tournamentID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return r.liveIDs(c, "matches", bson.M{"tournament_id": tournamentID})
+}
+
+func (r mongoCascadeReader) matchesForTeam(c context.Context, teamID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return r.liveIDs(c, "matches", bson.M{"$or": bson.A{bson.M{"team1_id": teamID}, bson.M{"team2_id": teamID}}})
+}
+
+func (r mongoCascadeReader) resultsForMatch(c context.Context, matchID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return r.liveIDs(c, "match_results", bson.M{"match_id": matchID})
+}
+
+func (mongoCascadeReader) isLive(c context.Context, collection string, id primitive.ObjectID) (bool, error) {
+	count, err := collectionNamed(collection).CountDocuments(c, notDeleted(bson.M{"_id": id}))
+	return count > 0, err
+}
+
+// Soft deletes a document and everything its cascade rules reach in one transaction
+func softDelete(c context.Context, collection string, id primitive.ObjectID, rules CascadeRules, notFound error) error {
+	return withTransaction(c, func(sc mongo.SessionContext) error {
+		reader := mongoCascadeReader{}
+		live, err := reader.isLive(sc, collection, id)
+		if err != nil {
+			return err
+		}
+		if !live {
+			return notFound
+		}
+
+		plan := newDeletionPlan(rules, reader)
+		if err := plan.delete(sc, collection, id); err != nil {
+			return err
+		}
+
+		mark := bson.M{"$set": bson.M{"deleted_at": time.Now().UTC(), "deletion_id": primitive.NewObjectID()}}
+		for name, ids := range map[string][]primitive.ObjectID{
+			"tournaments":   plan.tournaments,
+			"teams":         plan.teams,
+			"matches":       plan.matches,
+			"match_results": plan.matchResults,
+		} {
+			if len(ids) == 0 {
+				continue
+			}
+			if _, err := collectionNamed(name).UpdateMany(sc, bson.M{"_id": bson.M{"$in": ids}}, mark); err != nil {
+				return err
+			}
+		}
+
+		detach := bumped(bson.M{"$unset": bson.M{"tournament_id": ""}})
+		for name, ids := range map[string][]primitive.ObjectID{"teams": plan.detachedTeams, "matches": plan.detachedMatches} {
+			if len(ids) == 0 {
+				continue
+			}
+			if _, err := collectionNamed(name).UpdateMany(sc, bson.M{"_id": bson.M{"$in": ids}}, detach); err != nil {
+				return err
+			}
+		}
+
+		// Take them out of every tournament's lists so no tournament is left pointing at them
+		teamIDs, matchIDs := plan.unlistedTeams(), plan.unlistedMatches()
+		if len(teamIDs) > 0 || len(matchIDs) > 0 {
+			filter := bson.M{"$or": bson.A{bson.M{"teams": bson.M{"$in": teamIDs}}, bson.M{"matches": bson.M{"$in": matchIDs}}}}
+			pull := bumped(bson.M{"$pull": bson.M{"teams": bson.M{"$in": teamIDs}, "matches": bson.M{"$in": matchIDs}}})
+			if _, err := collectionNamed("tournaments").UpdateMany(sc, filter, pull); err != nil {
+				return err
+			}
+		}
+
+		name := deletableName(collection)
+		return recordOutboxEvent(sc, map[string]interface{}{
+			"action":     name + "_deleted",
+			name + "_id": id.Hex(),
+		})
+	})
+}
+
+// Gets a soft deleted document, so it can be checked before it is restored
+func getDeleted[T any](c context.Context, collection string, id primitive.ObjectID, notFound error) (*T, error) {
+	var document T
+	err := collectionNamed(collection).FindOne(c, bson.M{"_id": id, "deleted_at": bson.M{"$exists": true}}).Decode(&document)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, notFound
+		}
+		return nil, err
+	}
+
+	return &document, nil
+}
+
+func findDeleted[T any](c context.Context, collection string, deletionID primitive.ObjectID) ([]*T, error) {
+	cursor, err := collectionNamed(collection).Find(c, bson.M{"deletion_id": deletionID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(c)
+
+	var documents []*T
+	for cursor.Next(c) {
+		var document T
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		documents = append(documents, &document)
+	}
+
+	return documents, cursor.Err()
+}
+
+// Restores a soft deleted document and everything deleted with it in one transaction,
+// putting teams and matches back on their tournaments' lists
+func restoreDeleted(c context.Context, collection string, id primitive.ObjectID, notFound error) error {
+	return withTransaction(c, func(sc mongo.SessionContext) error {
+		deleted, err := getDeleted[Deletion](sc, collection, id, notFound)
+		if err != nil {
+			return err
+		}
+
+		var group deletionGroup
+		if group.tournaments, err = findDeleted[Tournament](sc, "tournaments", deleted.DeletionID); err != nil {
+			return err
+		}
+		if group.teams, err = findDeleted[Team](sc, "teams", deleted.DeletionID); err != nil {
+			return err
+		}
+		if group.matches, err = findDeleted[Match](sc, "matches", deleted.DeletionID); err != nil {
+			return err
+		}
+		if group.matchResults, err = findDeleted[MatchResult](sc, "match_results", deleted.DeletionID); err != nil {
+			return err
+		}
+
+		if err := group.checkParents(sc, mongoCascadeReader{}); err != nil {
+			return err
+		}
+
+		unmark := bson.M{"$unset": bson.M{"deleted_at": "", "deletion_id": ""}}
+		for _, deletable := range deletableCollections {
+			if _, err := collectionNamed(deletable.collection).UpdateMany(sc, bson.M{"deletion_id": deleted.DeletionID}, unmark); err != nil {
+				return err
+			}
+		}
+
+		for _, team := range group.teams {
+			if !team.TournamentID.IsZero() {
+				if err := addToTournament(sc, "teams", team.TournamentID, team.ID); err != nil {
+					return err
+				}
+			}
+		}
+		for _, match := range group.matches {
+			if !match.TournamentID.IsZero() {
+				if err := addToTournament(sc, "matches", match.TournamentID, match.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		name := deletableName(collection)
+		return recordOutboxEvent(sc, map[string]interface{}{
+			"action":     name + "_restored",
+			name + "_id": id.Hex(),
+		})
+	})
+}
+
+// Removes documents soft deleted before the given time for good
+func PurgeDeleted(c context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for _, deletable := range deletableCollections {
+		result, err := collectionNamed(deletable.collection).DeleteMany(c, bson.M{"deleted_at": bson.M{"$lt": before}})
+		if err != nil {
+			return purged, err
+		}
+		purged += result.DeletedCount
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,613 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidListQuery = errors.New("Invalid list query")
+	ErrInvalidCursor    = errors.New("Cursor is invalid or was made for a different sort")
+)
+
+// How many documents a page has when no limit is given, and the most it can have
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+// A condition on a document. With Missing set it matches when any of Fields isn't set,
+// otherwise when any of Fields equals one of In (if given) and lies between From and To
+// (if given). Array fields match when any element does.
+type Condition struct {
+	Fields  []string
+	In      []interface{}
+	From    time.Time
+	To      time.Time
+	Missing bool
+}
+
+// What to list: the conditions documents must meet, the order and where the page starts.
+// Sort is a field, with a leading - to sort descending. Ties are broken by ID.
+type ListQuery struct {
+	Conditions []Condition
+	Sort       string
+	Limit      int
+	Cursor     string
+}
+
+// One page of a list, with the total across every page. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []*T   `json:"items"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Turns a query parameter's value into conditions
+type FilterParser func(value string) ([]Condition, error)
+
+// The query parameters a list accepts. Sorts maps each sort key to the field it sorts by,
+// and DefaultSort is the key used when none is given, with a leading - for descending.
+type ListSchema struct {
+	Filters     map[string]FilterParser
+	Sorts       map[string]string
+	DefaultSort string
+}
+
+// Parses a list's query string: the schema's filters, sort=<key> or sort=-<key>,
+// limit=<n> and cursor=<next_cursor from the previous page>
+func ParseListQuery(schema ListSchema, values url.Values) (ListQuery, error) {
+	query := ListQuery{Limit: DefaultListLimit}
+
+	sortKey := values.Get("sort")
+	if sortKey == "" {
+		sortKey = schema.DefaultSort
+	}
+	field, ok := schema.Sorts[strings.TrimPrefix(sortKey, "-")]
+	if !ok {
+		return query, fmt.Errorf("%w: can't sort by %q", ErrInvalidListQuery, sortKey)
+	}
+	query.Sort = field
+	if strings.HasPrefix(sortKey, "-") {
+		query.Sort = "-" + field
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxListLimit {
+			return query, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListQuery, MaxListLimit)
+		}
+		query.Limit = n
+	}
+
+	query.Cursor = values.Get("cursor")
+	if _, err := query.after(); err != nil {
+		return query, err
+	}
+
+	// Sorted so the same query string always gives the same conditions
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "sort" || name == "limit" || name == "cursor" {
+			continue
+		}
+
+		parse, ok := schema.Filters[name]
+		if !ok {
+			return query, fmt.Errorf("%w: unknown filter %q", ErrInvalidListQuery, name)
+		}
+		conditions, err := parse(strings.Join(values[name], ","))
+		if err != nil {
+			return query, fmt.Errorf("%w: %s: %v", ErrInvalidListQuery, name, err)
+		}
+		query.Conditions = append(query.Conditions, conditions...)
+	}
+
+	return query, nil
+}
+
+// Matches a comma separated list of IDs in any of the fields
+func idFilter(fields ...string) FilterParser {
+	return func(value string) ([]Condition, error) {
+		condition := Condition{Fields: fields}
+		for _, hex := range strings.Split(value, ",") {
+			id, err := primitive.ObjectIDFromHex(strings.TrimSpace(hex))
+			if err != nil {
+				return nil, fmt.Errorf("%q isn't an ID", hex)
+			}
+			condition.In = append(condition.In, id)
+		}
+		return []Condition{condition}, nil
+	}
+}
+
+// Matches a comma separated list of values in any of the fields
+func stringFilter(fields ...string) FilterParser {
+	return func(value string) ([]Condition, error) {
+		condition := Condition{Fields: fields}
+		for _, option := range strings.Split(value, ",") {
+			condition.In = append(condition.In, strings.TrimSpace(option))
+		}
+		return []Condition{condition}, nil
+	}
+}
+
+// Matches true or false
+func boolFilter(field string) FilterParser {
+	return func(value string) ([]Condition, error) {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("must be true or false")
+		}
+		return []Condition{{Fields: []string{field}, In: []interface{}{b}}}, nil
+	}
+}
+
+// Matches a field on or after an RFC 3339 time, or the start of a YYYY-MM-DD date in UTC
+func fromFilter(field string) FilterParser {
+	return func(value string) ([]Condition, error) {
+		from, err := parseListTime(value, false)
+		if err != nil {
+			return nil, err
+		}
+		return []Condition{{Fields: []string{field}, From: from}}, nil
+	}
+}
+
+// Matches a field on or before an RFC 3339 time, or the end of a YYYY-MM-DD date in UTC
+func toFilter(field string) FilterParser {
+	return func(value string) ([]Condition, error) {
+		to, err := parseListTime(value, true)
+		if err != nil {
+			return nil, err
+		}
+		return []Condition{{Fields: []string{field}, To: to}}, nil
+	}
+}
+
+func parseListTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return UTC(t), nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Millisecond), nil
+	}
+	return day, nil
+}
+
+// Matches one of a set of named statuses, each worked out from the current time
+func statusFilter(statuses map[string]func(now time.Time) []Condition) FilterParser {
+	return func(value string) ([]Condition, error) {
+		conditions, ok := statuses[value]
+		if !ok {
+			names := make([]string, 0, len(statuses))
+			for name := range statuses {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("must be one of %s", strings.Join(names, ", "))
+		}
+		return conditions(utcNow()), nil
+	}
+}
+
+// The field the query sorts by and whether it sorts descending
+func (q ListQuery) sortField() (string, bool) {
+	return strings.TrimPrefix(q.Sort, "-"), strings.HasPrefix(q.Sort, "-")
+}
+
+// Where a page starts: just after the document with this sort value and ID
+type listCursor struct {
+	Sort  string             `bson:"s"`
+	Value bson.RawValue      `bson:"v"`
+	ID    primitive.ObjectID `bson:"id"`
+}
+
+// Decodes the query's cursor, nil if there is none
+func (q ListQuery) after() (*listCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil || bson.Raw(data).Validate() != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor listCursor
+	if err := bson.Unmarshal(data, &cursor); err != nil || cursor.Sort != q.Sort || cursor.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+
+	// The value goes into the filter as it is, so anything but a plain value is refused
+	// rather than letting a client send operators or expressions
+	if _, ok := cursorValueTypes[cursor.Value.Type]; !ok {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// The types a sort value can have
+var cursorValueTypes = map[bsontype.Type]struct{}{
+	bsontype.String:   {},
+	bsontype.DateTime: {},
+	bsontype.Int32:    {},
+	bsontype.Int64:    {},
+	bsontype.Double:   {},
+	bsontype.ObjectID: {},
+	bsontype.Null:     {},
+}
+
+// Makes the cursor for the page after the given document
+func (q ListQuery) cursorAfter(document bson.Raw) (string, error) {
+	field, _ := q.sortField()
+	value := sortValue(document, field)
+	id, _ := document.Lookup("_id").ObjectIDOK()
+
+	data, err := bson.Marshal(listCursor{Sort: q.Sort, Value: value, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// The value a document is sorted by, null if it isn't set
+func sortValue(document bson.Raw, field string) bson.RawValue {
+	values := fieldValues(document, field)
+	if len(values) == 0 {
+		return bson.RawValue{Type: bsontype.Null}
+	}
+	return values[0]
+}
+
+// The query's conditions added to a base filter
+func (q ListQuery) filter(base bson.M) bson.M {
+	and := bson.A{base}
+	for _, condition := range q.Conditions {
+		and = append(and, condition.filter())
+	}
+	return bson.M{"$and": and}
+}
+
+func (c Condition) filter() bson.M {
+	clauses := bson.A{}
+	for _, field := range c.Fields {
+		// null matches fields that aren't set as well as ones set to null
+		if c.Missing {
+			clauses = append(clauses, bson.M{field: nil})
+			continue
+		}
+
+		match := bson.M{}
+		if len(c.In) > 0 {
+			match["$in"] = c.In
+		}
+		if !c.From.IsZero() {
+			match["$gte"] = c.From
+		}
+		if !c.To.IsZero() {
+			match["$lte"] = c.To
+		}
+		clauses = append(clauses, bson.M{field: match})
+	}
+
+	if len(clauses) == 1 {
+		return clauses[0].(bson.M)
+	}
+	return bson.M{"$or": clauses}
+}
+
+// Documents after the cursor in the query's order. Documents without the sort field come
+// first, as MongoDB sorts them.
+func (q ListQuery) afterFilter(after *listCursor) bson.M {
+	field, descending := q.sortField()
+	op := "$gt"
+	if descending {
+		op = "$lt"
+	}
+
+	if after.Value.Type == bsontype.Null {
+		sameValue := bson.M{field: nil, "_id": bson.M{op: after.ID}}
+		if descending {
+			return sameValue
+		}
+		return bson.M{"$or": bson.A{sameValue, bson.M{field: bson.M{"$ne": nil}}}}
+	}
+
+	clauses := bson.A{
+		bson.M{field: bson.M{op: after.Value}},
+		bson.M{field: after.Value, "_id": bson.M{op: after.ID}},
+	}
+	if descending {
+		clauses = append(clauses, bson.M{field: nil})
+	}
+	return bson.M{"$or": clauses}
+}
+
+// Gets a page of the documents in a collection that match a base filter and the query
+func findPage[T any](c context.Context, collection *mongo.Collection, base bson.M, query ListQuery) (*Page[T], error) {
+	after, err := query.after()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := query.filter(base)
+	total, err := collection.CountDocuments(c, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if after != nil {
+		filter = bson.M{"$and": bson.A{filter, query.afterFilter(after)}}
+	}
+
+	field, descending := query.sortField()
+	direction := 1
+	if descending {
+		direction = -1
+	}
+	// One more than the page holds, to tell whether there is another page
+	opts := options.Find().
+		SetSort(bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(query.Limit) + 1)
+
+	cursor, err := collection.Find(c, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(c)
+
+	page := &Page[T]{Items: []*T{}, Total: total}
+	var last bson.Raw
+	for cursor.Next(c) {
+		if len(page.Items) == query.Limit {
+			if page.NextCursor, err = query.cursorAfter(last); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, &item)
+		last = append(bson.Raw(nil), cursor.Current...)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// Gets a page of in-memory documents that match the query, by encoding them as they would
+// be stored and applying the query the way MongoDB would
+func listPage[T any](items []*T, query ListQuery) (*Page[T], error) {
+	after, err := query.after()
+	if err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		item     *T
+		document bson.Raw
+	}
+
+	var entries []entry
+	for _, item := range items {
+		document, err := bson.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		if query.matches(document) {
+			entries = append(entries, entry{item, document})
+		}
+	}
+
+	field, descending := query.sortField()
+	order := func(a, b bson.Raw) int {
+		if compared := compareValues(sortValue(a, field), sortValue(b, field)); compared != 0 {
+			return compared
+		}
+		return compareValues(a.Lookup("_id"), b.Lookup("_id"))
+	}
+	if descending {
+		ascending := order
+		order = func(a, b bson.Raw) int { return -ascending(a, b) }
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return order(entries[i].document, entries[j].document) < 0
+	})
+
+	page := &Page[T]{Items: []*T{}, Total: int64(len(entries))}
+	var last bson.Raw
+	for _, entry := range entries {
+		if after != nil {
+			id, _ := entry.document.Lookup("_id").ObjectIDOK()
+			position := compareValues(sortValue(entry.document, field), after.Value)
+			if position == 0 {
+				position = bytes.Compare(id[:], after.ID[:])
+			}
+			if descending {
+				position = -position
+			}
+			if position <= 0 {
+				continue
+			}
+		}
+
+		if len(page.Items) == query.Limit {
+			if page.NextCursor, err = query.cursorAfter(last); err != nil {
+				return nil, err
+			}
+			break
+		}
+		page.Items = append(page.Items, entry.item)
+		last = entry.document
+	}
+
+	return page, nil
+}
+
+// Reports whether a stored document meets all the query's conditions
+func (q ListQuery) matches(document bson.Raw) bool {
+	for _, condition := range q.Conditions {
+		if !condition.matches(document) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) matches(document bson.Raw) bool {
+	for _, field := range c.Fields {
+		values := fieldValues(document, field)
+
+		if c.Missing {
+			if len(values) == 0 || (len(values) == 1 && values[0].Type == bsontype.Null) {
+				return true
+			}
+			continue
+		}
+
+		for _, value := range values {
+			if c.valueMatches(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c Condition) valueMatches(value bson.RawValue) bool {
+	if len(c.In) > 0 {
+		found := false
+		for _, option := range c.In {
+			optionType, optionData, err := bson.MarshalValue(option)
+			if err == nil && optionType == value.Type && bytes.Equal(optionData, value.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if !c.From.IsZero() || !c.To.IsZero() {
+		date, ok := value.DateTimeOK()
+		if !ok {
+			return false
+		}
+		if !c.From.IsZero() && date < c.From.UnixMilli() {
+			return false
+		}
+		if !c.To.IsZero() && date > c.To.UnixMilli() {
+			return false
+		}
+	}
+	return true
+}
+
+// The values at a dotted path in a document, looking into arrays along the way as MongoDB does
+func fieldValues(document bson.Raw, path string) []bson.RawValue {
+	name, rest, nested := strings.Cut(path, ".")
+	value, err := document.LookupErr(name)
+	if err != nil {
+		return nil
+	}
+
+	if !nested {
+		if array, ok := value.ArrayOK(); ok {
+			elements, _ := array.Values()
+			return elements
+		}
+		return []bson.RawValue{value}
+	}
+
+	if sub, ok := value.DocumentOK(); ok {
+		return fieldValues(sub, rest)
+	}
+
+	var values []bson.RawValue
+	if array, ok := value.ArrayOK(); ok {
+		elements, _ := array.Values()
+		for _, element := range elements {
+			if sub, ok := element.DocumentOK(); ok {
+				values = append(values, fieldValues(sub, rest)...)
+			}
+		}
+	}
+	return values
+}
+
+// Orders two stored values the way they are sorted, with unset and null values first
+func compareValues(a, b bson.RawValue) int {
+	aNull := a.Type == 0 || a.Type == bsontype.Null
+	bNull := b.Type == 0 || b.Type == bsontype.Null
+	switch {
+	case aNull && bNull:
+		return 0
+	case aNull:
+		return -1
+	case bNull:
+		return 1
+	case a.Type != b.Type:
+		return int(a.Type) - int(b.Type)
+	}
+
+	switch a.Type {
+	case bsontype.String:
+		return strings.Compare(a.StringValue(), b.StringValue())
+	case bsontype.DateTime:
+		return compareInts(a.DateTime(), b.DateTime())
+	case bsontype.Int32, bsontype.Int64:
+		return compareInts(a.AsInt64(), b.AsInt64())
+	case bsontype.Boolean:
+		return compareInts(boolInt(a.Boolean()), boolInt(b.Boolean()))
+	default:
+		return bytes.Compare(a.Value, b.Value)
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
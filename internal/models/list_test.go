@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorsOnlyCarryPlainValues(t *testing.T) {
+	query := ListQuery{Sort: "name"}
+	cursor := func(value interface{}) string {
+		data, err := bson.Marshal(bson.M{"s": query.Sort, "v": value, "id": primitive.NewObjectID()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	for _, value := range []interface{}{"Major", time.Now(), int32(1), int64(1), 1.5, primitive.NewObjectID(), nil} {
+		query.Cursor = cursor(value)
+		if _, err := query.after(); err != nil {
+			t.Errorf("cursor after %v refused: %v", value, err)
+		}
+	}
+
+	for _, value := range []interface{}{
+		bson.M{"$ne": nil},
+		bson.A{"Major"},
+		primitive.Regex{Pattern: ".*"},
+		primitive.JavaScript("sleep(1000)"),
+		true,
+	} {
+		query.Cursor = cursor(value)
+		if _, err := query.after(); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor after %v: got %v, want %v", value, err, ErrInvalidCursor)
+		}
+	}
+
+	// The cursors made for a page are accepted back
+	document, err := bson.Marshal(bson.M{"_id": primitive.NewObjectID(), "name": "Major"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Cursor, err = query.cursorAfter(document); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := query.after(); err != nil {
+		t.Errorf("cursor for the next page refused: %v", err)
+	}
+}
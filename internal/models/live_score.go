@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Game modes that can be live scored
+const (
+	ModeHardpoint        = "hardpoint"
+	ModeSearchAndDestroy = "search_and_destroy"
+	ModeControl          = "control"
+)
+
+// Score limits for each mode
+const (
+	hardpointScoreLimit        = 250
+	searchAndDestroyRoundLimit = 6
+	controlRoundLimit          = 3
+	controlLivesPerRound       = 30
+)
+
+var (
+	ErrLiveScoreNotFound = errors.New("No live score for this match")
+	ErrMapResultExists   = errors.New("A result for this map has already been recorded")
+)
+
+// The in-progress state of the map currently being played in a match
+type LiveScore struct {
+	MatchID      primitive.ObjectID `bson:"match_id" json:"match_id"`
+	TournamentID primitive.ObjectID `bson:"tournament_id,omitempty" json:"tournament_id"`
+	MapNumber    int                `bson:"map_number" json:"map_number" binding:"required,min=1"`
+	MapName      string             `bson:"map_name" json:"map_name"`
+	Mode         string             `bson:"mode" json:"mode" binding:"required"`
+	Team1Score   int                `bson:"team1_score" json:"team1_score" binding:"min=0"`
+	Team2Score   int                `bson:"team2_score" json:"team2_score" binding:"min=0"`
+	Hill         int                `bson:"hill,omitempty" json:"hill,omitempty"`
+	Round        int                `bson:"round,omitempty" json:"round,omitempty"`
+	Team1Lives   int                `bson:"team1_lives,omitempty" json:"team1_lives,omitempty"`
+	Team2Lives   int                `bson:"team2_lives,omitempty" json:"team2_lives,omitempty"`
+	PlayerStats  []PlayerStatLine   `bson:"player_stats" json:"player_stats"`
+	Final        bool               `bson:"final" json:"final"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// The final score of a single map in a match
+type MapResult struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MatchID     primitive.ObjectID `bson:"match_id" json:"match_id"`
+	OrganiserID primitive.ObjectID `bson:"organiser_id" json:"organiser_id"`
+	MapNumber   int                `bson:"map_number" json:"map_number"`
+	MapName     string             `bson:"map_name" json:"map_name"`
+	Mode        string             `bson:"mode" json:"mode"`
+	Team1Score  int                `bson:"team1_score" json:"team1_score"`
+	Team2Score  int                `bson:"team2_score" json:"team2_score"`
+	WinnerID    primitive.ObjectID `bson:"winner_id" json:"winner_id"`
+	PlayerStats []PlayerStatLine   `bson:"player_stats" json:"player_stats"`
+}
+
+// A player's stats for a map, or for a series when summed across maps
+type PlayerStatLine struct {
+	Player  string             `bson:"player" json:"player"`
+	TeamID  primitive.ObjectID `bson:"team_id" json:"team_id"`
+	Kills   int                `bson:"kills" json:"kills"`
+	Deaths  int                `bson:"deaths" json:"deaths"`
+	Assists int                `bson:"assists" json:"assists"`
+	Damage  int                `bson:"damage" json:"damage"`
+}
+
+// Checks the score makes sense for the mode being played in the match, and that every
+// player's stats are for one of its teams
+func (s *LiveScore) Validate(match *Match) error {
+	for _, stats := range s.PlayerStats {
+		if stats.TeamID != match.Team1ID && stats.TeamID != match.Team2ID {
+			return fmt.Errorf("Player stats for %s must be for one of the match's teams", stats.Player)
+		}
+	}
+
+	switch s.Mode {
+	case ModeHardpoint:
+		if s.Team1Score > hardpointScoreLimit || s.Team2Score > hardpointScoreLimit {
+			return errors.New("Hardpoint scores cannot exceed 250")
+		}
+		if s.Hill < 1 {
+			return errors.New("Hardpoint updates must include the current hill")
+		}
+	case ModeSearchAndDestroy:
+		if s.Team1Score > searchAndDestroyRoundLimit || s.Team2Score > searchAndDestroyRoundLimit {
+			return errors.New("Search and Destroy round wins cannot exceed 6")
+		}
+	case ModeControl:
+		if s.Team1Score > controlRoundLimit || s.Team2Score > controlRoundLimit {
+			return errors.New("Control round wins cannot exceed 3")
+		}
+		if s.Team1Lives < 0 || s.Team2Lives < 0 || s.Team1Lives > controlLivesPerRound || s.Team2Lives > controlLivesPerRound {
+			return errors.New("Control lives remaining must be between 0 and 30")
+		}
+	default:
+		return errors.New("Mode must be hardpoint, search_and_destroy or control")
+	}
+
+	if s.Final && s.Team1Score == s.Team2Score {
+		return errors.New("A final score cannot be a draw")
+	}
+
+	return nil
+}
+
+// Message broadcast to match subscribers for each live update
+func (s *LiveScore) Message() map[string]interface{} {
+	return map[string]interface{}{
+		"action":        "live_score_updated",
+		"match_id":      s.MatchID.Hex(),
+		"tournament_id": s.TournamentID.Hex(),
+		"map_number":    s.MapNumber,
+		"map_name":      s.MapName,
+		"mode":          s.Mode,
+		"team1_score":   s.Team1Score,
+		"team2_score":   s.Team2Score,
+		"hill":          s.Hill,
+		"round":         s.Round,
+		"team1_lives":   s.Team1Lives,
+		"team2_lives":   s.Team2Lives,
+		"player_stats":  s.PlayerStats,
+		"final":         s.Final,
+	}
+}
+
+// Stores the latest live state for a match, replacing the previous update
+func SaveLiveScore(c context.Context, scores LiveScoreRepository, score *LiveScore) error {
+	score.UpdatedAt = time.Now().UTC()
+	return scores.Save(c, score)
+}
+
+// Message broadcast when a map's result is recorded
+func (r *MapResult) createdMessage(match *Match) map[string]interface{} {
+	return map[string]interface{}{
+		"action":        "map_result_created",
+		"map_result_id": r.ID.Hex(),
+		"match_id":      match.ID.Hex(),
+		"tournament_id": match.TournamentID.Hex(),
+		"map_number":    r.MapNumber,
+		"map_name":      r.MapName,
+		"mode":          r.Mode,
+		"team1_score":   r.Team1Score,
+		"team2_score":   r.Team2Score,
+		"winner_id":     r.WinnerID.Hex(),
+	}
+}
+
+// Stores the final live update for a map along with the map result it becomes. Both are
+// broadcast through the outbox once stored, so subscribers never see a final score that
+// wasn't recorded. A map only has one result, so a repeated final update is refused and
+// the first one is left in place.
+func SaveFinalLiveScore(c context.Context, scores LiveScoreRepository, match *Match, score *LiveScore) (*MapResult, error) {
+	score.UpdatedAt = time.Now().UTC()
+
+	mapResult := &MapResult{
+		MatchID:     match.ID,
+		OrganiserID: match.OrganiserID,
+		MapNumber:   score.MapNumber,
+		MapName:     score.MapName,
+		Mode:        score.Mode,
+		Team1Score:  score.Team1Score,
+		Team2Score:  score.Team2Score,
+		WinnerID:    match.Team1ID,
+		PlayerStats: score.PlayerStats,
+	}
+	if score.Team2Score > score.Team1Score {
+		mapResult.WinnerID = match.Team2ID
+	}
+
+	if err := scores.SaveFinal(c, match, score, mapResult); err != nil {
+		return nil, err
+	}
+
+	return mapResult, nil
+}
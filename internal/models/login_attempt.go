@@ -0,0 +1,157 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Failed logins allowed before an email address or IP address is locked out
+const (
+	maxAccountLoginFailures = 5
+	maxIPLoginFailures      = 20
+)
+
+// Lockouts start short and double with every further failure, up to the maximum
+const (
+	baseLoginLockout = 30 * time.Second
+	maxLoginLockout  = 30 * time.Minute
+)
+
+// Failures older than this are forgotten
+const loginFailureWindow = time.Hour
+
+// Failed logins for an email address or IP address, keyed by "email:<email>" or "ip:<ip>"
+type LoginAttempt struct {
+	Key           string     `bson:"_id"`
+	Failures      int        `bson:"failures"`
+	LastFailureAt time.Time  `bson:"last_failure_at"`
+	LockedUntil   *time.Time `bson:"locked_until,omitempty"`
+}
+
+// Returned while an email address or IP address is locked out. It reads as invalid
+// credentials so a lockout doesn't reveal whether the email address is registered.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return ErrInvalidCredentials.Error()
+}
+
+func (e *LoginLockedError) Is(target error) bool {
+	return target == ErrInvalidCredentials
+}
+
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
+// Compares a password against a throwaway hash, so logins for unknown email
+// addresses take as long as logins with a wrong password
+func compareDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword(secret, bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
+func accountLoginKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Returns how long until the longest lockout on any of the keys ends, or 0 if none are locked out
+func loginLockRemaining(c context.Context, attempts LoginAttemptRepository, keys ...string) (time.Duration, error) {
+	locked, err := attempts.ListLocked(c, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	var remaining time.Duration
+	for _, attempt := range locked {
+		if wait := attempt.LockedUntil.Sub(now); wait > remaining {
+			remaining = wait
+		}
+	}
+
+	return remaining, nil
+}
+
+// Counts a failed login against a key and locks it out once it has failed too many times.
+// Returns the new lockout, or 0 if the key is not locked out.
+func recordLoginFailure(c context.Context, attempts LoginAttemptRepository, key string, maxFailures int) (time.Duration, error) {
+	// Failures outside the window start the count again
+	attempt, err := attempts.RecordFailure(c, key, loginFailureWindow)
+	if err != nil {
+		return 0, err
+	}
+
+	if attempt.Failures < maxFailures {
+		return 0, nil
+	}
+
+	lockout := maxLoginLockout
+	if doublings := attempt.Failures - maxFailures; doublings < 16 {
+		if backoff := baseLoginLockout << doublings; backoff < maxLoginLockout {
+			lockout = backoff
+		}
+	}
+
+	if err := attempts.Lock(c, key, attempt.LastFailureAt.Add(lockout)); err != nil {
+		return 0, err
+	}
+
+	return lockout, nil
+}
+
+// Records a failed login for the email address and IP address, locking either out
+// if they have failed too often and writing the lockout to the audit log
+func handleLoginFailure(c context.Context, repos *Repositories, email, ip string, userID *primitive.ObjectID) error {
+	accountLockout, err := recordLoginFailure(c, repos.LoginAttempts, accountLoginKey(email), maxAccountLoginFailures)
+	if err != nil {
+		return err
+	}
+	if accountLockout > 0 {
+		entry := &AuditLog{
+			Action:    AuditActionAccountLockedOut,
+			UserID:    userID,
+			Email:     strings.ToLower(strings.TrimSpace(email)),
+			IPAddress: ip,
+			Details:   map[string]interface{}{"lockout_seconds": int(accountLockout.Seconds())},
+		}
+		if err := RecordAuditLog(c, repos.AuditLogs, entry); err != nil {
+			log.Println("could not record account lockout:", err)
+		}
+	}
+
+	ipLockout, err := recordLoginFailure(c, repos.LoginAttempts, ipLoginKey(ip), maxIPLoginFailures)
+	if err != nil {
+		return err
+	}
+	if ipLockout > 0 {
+		entry := &AuditLog{
+			Action:    AuditActionIPLockedOut,
+			IPAddress: ip,
+			Details:   map[string]interface{}{"lockout_seconds": int(ipLockout.Seconds())},
+		}
+		if err := RecordAuditLog(c, repos.AuditLogs, entry); err != nil {
+			log.Println("could not record IP lockout:", err)
+		}
+	}
+
+	return nil
+}
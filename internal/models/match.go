@@ -1,13 +1,10 @@
 package models
 
 import (
-	"encoding/json"
-	"errors"
-	"log"
+	"context"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
-	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -17,114 +14,124 @@ type Match struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty"`
 	TournamentID primitive.ObjectID `bson:"tournament_id,omitempty"`
 	OrganiserID  primitive.ObjectID `bson:"organiser_id" binding:"required"`
-	Team1ID      primitive.ObjectID `bson:"team1_id" binding:"required"`
-	Team2ID      primitive.ObjectID `bson:"team2_id" binding:"required"`
-	Date         string             `bson:"date"`
-	Team1Name    string             `bson:"team1_name"`
-	Team2Name    string             `bson:"team2_name"`
-	WebSocketHub *realtimemanager.WebSocketHub
+	// Organisation that owns the match, empty for matches created before organisations
+	OrganisationID primitive.ObjectID `bson:"organisation_id,omitempty"`
+	Team1ID        primitive.ObjectID `bson:"team1_id" binding:"required"`
+	Team2ID        primitive.ObjectID `bson:"team2_id" binding:"required"`
+	// When the match is scheduled, not set until it is
+	Date       time.Time            `bson:"date,omitempty"`
+	Team1Name  string               `bson:"team1_name"`
+	Team2Name  string               `bson:"team2_name"`
+	Vetoes     []MapVeto            `bson:"vetoes"`
+	RefereeIDs []primitive.ObjectID `bson:"referee_ids"`
+	// Incremented on every update
+	Version    int64 `bson:"version"`
+	Timestamps `bson:",inline"`
+	Deletion   `bson:",inline"`
 }
 
-// add a team to a tournament
-func AddMatchToTournament(c *gin.Context, matchID, tournamentID primitive.ObjectID) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournament")
-
-	update := bson.M{"$push": bson.M{"match": matchID}}
+// The filters and sorts for listing matches. from and to are on when they are scheduled,
+// and mode finds matches with a map picked or banned in that game mode.
+var MatchListSchema = ListSchema{
+	Filters: map[string]FilterParser{
+		"status":     statusFilter(matchStatuses),
+		"tournament": idFilter("tournament_id"),
+		"team":       idFilter("team1_id", "team2_id"),
+		"mode":       stringFilter("vetoes.mode"),
+		"from":       fromFilter("date"),
+		"to":         toFilter("date"),
+	},
+	Sorts: map[string]string{
+		"date":       "date",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "date",
+}
 
-	_, err := collection.UpdateOne(c, bson.M{"_id": tournamentID}, update)
-	if err != nil {
-		return err
-	}
+// A match is unscheduled until it has a date, then upcoming until that date passes
+var matchStatuses = map[string]func(now time.Time) []Condition{
+	"unscheduled": func(now time.Time) []Condition {
+		return []Condition{{Fields: []string{"date"}, Missing: true}}
+	},
+	"upcoming": func(now time.Time) []Condition {
+		return []Condition{{Fields: []string{"date"}, From: now}}
+	},
+	"past": func(now time.Time) []Condition {
+		return []Condition{{Fields: []string{"date"}, To: now.Add(-time.Millisecond)}}
+	},
+}
 
-	return nil
+// A map and mode picked or banned by a team before the match
+type MapVeto struct {
+	TeamID  primitive.ObjectID `bson:"team_id" json:"team_id"`
+	Action  string             `bson:"action" json:"action"`
+	MapName string             `bson:"map_name" json:"map_name"`
+	Mode    string             `bson:"mode" json:"mode"`
 }
 
 // - CreateMatch
-func CreateMatch(c *gin.Context, match *Match) (*Match, error) {
+func CreateMatch(c context.Context, match *Match) (*Match, error) {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("matches")
 
-	// retrieve team names based on Team1ID and Team2ID from the database
-	team1, err := GetTeamByID(c, match.Team1ID)
-	if err != nil {
-		return nil, err
-	}
+	match.Version = 1
+	match.created()
 
-	team2, err := GetTeamByID(c, match.Team2ID)
-	if err != nil {
-		return nil, err
-	}
+	// Insert the match and record its event together so one never exists without the other
+	err := withTransaction(c, func(sc mongo.SessionContext) error {
+		result, err := collection.InsertOne(sc, match)
+		if err != nil {
+			return err
+		}
 
-	match.Team1Name = team1.Name
-	match.Team2Name = team2.Name
+		match.ID = result.InsertedID.(primitive.ObjectID)
 
-	result, err := collection.InsertOne(c, match)
-	if err != nil {
-		return nil, err
-	}
+		// Join the tournament in the same transaction, so a missing tournament rolls back the insert
+		if !match.TournamentID.IsZero() {
+			if err := addToTournament(sc, "matches", match.TournamentID, match.ID); err != nil {
+				return err
+			}
+		}
 
-	match.ID = result.InsertedID.(primitive.ObjectID)
-
-	// Construct the WebSocket message for match creation
-	message := map[string]interface{}{
-		"action":        "match_created",
-		"match_id":      match.ID.Hex(),
-		"tournament_id": match.TournamentID.Hex(),
-		"team1_id":      match.Team1ID.Hex(),
-		"team2_id":      match.Team2ID.Hex(),
-		"date":          match.Date,
-		"team1_name":    match.Team1Name,
-		"team2_name":    match.Team2Name,
-	}
+		// Construct the WebSocket message for match creation
+		message := map[string]interface{}{
+			"action":        "match_created",
+			"match_id":      match.ID.Hex(),
+			"tournament_id": match.TournamentID.Hex(),
+			"team1_id":      match.Team1ID.Hex(),
+			"team2_id":      match.Team2ID.Hex(),
+			"date":          eventTime(match.Date),
+			"team1_name":    match.Team1Name,
+			"team2_name":    match.Team2Name,
+			"vetoes":        match.Vetoes,
+			"version":       match.Version,
+		}
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(message)
+		return recordOutboxEvent(sc, message)
+	})
 	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
-		return match, nil
+		return nil, err
 	}
 
-	log.Printf("Broadcasting WebSocket message: %s", messageJSON)
-	// Broadcast the message to WebSocket clients
-	match.WebSocketHub.Broadcast(messageJSON)
-
 	return match, nil
 }
 
-// Get Matches by organiserID
-func GetMatchesByOrganiserID(c *gin.Context, organiserID primitive.ObjectID) ([]*Match, error) {
+// Gets a page of the matches a user can see
+func GetMatches(c context.Context, scope OwnerScope, query ListQuery) (*Page[Match], error) {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("matches")
 
-	cursor, err := collection.Find(c, bson.M{"organiser_id": organiserID})
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(c)
-
-	var matches []*Match
-	for cursor.Next(c) {
-		var match Match
-		if err := cursor.Decode(&match); err != nil {
-			return nil, err
-		}
-		matches = append(matches, &match)
-	}
-
-	if err := cursor.Err(); err != nil {
-		return nil, err
-	}
-
-	return matches, nil
+	return findPage[Match](c, collection, notDeleted(scope.filter()), query)
 }
 
 // - GetMatchByID
-func GetMatchByID(c *gin.Context, id primitive.ObjectID) (*Match, error) {
+func GetMatchByID(c context.Context, id primitive.ObjectID) (*Match, error) {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("matches")
 
 	var match Match
-	err := collection.FindOne(c, bson.M{"_id": id}).Decode(&match)
+	err := collection.FindOne(c, notDeleted(bson.M{"_id": id})).Decode(&match)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("Match not found")
+			return nil, ErrMatchNotFound
 		}
 		return nil, err
 	}
@@ -132,49 +139,70 @@ func GetMatchByID(c *gin.Context, id primitive.ObjectID) (*Match, error) {
 	return &match, nil
 }
 
-// - UpdateMatch
-func UpdateMatch(c *gin.Context, id primitive.ObjectID, updatedMatch *Match) error {
+// Updates a match if it is still at updatedMatch.Version, which is then incremented
+func UpdateMatch(c context.Context, id primitive.ObjectID, updatedMatch *Match) error {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("matches")
 
-	update := bson.M{"$set": updatedMatch}
-	_, err := collection.UpdateOne(c, bson.M{"_id": id}, update)
-	if err != nil {
-		return err
-	}
+	expectedVersion := updatedMatch.Version
+	err := withTransaction(c, func(sc mongo.SessionContext) error {
+		updatedMatch.Version = expectedVersion + 1
+		updatedMatch.updated()
+		update := bson.M{"$set": updatedMatch}
+		// A zero date is left out of $set, so unscheduling a match has to unset it
+		if updatedMatch.Date.IsZero() {
+			update["$unset"] = bson.M{"date": ""}
+		}
+		var previous Match
+		err := collection.FindOneAndUpdate(sc, versionFilter(notDeleted(bson.M{"_id": id}), expectedVersion), update).Decode(&previous)
+		if err != nil {
+			// Nothing changed, so there is nothing to tell clients about
+			if err == mongo.ErrNoDocuments {
+				return versionMismatch(sc, "matches", id, ErrMatchNotFound)
+			}
+			return err
+		}
 
-	// Construct the WebSocket message for match update
-	message := map[string]interface{}{
-		"action":        "match_updated",
-		"match_id":      id.Hex(),
-		"tournament_id": updatedMatch.TournamentID.Hex(),
-		"team1_id":      updatedMatch.Team1ID.Hex(),
-		"team2_id":      updatedMatch.Team2ID.Hex(),
-		"date":          updatedMatch.Date,
-		"team1_name":    updatedMatch.Team1Name,
-		"team2_name":    updatedMatch.Team2Name,
-	}
+		// Move it between tournaments in the same transaction. An empty tournament isn't set, so it stays put.
+		if !updatedMatch.TournamentID.IsZero() {
+			if err := moveBetweenTournaments(sc, "matches", id, previous.TournamentID, updatedMatch.TournamentID); err != nil {
+				return err
+			}
+		}
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(message)
+		// Construct the WebSocket message for match update
+		message := map[string]interface{}{
+			"action":        "match_updated",
+			"match_id":      id.Hex(),
+			"tournament_id": updatedMatch.TournamentID.Hex(),
+			"team1_id":      updatedMatch.Team1ID.Hex(),
+			"team2_id":      updatedMatch.Team2ID.Hex(),
+			"date":          eventTime(updatedMatch.Date),
+			"team1_name":    updatedMatch.Team1Name,
+			"team2_name":    updatedMatch.Team2Name,
+			"vetoes":        updatedMatch.Vetoes,
+			"version":       updatedMatch.Version,
+		}
+
+		return recordOutboxEvent(sc, message)
+	})
 	if err != nil {
-		return err
+		updatedMatch.Version = expectedVersion
 	}
 
-	log.Printf("Broadcasting WebSocket message: %s", messageJSON)
-	// Broadcast the message to WebSocket clients
-	updatedMatch.WebSocketHub.Broadcast(messageJSON)
-
-	return nil
+	return err
 }
 
-// - DeleteMatch
-func DeleteMatch(c *gin.Context, id primitive.ObjectID) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("matches")
+// Soft deletes a match, cascading to its results by the rules
+func DeleteMatch(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return softDelete(c, "matches", id, rules, ErrMatchNotFound)
+}
 
-	_, err := collection.DeleteOne(c, bson.M{"_id": id})
-	if err != nil {
-		return err
-	}
+// Gets a soft deleted match
+func GetDeletedMatchByID(c context.Context, id primitive.ObjectID) (*Match, error) {
+	return getDeleted[Match](c, "matches", id, ErrMatchNotFound)
+}
 
-	return nil
+// Restores a soft deleted match along with everything deleted with it
+func RestoreMatch(c context.Context, id primitive.ObjectID) error {
+	return restoreDeleted(c, "matches", id, ErrMatchNotFound)
 }
@@ -1,102 +1,108 @@
 package models
 
 import (
-	"encoding/json"
-	"errors"
-	"log"
+	"context"
 
-	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
-	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type MatchResult struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty"`
-	MatchID      primitive.ObjectID `bson:"match_id" binding:"required"`
-	OrganiserID  primitive.ObjectID `bson:"organiser_id" binding:"required"`
-	WinnerID     primitive.ObjectID `bson:"winner_id"`
-	LoserID      primitive.ObjectID `bson:"loser_id"`
-	WinnerScore  int                `bson:"winner_score"`
-	LoserScore   int                `bson:"loser_score"`
-	WebSocketHub *realtimemanager.WebSocketHub
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	MatchID     primitive.ObjectID `bson:"match_id" binding:"required"`
+	OrganiserID primitive.ObjectID `bson:"organiser_id" binding:"required"`
+	// Organisation that owns the result, always the same as its match's
+	OrganisationID primitive.ObjectID `bson:"organisation_id,omitempty"`
+	WinnerID       primitive.ObjectID `bson:"winner_id"`
+	LoserID        primitive.ObjectID `bson:"loser_id"`
+	WinnerScore    int                `bson:"winner_score"`
+	LoserScore     int                `bson:"loser_score"`
+	// Incremented on every update
+	Version    int64 `bson:"version"`
+	Timestamps `bson:",inline"`
+	Deletion   `bson:",inline"`
+}
+
+// The filters and sorts for listing match results, newest first. from and to are on when
+// they were recorded.
+var MatchResultListSchema = ListSchema{
+	Filters: map[string]FilterParser{
+		"match": idFilter("match_id"),
+		"team":  idFilter("winner_id", "loser_id"),
+		"from":  fromFilter("created_at"),
+		"to":    toFilter("created_at"),
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "-created_at",
 }
 
 // MatchResult-related functions
 // - CreateMatchResult
-func CreateMatchResult(c *gin.Context, matchResult *MatchResult) (*MatchResult, error) {
+func CreateMatchResult(c context.Context, matchResult *MatchResult) (*MatchResult, error) {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("match_results")
 
-	result, err := collection.InsertOne(c, matchResult)
-	if err != nil {
-		return nil, err
+	// Look up the tournament so tournament subscribers also receive the result
+	var tournamentID string
+	if match, err := GetMatchByID(c, matchResult.MatchID); err == nil {
+		tournamentID = match.TournamentID.Hex()
 	}
 
-	matchResult.ID = result.InsertedID.(primitive.ObjectID)
-
-	// Construct and broadcast the WebSocket message
-	message := map[string]interface{}{
-		"action":          "match_result_created",
-		"match_result_id": matchResult.ID.Hex(),
-		"match_id":        matchResult.MatchID.Hex(),
-		"organiser_id":    matchResult.OrganiserID.Hex(),
-		"winner_id":       matchResult.WinnerID.Hex(),
-		"loser_id":        matchResult.LoserID.Hex(),
-		"winner_score":    matchResult.WinnerScore,
-		"loser_sccore":    matchResult.LoserScore,
-	}
+	matchResult.Version = 1
+	matchResult.created()
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
-		return matchResult, nil
-	}
-	
-	log.Printf("Broadcasting WebSocket message: %s", messageJSON)
-	// Broadcast the message to WebSocket clients
-	matchResult.WebSocketHub.Broadcast(messageJSON)
-
-	return matchResult, nil
-}
+	// Insert the result and record its event together so one never exists without the other
+	err := withTransaction(c, func(sc mongo.SessionContext) error {
+		result, err := collection.InsertOne(sc, matchResult)
+		if err != nil {
+			return err
+		}
 
-// Gets match results by organiser id
-func GetMatchResultsByOrganiserID(c *gin.Context, organiserID primitive.ObjectID) ([]*MatchResult, error) {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("match_results")
+		matchResult.ID = result.InsertedID.(primitive.ObjectID)
+
+		// Construct the WebSocket message for match result creation
+		message := map[string]interface{}{
+			"action":          "match_result_created",
+			"match_result_id": matchResult.ID.Hex(),
+			"match_id":        matchResult.MatchID.Hex(),
+			"tournament_id":   tournamentID,
+			"organiser_id":    matchResult.OrganiserID.Hex(),
+			"winner_id":       matchResult.WinnerID.Hex(),
+			"loser_id":        matchResult.LoserID.Hex(),
+			"winner_score":    matchResult.WinnerScore,
+			"loser_sccore":    matchResult.LoserScore,
+			"version":         matchResult.Version,
+		}
 
-	cursor, err := collection.Find(c, bson.M{"organiser_id": organiserID})
+		return recordOutboxEvent(sc, message)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(c)
 
-	var matchResults []*MatchResult
-	for cursor.Next(c) {
-		var matchResult MatchResult
-		if err := cursor.Decode(&matchResult); err != nil {
-			return nil, err
-		}
-		matchResults = append(matchResults, &matchResult)
-	}
+	return matchResult, nil
+}
 
-	if err := cursor.Err(); err != nil {
-		return nil, err
-	}
+// Gets a page of the match results a user can see
+func GetMatchResults(c context.Context, scope OwnerScope, query ListQuery) (*Page[MatchResult], error) {
+	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("match_results")
 
-	return matchResults, nil
+	return findPage[MatchResult](c, collection, notDeleted(scope.filter()), query)
 }
 
 // - GetMatchResultByID
-func GetMatchResultByID(c *gin.Context, id primitive.ObjectID) (*MatchResult, error) {
+func GetMatchResultByID(c context.Context, id primitive.ObjectID) (*MatchResult, error) {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("match_results")
 
 	var matchResult MatchResult
-	err := collection.FindOne(c, bson.M{"_id": id}).Decode(&matchResult)
+	err := collection.FindOne(c, notDeleted(bson.M{"_id": id})).Decode(&matchResult)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("Match result not found")
+			return nil, ErrMatchResultNotFound
 		}
 		return nil, err
 	}
@@ -104,50 +110,65 @@ func GetMatchResultByID(c *gin.Context, id primitive.ObjectID) (*MatchResult, er
 	return &matchResult, nil
 }
 
-// - UpdateMatchResult
-func UpdateMatchResult(c *gin.Context, id primitive.ObjectID, updatedMatchResult *MatchResult) error {
+// Updates a match result if it is still at updatedMatchResult.Version, which is then incremented
+func UpdateMatchResult(c context.Context, id primitive.ObjectID, updatedMatchResult *MatchResult) error {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("match_results")
 
-	update := bson.M{"$set": updatedMatchResult}
-	_, err := collection.UpdateOne(c, bson.M{"_id": id}, update)
-	if err != nil {
-		return err
+	// Look up the tournament so tournament subscribers also receive the result
+	var tournamentID string
+	if match, err := GetMatchByID(c, updatedMatchResult.MatchID); err == nil {
+		tournamentID = match.TournamentID.Hex()
 	}
 
-	// Construct and broadcast the WebSocket message
-	message := map[string]interface{}{
-		"action":          "match_result_created",
-		"match_result_id": updatedMatchResult.ID.Hex(),
-		"match_id":        updatedMatchResult.MatchID.Hex(),
-		"organiser_id":    updatedMatchResult.OrganiserID.Hex(),
-		"winner_id":       updatedMatchResult.WinnerID.Hex(),
-		"loser_id":        updatedMatchResult.LoserID.Hex(),
-		"winner_score":    updatedMatchResult.WinnerScore,
-		"loser_sccore":    updatedMatchResult.LoserScore,
-	}
+	expectedVersion := updatedMatchResult.Version
+	err := withTransaction(c, func(sc mongo.SessionContext) error {
+		updatedMatchResult.Version = expectedVersion + 1
+		updatedMatchResult.updated()
+		update := bson.M{"$set": updatedMatchResult}
+		result, err := collection.UpdateOne(sc, versionFilter(notDeleted(bson.M{"_id": id}), expectedVersion), update)
+		if err != nil {
+			return err
+		}
+
+		// Nothing changed, so there is nothing to tell clients about
+		if result.MatchedCount == 0 {
+			return versionMismatch(sc, "match_results", id, ErrMatchResultNotFound)
+		}
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(message)
+		// Construct the WebSocket message for match result update
+		message := map[string]interface{}{
+			"action":          "match_result_updated",
+			"match_result_id": id.Hex(),
+			"match_id":        updatedMatchResult.MatchID.Hex(),
+			"tournament_id":   tournamentID,
+			"organiser_id":    updatedMatchResult.OrganiserID.Hex(),
+			"winner_id":       updatedMatchResult.WinnerID.Hex(),
+			"loser_id":        updatedMatchResult.LoserID.Hex(),
+			"winner_score":    updatedMatchResult.WinnerScore,
+			"loser_sccore":    updatedMatchResult.LoserScore,
+			"version":         updatedMatchResult.Version,
+		}
+
+		return recordOutboxEvent(sc, message)
+	})
 	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
-		return err
+		updatedMatchResult.Version = expectedVersion
 	}
 
-	log.Printf("Broadcasting WebSocket message: %s", messageJSON)
-	// Broadcast the message to WebSocket clients
-	updatedMatchResult.WebSocketHub.Broadcast(messageJSON)
-
-	return nil
+	return err
 }
 
-// - DeleteMatchResult
-func DeleteMatchResult(c *gin.Context, id primitive.ObjectID) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("match_results")
+// Soft deletes a match result
+func DeleteMatchResult(c context.Context, id primitive.ObjectID) error {
+	return softDelete(c, "match_results", id, CascadeRules{}, ErrMatchResultNotFound)
+}
 
-	_, err := collection.DeleteOne(c, bson.M{"_id": id})
-	if err != nil {
-		return err
-	}
+// Gets a soft deleted match result
+func GetDeletedMatchResultByID(c context.Context, id primitive.ObjectID) (*MatchResult, error) {
+	return getDeleted[MatchResult](c, "match_results", id, ErrMatchResultNotFound)
+}
 
-	return nil
+// Restores a soft deleted match result
+func RestoreMatchResult(c context.Context, id primitive.ObjectID) error {
+	return restoreDeleted(c, "match_results", id, ErrMatchResultNotFound)
 }
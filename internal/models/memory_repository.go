@@ -0,0 +1,1729 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The memory repositories behave like the Mongo ones: IDs are generated on create, updates
+// keep the fields Mongo would skip when empty and only apply at the version they were read
+// at, lists are filtered, sorted and paged by the same queries, and deletes are soft and
+// cascade by the same rules. They don't record realtime events. Documents are copied in
+// and out so callers can't change what is stored.
+
+func cloneObjectIDs(ids []primitive.ObjectID) []primitive.ObjectID {
+	if ids == nil {
+		return nil
+	}
+	return append([]primitive.ObjectID{}, ids...)
+}
+
+// Tournaments, teams, matches and match results share one store and lock, so a write that
+// touches several of them happens all at once like the Mongo transactions.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	tournaments  map[primitive.ObjectID]*Tournament
+	teams        map[primitive.ObjectID]*Team
+	matches      map[primitive.ObjectID]*Match
+	matchResults map[primitive.ObjectID]*MatchResult
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tournaments:  make(map[primitive.ObjectID]*Tournament),
+		teams:        make(map[primitive.ObjectID]*Team),
+		matches:      make(map[primitive.ObjectID]*Match),
+		matchResults: make(map[primitive.ObjectID]*MatchResult),
+	}
+}
+
+// Adds a team or match to a tournament's list, or moves it from one tournament to
+// another. Either tournament may be empty. The caller holds the lock.
+func (s *MemoryStore) moveLocked(list func(*Tournament) *[]primitive.ObjectID, id, from, to primitive.ObjectID) error {
+	if from == to {
+		return nil
+	}
+
+	var target *Tournament
+	if !to.IsZero() {
+		var ok bool
+		if target, ok = s.tournaments[to]; !ok || target.deleted() {
+			return ErrTournamentNotFound
+		}
+	}
+
+	if source, ok := s.tournaments[from]; ok {
+		*list(source) = withoutObjectIDs(*list(source), id)
+		source.Version++
+		source.updated()
+	}
+
+	if target != nil {
+		ids := list(target)
+		if !containsObjectID(*ids, id) {
+			*ids = append(cloneObjectIDs(*ids), id)
+		}
+		target.Version++
+		target.updated()
+	}
+	return nil
+}
+
+func tournamentTeams(t *Tournament) *[]primitive.ObjectID   { return &t.Teams }
+func tournamentMatches(t *Tournament) *[]primitive.ObjectID { return &t.Matches }
+
+func withoutObjectIDs(ids []primitive.ObjectID, remove ...primitive.ObjectID) []primitive.ObjectID {
+	kept := ids[:0:0]
+	for _, id := range ids {
+		if !containsObjectID(remove, id) {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// The deletion marker of any stored document, or nil if there is no such document
+func (s *MemoryStore) deletionLocked(collection string, id primitive.ObjectID) *Deletion {
+	switch collection {
+	case "tournaments":
+		if tournament, ok := s.tournaments[id]; ok {
+			return &tournament.Deletion
+		}
+	case "teams":
+		if team, ok := s.teams[id]; ok {
+			return &team.Deletion
+		}
+	case "matches":
+		if match, ok := s.matches[id]; ok {
+			return &match.Deletion
+		}
+	case "match_results":
+		if matchResult, ok := s.matchResults[id]; ok {
+			return &matchResult.Deletion
+		}
+	}
+	return nil
+}
+
+// The store reads its own cascade while the caller holds the lock
+
+func (s *MemoryStore) teamsInTournament(c context.Context, tournamentID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for _, team := range s.teams {
+		if !team.deleted() && team.TournamentID == tournamentID {
+			ids = append(ids, team.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryStore) matchesInTournament(c context.Context, tournamentID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for _, match := range s.matches {
+		if !match.deleted() && match.TournamentID == tournamentID {
+			ids = append(ids, match.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryStore) matchesForTeam(c context.Context, teamID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for _, match := range s.matches {
+		if !match.deleted() && (match.Team1ID == teamID || match.Team2ID == teamID) {
+			ids = append(ids, match.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryStore) resultsForMatch(c context.Context, matchID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for _, matchResult := range s.matchResults {
+		if !matchResult.deleted() && matchResult.MatchID == matchID {
+			ids = append(ids, matchResult.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryStore) isLive(c context.Context, collection string, id primitive.ObjectID) (bool, error) {
+	deletion := s.deletionLocked(collection, id)
+	return deletion != nil && !deletion.deleted(), nil
+}
+
+// Soft deletes a document and everything its cascade rules reach, or nothing if the rules refuse
+func (s *MemoryStore) softDelete(c context.Context, collection string, id primitive.ObjectID, rules CascadeRules, notFound error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if live, _ := s.isLive(c, collection, id); !live {
+		return notFound
+	}
+
+	plan := newDeletionPlan(rules, s)
+	if err := plan.delete(c, collection, id); err != nil {
+		return err
+	}
+
+	deletedAt := time.Now().UTC()
+	mark := Deletion{DeletedAt: &deletedAt, DeletionID: primitive.NewObjectID()}
+	for name, ids := range map[string][]primitive.ObjectID{
+		"tournaments":   plan.tournaments,
+		"teams":         plan.teams,
+		"matches":       plan.matches,
+		"match_results": plan.matchResults,
+	} {
+		for _, markedID := range ids {
+			*s.deletionLocked(name, markedID) = mark
+		}
+	}
+
+	for _, teamID := range plan.detachedTeams {
+		s.teams[teamID].TournamentID = primitive.NilObjectID
+		s.teams[teamID].Version++
+		s.teams[teamID].updated()
+	}
+	for _, matchID := range plan.detachedMatches {
+		s.matches[matchID].TournamentID = primitive.NilObjectID
+		s.matches[matchID].Version++
+		s.matches[matchID].updated()
+	}
+
+	teamIDs, matchIDs := plan.unlistedTeams(), plan.unlistedMatches()
+	for _, tournament := range s.tournaments {
+		teams := withoutObjectIDs(tournament.Teams, teamIDs...)
+		matches := withoutObjectIDs(tournament.Matches, matchIDs...)
+		if len(teams) != len(tournament.Teams) || len(matches) != len(tournament.Matches) {
+			tournament.Teams, tournament.Matches = teams, matches
+			tournament.Version++
+			tournament.updated()
+		}
+	}
+
+	return nil
+}
+
+// Restores a soft deleted document and everything deleted with it
+func (s *MemoryStore) restore(c context.Context, collection string, id primitive.ObjectID, notFound error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletion := s.deletionLocked(collection, id)
+	if deletion == nil || !deletion.deleted() {
+		return notFound
+	}
+	deletionID := deletion.DeletionID
+
+	var group deletionGroup
+	for _, tournament := range s.tournaments {
+		if tournament.DeletionID == deletionID {
+			group.tournaments = append(group.tournaments, tournament)
+		}
+	}
+	for _, team := range s.teams {
+		if team.DeletionID == deletionID {
+			group.teams = append(group.teams, team)
+		}
+	}
+	for _, match := range s.matches {
+		if match.DeletionID == deletionID {
+			group.matches = append(group.matches, match)
+		}
+	}
+	for _, matchResult := range s.matchResults {
+		if matchResult.DeletionID == deletionID {
+			group.matchResults = append(group.matchResults, matchResult)
+		}
+	}
+
+	if err := group.checkParents(c, s); err != nil {
+		return err
+	}
+
+	for _, tournament := range group.tournaments {
+		tournament.Deletion = Deletion{}
+	}
+	for _, team := range group.teams {
+		team.Deletion = Deletion{}
+	}
+	for _, match := range group.matches {
+		match.Deletion = Deletion{}
+	}
+	for _, matchResult := range group.matchResults {
+		matchResult.Deletion = Deletion{}
+	}
+
+	for _, team := range group.teams {
+		if err := s.moveLocked(tournamentTeams, team.ID, primitive.NilObjectID, team.TournamentID); err != nil {
+			return err
+		}
+	}
+	for _, match := range group.matches {
+		if err := s.moveLocked(tournamentMatches, match.ID, primitive.NilObjectID, match.TournamentID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) PurgeDeleted(c context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	purge := func(deletion *Deletion) bool {
+		if deletion.deleted() && deletion.DeletedAt.Before(before) {
+			purged++
+			return true
+		}
+		return false
+	}
+
+	for id, tournament := range s.tournaments {
+		if purge(&tournament.Deletion) {
+			delete(s.tournaments, id)
+		}
+	}
+	for id, team := range s.teams {
+		if purge(&team.Deletion) {
+			delete(s.teams, id)
+		}
+	}
+	for id, match := range s.matches {
+		if purge(&match.Deletion) {
+			delete(s.matches, id)
+		}
+	}
+	for id, matchResult := range s.matchResults {
+		if purge(&matchResult.Deletion) {
+			delete(s.matchResults, id)
+		}
+	}
+
+	return purged, nil
+}
+
+type MemoryTournamentRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryTournamentRepository(store *MemoryStore) *MemoryTournamentRepository {
+	return &MemoryTournamentRepository{store: store}
+}
+
+func cloneTournament(tournament *Tournament) *Tournament {
+	copied := *tournament
+	copied.Teams = cloneObjectIDs(tournament.Teams)
+	copied.Matches = cloneObjectIDs(tournament.Matches)
+	copied.StaffIDs = cloneObjectIDs(tournament.StaffIDs)
+	return &copied
+}
+
+func (r *MemoryTournamentRepository) Create(c context.Context, tournament *Tournament) (*Tournament, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if tournament.ID.IsZero() {
+		tournament.ID = primitive.NewObjectID()
+	}
+	tournament.Version = 1
+	tournament.created()
+	r.store.tournaments[tournament.ID] = cloneTournament(tournament)
+
+	return tournament, nil
+}
+
+func (r *MemoryTournamentRepository) GetByID(c context.Context, id primitive.ObjectID) (*Tournament, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	tournament, ok := r.store.tournaments[id]
+	if !ok || tournament.deleted() {
+		return nil, ErrTournamentNotFound
+	}
+	return cloneTournament(tournament), nil
+}
+
+func (r *MemoryTournamentRepository) List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Tournament], error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var tournaments []*Tournament
+	for _, tournament := range r.store.tournaments {
+		if !tournament.deleted() && scope.includes(tournament.OrganisationID, tournament.OrganiserID) {
+			tournaments = append(tournaments, cloneTournament(tournament))
+		}
+	}
+	return listPage(tournaments, query)
+}
+
+func (r *MemoryTournamentRepository) Update(c context.Context, id primitive.ObjectID, tournament *Tournament) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.tournaments[id]
+	if !ok || existing.deleted() {
+		return ErrTournamentNotFound
+	}
+	if existing.Version != tournament.Version {
+		return ErrVersionConflict
+	}
+
+	updated := cloneTournament(tournament)
+	updated.ID = id
+	updated.Deletion = Deletion{}
+	updated.Version++
+	updated.updated()
+	if updated.CreatedAt.IsZero() {
+		updated.CreatedAt = existing.CreatedAt
+	}
+	if updated.OrganisationID.IsZero() {
+		updated.OrganisationID = existing.OrganisationID
+	}
+	r.store.tournaments[id] = updated
+	tournament.Version = updated.Version
+	tournament.Timestamps = updated.Timestamps
+
+	return nil
+}
+
+func (r *MemoryTournamentRepository) Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return r.store.softDelete(c, "tournaments", id, rules, ErrTournamentNotFound)
+}
+
+func (r *MemoryTournamentRepository) GetDeletedByID(c context.Context, id primitive.ObjectID) (*Tournament, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	tournament, ok := r.store.tournaments[id]
+	if !ok || !tournament.deleted() {
+		return nil, ErrTournamentNotFound
+	}
+	return cloneTournament(tournament), nil
+}
+
+func (r *MemoryTournamentRepository) Restore(c context.Context, id primitive.ObjectID) error {
+	return r.store.restore(c, "tournaments", id, ErrTournamentNotFound)
+}
+
+// Teams keep the store's tournament team lists up to date
+type MemoryTeamRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryTeamRepository(store *MemoryStore) *MemoryTeamRepository {
+	return &MemoryTeamRepository{store: store}
+}
+
+func cloneTeam(team *Team) *Team {
+	copied := *team
+	if team.Players != nil {
+		copied.Players = append([]string{}, team.Players...)
+	}
+	return &copied
+}
+
+func (r *MemoryTeamRepository) Create(c context.Context, team *Team) (*Team, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if team.ID.IsZero() {
+		team.ID = primitive.NewObjectID()
+	}
+	team.Version = 1
+	team.created()
+
+	if err := r.store.moveLocked(tournamentTeams, team.ID, primitive.NilObjectID, team.TournamentID); err != nil {
+		return nil, err
+	}
+	r.store.teams[team.ID] = cloneTeam(team)
+
+	return team, nil
+}
+
+func (r *MemoryTeamRepository) GetByID(c context.Context, id primitive.ObjectID) (*Team, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	team, ok := r.store.teams[id]
+	if !ok || team.deleted() {
+		return nil, ErrTeamNotFound
+	}
+	return cloneTeam(team), nil
+}
+
+func (r *MemoryTeamRepository) List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Team], error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var teams []*Team
+	for _, team := range r.store.teams {
+		if !team.deleted() && scope.includes(team.OrganisationID, team.OrganiserID) {
+			teams = append(teams, cloneTeam(team))
+		}
+	}
+	return listPage(teams, query)
+}
+
+func (r *MemoryTeamRepository) Update(c context.Context, id primitive.ObjectID, team *Team) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.teams[id]
+	if !ok || existing.deleted() {
+		return ErrTeamNotFound
+	}
+	if existing.Version != team.Version {
+		return ErrVersionConflict
+	}
+
+	updated := cloneTeam(team)
+	updated.ID = id
+	updated.Deletion = Deletion{}
+	if updated.OrganisationID.IsZero() {
+		updated.OrganisationID = existing.OrganisationID
+	}
+	if updated.TournamentID.IsZero() {
+		updated.TournamentID = existing.TournamentID
+	}
+
+	if err := r.store.moveLocked(tournamentTeams, id, existing.TournamentID, updated.TournamentID); err != nil {
+		return err
+	}
+	updated.Version++
+	updated.updated()
+	if updated.CreatedAt.IsZero() {
+		updated.CreatedAt = existing.CreatedAt
+	}
+	r.store.teams[id] = updated
+	team.Version = updated.Version
+	team.Timestamps = updated.Timestamps
+
+	return nil
+}
+
+func (r *MemoryTeamRepository) Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return r.store.softDelete(c, "teams", id, rules, ErrTeamNotFound)
+}
+
+func (r *MemoryTeamRepository) GetDeletedByID(c context.Context, id primitive.ObjectID) (*Team, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	team, ok := r.store.teams[id]
+	if !ok || !team.deleted() {
+		return nil, ErrTeamNotFound
+	}
+	return cloneTeam(team), nil
+}
+
+func (r *MemoryTeamRepository) Restore(c context.Context, id primitive.ObjectID) error {
+	return r.store.restore(c, "teams", id, ErrTeamNotFound)
+}
+
+// Matches keep the store's tournament match lists up to date the same way teams do
+type MemoryMatchRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryMatchRepository(store *MemoryStore) *MemoryMatchRepository {
+	return &MemoryMatchRepository{store: store}
+}
+
+func cloneMatch(match *Match) *Match {
+	copied := *match
+	if match.Vetoes != nil {
+		copied.Vetoes = append([]MapVeto{}, match.Vetoes...)
+	}
+	copied.RefereeIDs = cloneObjectIDs(match.RefereeIDs)
+	return &copied
+}
+
+func (r *MemoryMatchRepository) Create(c context.Context, match *Match) (*Match, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if match.ID.IsZero() {
+		match.ID = primitive.NewObjectID()
+	}
+	match.Version = 1
+	match.created()
+
+	if err := r.store.moveLocked(tournamentMatches, match.ID, primitive.NilObjectID, match.TournamentID); err != nil {
+		return nil, err
+	}
+	r.store.matches[match.ID] = cloneMatch(match)
+
+	return match, nil
+}
+
+func (r *MemoryMatchRepository) GetByID(c context.Context, id primitive.ObjectID) (*Match, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	match, ok := r.store.matches[id]
+	if !ok || match.deleted() {
+		return nil, ErrMatchNotFound
+	}
+	return cloneMatch(match), nil
+}
+
+func (r *MemoryMatchRepository) List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Match], error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var matches []*Match
+	for _, match := range r.store.matches {
+		if !match.deleted() && scope.includes(match.OrganisationID, match.OrganiserID) {
+			matches = append(matches, cloneMatch(match))
+		}
+	}
+	return listPage(matches, query)
+}
+
+func (r *MemoryMatchRepository) Update(c context.Context, id primitive.ObjectID, match *Match) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.matches[id]
+	if !ok || existing.deleted() {
+		return ErrMatchNotFound
+	}
+	if existing.Version != match.Version {
+		return ErrVersionConflict
+	}
+
+	updated := cloneMatch(match)
+	updated.ID = id
+	updated.Deletion = Deletion{}
+	if updated.OrganisationID.IsZero() {
+		updated.OrganisationID = existing.OrganisationID
+	}
+	if updated.TournamentID.IsZero() {
+		updated.TournamentID = existing.TournamentID
+	}
+
+	if err := r.store.moveLocked(tournamentMatches, id, existing.TournamentID, updated.TournamentID); err != nil {
+		return err
+	}
+	updated.Version++
+	updated.updated()
+	if updated.CreatedAt.IsZero() {
+		updated.CreatedAt = existing.CreatedAt
+	}
+	r.store.matches[id] = updated
+	match.Version = updated.Version
+	match.Timestamps = updated.Timestamps
+
+	return nil
+}
+
+func (r *MemoryMatchRepository) Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return r.store.softDelete(c, "matches", id, rules, ErrMatchNotFound)
+}
+
+func (r *MemoryMatchRepository) GetDeletedByID(c context.Context, id primitive.ObjectID) (*Match, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	match, ok := r.store.matches[id]
+	if !ok || !match.deleted() {
+		return nil, ErrMatchNotFound
+	}
+	return cloneMatch(match), nil
+}
+
+func (r *MemoryMatchRepository) Restore(c context.Context, id primitive.ObjectID) error {
+	return r.store.restore(c, "matches", id, ErrMatchNotFound)
+}
+
+type MemoryMatchResultRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryMatchResultRepository(store *MemoryStore) *MemoryMatchResultRepository {
+	return &MemoryMatchResultRepository{store: store}
+}
+
+func (r *MemoryMatchResultRepository) Create(c context.Context, matchResult *MatchResult) (*MatchResult, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if matchResult.ID.IsZero() {
+		matchResult.ID = primitive.NewObjectID()
+	}
+	matchResult.Version = 1
+	matchResult.created()
+	copied := *matchResult
+	r.store.matchResults[matchResult.ID] = &copied
+
+	return matchResult, nil
+}
+
+func (r *MemoryMatchResultRepository) GetByID(c context.Context, id primitive.ObjectID) (*MatchResult, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	matchResult, ok := r.store.matchResults[id]
+	if !ok || matchResult.deleted() {
+		return nil, ErrMatchResultNotFound
+	}
+	copied := *matchResult
+	return &copied, nil
+}
+
+func (r *MemoryMatchResultRepository) List(c context.Context, scope OwnerScope, query ListQuery) (*Page[MatchResult], error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var matchResults []*MatchResult
+	for _, matchResult := range r.store.matchResults {
+		if !matchResult.deleted() && scope.includes(matchResult.OrganisationID, matchResult.OrganiserID) {
+			copied := *matchResult
+			matchResults = append(matchResults, &copied)
+		}
+	}
+	return listPage(matchResults, query)
+}
+
+func (r *MemoryMatchResultRepository) Update(c context.Context, id primitive.ObjectID, matchResult *MatchResult) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.matchResults[id]
+	if !ok || existing.deleted() {
+		return ErrMatchResultNotFound
+	}
+	if existing.Version != matchResult.Version {
+		return ErrVersionConflict
+	}
+
+	updated := *matchResult
+	updated.ID = id
+	updated.Deletion = Deletion{}
+	updated.Version++
+	updated.updated()
+	if updated.CreatedAt.IsZero() {
+		updated.CreatedAt = existing.CreatedAt
+	}
+	if updated.OrganisationID.IsZero() {
+		updated.OrganisationID = existing.OrganisationID
+	}
+	r.store.matchResults[id] = &updated
+	matchResult.Version = updated.Version
+	matchResult.Timestamps = updated.Timestamps
+
+	return nil
+}
+
+func (r *MemoryMatchResultRepository) Delete(c context.Context, id primitive.ObjectID) error {
+	return r.store.softDelete(c, "match_results", id, CascadeRules{}, ErrMatchResultNotFound)
+}
+
+func (r *MemoryMatchResultRepository) GetDeletedByID(c context.Context, id primitive.ObjectID) (*MatchResult, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	matchResult, ok := r.store.matchResults[id]
+	if !ok || !matchResult.deleted() {
+		return nil, ErrMatchResultNotFound
+	}
+	copied := *matchResult
+	return &copied, nil
+}
+
+func (r *MemoryMatchResultRepository) Restore(c context.Context, id primitive.ObjectID) error {
+	return r.store.restore(c, "match_results", id, ErrMatchResultNotFound)
+}
+
+// Searches by ranking every tournament and team in the scope
+type MemorySearcher struct {
+	store *MemoryStore
+}
+
+func NewMemorySearcher(store *MemoryStore) *MemorySearcher {
+	return &MemorySearcher{store: store}
+}
+
+func (r *MemorySearcher) Search(c context.Context, scope OwnerScope, query SearchQuery) (*SearchResults, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var tournaments []*Tournament
+	for _, tournament := range r.store.tournaments {
+		if !tournament.deleted() && scope.includes(tournament.OrganisationID, tournament.OrganiserID) {
+			tournaments = append(tournaments, cloneTournament(tournament))
+		}
+	}
+
+	var teams []*Team
+	for _, team := range r.store.teams {
+		if !team.deleted() && scope.includes(team.OrganisationID, team.OrganiserID) {
+			teams = append(teams, cloneTeam(team))
+		}
+	}
+
+	return rankSearchResults(query, tournaments, teams), nil
+}
+
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]*User
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: make(map[primitive.ObjectID]*User)}
+}
+
+func cloneUser(user *User) *User {
+	copied := *user
+	if user.Roles != nil {
+		copied.Roles = append([]string{}, user.Roles...)
+	}
+	if user.Identities != nil {
+		copied.Identities = append([]UserIdentity{}, user.Identities...)
+	}
+	if user.TwoFactorRecoveryCodes != nil {
+		copied.TwoFactorRecoveryCodes = append([]string{}, user.TwoFactorRecoveryCodes...)
+	}
+	return &copied
+}
+
+func (r *MemoryUserRepository) Create(c context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Like the unique email index on the users collection
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return ErrEmailInUse
+		}
+	}
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	user.created()
+	r.users[user.ID] = cloneUser(user)
+
+	return nil
+}
+
+func (r *MemoryUserRepository) GetByID(c context.Context, id primitive.ObjectID) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (r *MemoryUserRepository) GetByEmail(c context.Context, email string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return cloneUser(user), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *MemoryUserRepository) Update(c context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.users[user.ID]; ok {
+		user.updated()
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = existing.CreatedAt
+		}
+		r.users[user.ID] = cloneUser(user)
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) GetByIdentity(c context.Context, provider, subject string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		for _, identity := range user.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				return cloneUser(user), nil
+			}
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// Applies a change to one stored user, failing with ErrUserNotFound if there is no such user
+func (r *MemoryUserRepository) update(userID primitive.ObjectID, apply func(user *User) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if err := apply(user); err != nil {
+		return err
+	}
+	user.UpdatedAt = utcNow()
+
+	return nil
+}
+
+func (r *MemoryUserRepository) AddIdentity(c context.Context, userID primitive.ObjectID, identity UserIdentity) error {
+	return r.update(userID, func(user *User) error {
+		user.Identities = append(user.Identities, identity)
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) RemoveIdentity(c context.Context, userID primitive.ObjectID, provider string) error {
+	return r.update(userID, func(user *User) error {
+		identities := user.Identities[:0:0]
+		for _, identity := range user.Identities {
+			if identity.Provider != provider {
+				identities = append(identities, identity)
+			}
+		}
+		user.Identities = identities
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) VerifyEmail(c context.Context, userID primitive.ObjectID) error {
+	return r.update(userID, func(user *User) error {
+		now := utcNow()
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) ResetPassword(c context.Context, userID primitive.ObjectID, passwordHash string) error {
+	return r.update(userID, func(user *User) error {
+		user.Password = passwordHash
+		user.EmailVerified = true
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) SetTwoFactorPendingSecret(c context.Context, userID primitive.ObjectID, secret string) error {
+	return r.update(userID, func(user *User) error {
+		user.TwoFactorPendingSecret = secret
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) EnableTwoFactor(c context.Context, userID primitive.ObjectID, pendingSecret string, recoveryCodeHashes []string, step int64) error {
+	err := r.update(userID, func(user *User) error {
+		if user.TwoFactorPendingSecret != pendingSecret {
+			return ErrTwoFactorNotSetUp
+		}
+		user.TwoFactorEnabled = true
+		user.TwoFactorSecret = pendingSecret
+		user.TwoFactorRecoveryCodes = append([]string{}, recoveryCodeHashes...)
+		user.TwoFactorLastStep = step
+		user.TwoFactorPendingSecret = ""
+		return nil
+	})
+	if err == ErrUserNotFound {
+		return ErrTwoFactorNotSetUp
+	}
+	return err
+}
+
+func (r *MemoryUserRepository) DisableTwoFactor(c context.Context, userID primitive.ObjectID) error {
+	return r.update(userID, func(user *User) error {
+		user.TwoFactorEnabled = false
+		user.TwoFactorSecret = ""
+		user.TwoFactorRecoveryCodes = nil
+		user.TwoFactorLastStep = 0
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) SetRecoveryCodes(c context.Context, userID primitive.ObjectID, recoveryCodeHashes []string) error {
+	return r.update(userID, func(user *User) error {
+		user.TwoFactorRecoveryCodes = append([]string{}, recoveryCodeHashes...)
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) UseTwoFactorStep(c context.Context, userID primitive.ObjectID, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok || user.TwoFactorLastStep >= step {
+		return false, nil
+	}
+	user.TwoFactorLastStep = step
+
+	return true, nil
+}
+
+func (r *MemoryUserRepository) UseRecoveryCode(c context.Context, userID primitive.ObjectID, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return false, nil
+	}
+	for i, hash := range user.TwoFactorRecoveryCodes {
+		if hash == codeHash {
+			user.TwoFactorRecoveryCodes = append(user.TwoFactorRecoveryCodes[:i:i], user.TwoFactorRecoveryCodes[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+type MemoryOrganisationRepository struct {
+	mu            sync.RWMutex
+	organisations map[primitive.ObjectID]*Organisation
+}
+
+func NewMemoryOrganisationRepository() *MemoryOrganisationRepository {
+	return &MemoryOrganisationRepository{organisations: make(map[primitive.ObjectID]*Organisation)}
+}
+
+func cloneOrganisation(organisation *Organisation) *Organisation {
+	copied := *organisation
+	copied.Members = append([]OrganisationMember{}, organisation.Members...)
+	return &copied
+}
+
+func (r *MemoryOrganisationRepository) Create(c context.Context, organisation *Organisation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if organisation.ID.IsZero() {
+		organisation.ID = primitive.NewObjectID()
+	}
+	r.organisations[organisation.ID] = cloneOrganisation(organisation)
+
+	return nil
+}
+
+func (r *MemoryOrganisationRepository) GetByID(c context.Context, id primitive.ObjectID) (*Organisation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	organisation, ok := r.organisations[id]
+	if !ok {
+		return nil, ErrOrganisationNotFound
+	}
+	return cloneOrganisation(organisation), nil
+}
+
+func (r *MemoryOrganisationRepository) ListForUser(c context.Context, userID primitive.ObjectID) ([]*Organisation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	organisations := []*Organisation{}
+	for _, organisation := range r.organisations {
+		if organisation.RoleOf(userID) != "" {
+			organisations = append(organisations, cloneOrganisation(organisation))
+		}
+	}
+	sort.Slice(organisations, func(i, j int) bool {
+		return organisations[i].Name < organisations[j].Name
+	})
+
+	return organisations, nil
+}
+
+func (r *MemoryOrganisationRepository) AddMember(c context.Context, organisationID primitive.ObjectID, member OrganisationMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	organisation, ok := r.organisations[organisationID]
+	if !ok || organisation.RoleOf(member.UserID) != "" {
+		return ErrAlreadyOrganisationMember
+	}
+	organisation.Members = append(organisation.Members, member)
+	organisation.UpdatedAt = utcNow()
+
+	return nil
+}
+
+func (r *MemoryOrganisationRepository) UpdateMemberRole(c context.Context, organisationID, userID primitive.ObjectID, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if organisation, ok := r.organisations[organisationID]; ok {
+		for i := range organisation.Members {
+			if organisation.Members[i].UserID == userID {
+				organisation.Members[i].Role = role
+				organisation.UpdatedAt = utcNow()
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *MemoryOrganisationRepository) RemoveMember(c context.Context, organisationID, userID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if organisation, ok := r.organisations[organisationID]; ok {
+		members := organisation.Members[:0:0]
+		for _, member := range organisation.Members {
+			if member.UserID != userID {
+				members = append(members, member)
+			}
+		}
+		organisation.Members = members
+		organisation.UpdatedAt = utcNow()
+	}
+	return nil
+}
+
+type MemorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[primitive.ObjectID]*Session
+}
+
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{sessions: make(map[primitive.ObjectID]*Session)}
+}
+
+func (r *MemorySessionRepository) Create(c context.Context, session *Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	copied := *session
+	r.sessions[session.ID] = &copied
+
+	return nil
+}
+
+func (r *MemorySessionRepository) GetByID(c context.Context, id primitive.ObjectID) (*Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *MemorySessionRepository) Rotate(c context.Context, session *Session, previousHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.sessions[session.ID]
+	if !ok || existing.RefreshTokenHash != previousHash || existing.RevokedAt != nil {
+		return ErrInvalidRefreshToken
+	}
+	existing.RefreshTokenHash = session.RefreshTokenHash
+	existing.LastUsedAt = session.LastUsedAt
+	existing.ExpiresAt = session.ExpiresAt
+	existing.UserAgent = session.UserAgent
+	existing.IPAddress = session.IPAddress
+
+	return nil
+}
+
+func (r *MemorySessionRepository) Revoke(c context.Context, filter SessionFilter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := utcNow()
+	for _, session := range r.sessions {
+		if session.RevokedAt != nil ||
+			(!filter.ID.IsZero() && session.ID != filter.ID) ||
+			(!filter.UserID.IsZero() && session.UserID != filter.UserID) ||
+			(filter.RefreshTokenHash != "" && session.RefreshTokenHash != filter.RefreshTokenHash) {
+			continue
+		}
+		revokedAt := now
+		session.RevokedAt = &revokedAt
+	}
+	return nil
+}
+
+func (s *Session) active() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(utcNow())
+}
+
+func (r *MemorySessionRepository) ListActive(c context.Context, userID primitive.ObjectID) ([]*Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := []*Session{}
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.active() {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) IsActive(c context.Context, id primitive.ObjectID) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	return ok && session.active(), nil
+}
+
+type MemoryAccountTokenRepository struct {
+	mu     sync.Mutex
+	tokens []*AccountToken
+}
+
+func NewMemoryAccountTokenRepository() *MemoryAccountTokenRepository {
+	return &MemoryAccountTokenRepository{}
+}
+
+func (r *MemoryAccountTokenRepository) Replace(c context.Context, token *AccountToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := utcNow()
+	for _, existing := range r.tokens {
+		if existing.UserID == token.UserID && existing.Purpose == token.Purpose && existing.UsedAt == nil {
+			usedAt := now
+			existing.UsedAt = &usedAt
+		}
+	}
+
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	copied := *token
+	r.tokens = append(r.tokens, &copied)
+
+	return nil
+}
+
+// The caller holds the lock
+func (r *MemoryAccountTokenRepository) usableLocked(tokenHash, purpose string) *AccountToken {
+	now := utcNow()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash && token.Purpose == purpose && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			return token
+		}
+	}
+	return nil
+}
+
+func (r *MemoryAccountTokenRepository) Find(c context.Context, tokenHash, purpose string) (*AccountToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := r.usableLocked(tokenHash, purpose)
+	if token == nil {
+		return nil, ErrInvalidAccountToken
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *MemoryAccountTokenRepository) Consume(c context.Context, tokenHash, purpose string) (*AccountToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := r.usableLocked(tokenHash, purpose)
+	if token == nil {
+		return nil, ErrInvalidAccountToken
+	}
+	copied := *token
+	usedAt := utcNow()
+	token.UsedAt = &usedAt
+
+	return &copied, nil
+}
+
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]*LoginAttempt
+}
+
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{attempts: make(map[string]*LoginAttempt)}
+}
+
+func (r *MemoryLoginAttemptRepository) ListLocked(c context.Context, keys []string) ([]*LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := utcNow()
+	var locked []*LoginAttempt
+	for _, key := range keys {
+		if attempt, ok := r.attempts[key]; ok && attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			copied := *attempt
+			locked = append(locked, &copied)
+		}
+	}
+	return locked, nil
+}
+
+func (r *MemoryLoginAttemptRepository) RecordFailure(c context.Context, key string, window time.Duration) (*LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := utcNow()
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &LoginAttempt{Key: key}
+		r.attempts[key] = attempt
+	}
+	if attempt.LastFailureAt.After(now.Add(-window)) {
+		attempt.Failures++
+	} else {
+		attempt.Failures = 1
+	}
+	attempt.LastFailureAt = now
+
+	copied := *attempt
+	return &copied, nil
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(c context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.LockedUntil = &until
+	}
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) Clear(c context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+type MemoryAuditLogRepository struct {
+	mu      sync.Mutex
+	entries []*AuditLog
+}
+
+func NewMemoryAuditLogRepository() *MemoryAuditLogRepository {
+	return &MemoryAuditLogRepository{}
+}
+
+func (r *MemoryAuditLogRepository) Create(c context.Context, entry *AuditLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	copied := *entry
+	r.entries = append(r.entries, &copied)
+
+	return nil
+}
+
+type MemoryAPIKeyRepository struct {
+	mu      sync.RWMutex
+	apiKeys map[primitive.ObjectID]*APIKey
+}
+
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{apiKeys: make(map[primitive.ObjectID]*APIKey)}
+}
+
+func cloneAPIKey(apiKey *APIKey) *APIKey {
+	copied := *apiKey
+	copied.Scopes = append([]string{}, apiKey.Scopes...)
+	return &copied
+}
+
+func (r *MemoryAPIKeyRepository) Create(c context.Context, apiKey *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if apiKey.ID.IsZero() {
+		apiKey.ID = primitive.NewObjectID()
+	}
+	r.apiKeys[apiKey.ID] = cloneAPIKey(apiKey)
+
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) GetByID(c context.Context, id primitive.ObjectID) (*APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	apiKey, ok := r.apiKeys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return cloneAPIKey(apiKey), nil
+}
+
+func (r *MemoryAPIKeyRepository) ListActive(c context.Context, userID primitive.ObjectID) ([]*APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	apiKeys := []*APIKey{}
+	for _, apiKey := range r.apiKeys {
+		if apiKey.UserID == userID && apiKey.RevokedAt == nil {
+			apiKeys = append(apiKeys, cloneAPIKey(apiKey))
+		}
+	}
+	sort.Slice(apiKeys, func(i, j int) bool {
+		return apiKeys[i].CreatedAt.After(apiKeys[j].CreatedAt)
+	})
+
+	return apiKeys, nil
+}
+
+func (r *MemoryAPIKeyRepository) Revoke(c context.Context, userID, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKey, ok := r.apiKeys[id]
+	if !ok || apiKey.UserID != userID || apiKey.RevokedAt != nil {
+		return ErrAPIKeyNotFound
+	}
+	now := utcNow()
+	apiKey.RevokedAt = &now
+
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) MarkUsed(c context.Context, id primitive.ObjectID, interval time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := utcNow()
+	if apiKey, ok := r.apiKeys[id]; ok && (apiKey.LastUsedAt == nil || apiKey.LastUsedAt.Before(now.Add(-interval))) {
+		apiKey.LastUsedAt = &now
+	}
+	return nil
+}
+
+type MemoryOIDCStateRepository struct {
+	mu     sync.Mutex
+	states []*OIDCLoginState
+}
+
+func NewMemoryOIDCStateRepository() *MemoryOIDCStateRepository {
+	return &MemoryOIDCStateRepository{}
+}
+
+func (r *MemoryOIDCStateRepository) Create(c context.Context, state *OIDCLoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if state.ID.IsZero() {
+		state.ID = primitive.NewObjectID()
+	}
+	copied := *state
+	r.states = append(r.states, &copied)
+
+	return nil
+}
+
+func (r *MemoryOIDCStateRepository) Take(c context.Context, stateHash, provider string) (*OIDCLoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := utcNow()
+	for i, state := range r.states {
+		if state.StateHash == stateHash && state.Provider == provider && state.ExpiresAt.After(now) {
+			r.states = append(r.states[:i:i], r.states[i+1:]...)
+			return state, nil
+		}
+	}
+	return nil, ErrInvalidOIDCState
+}
+
+type MemoryChatRepository struct {
+	mu       sync.RWMutex
+	messages []*ChatMessage
+	mutes    []*ChatMute
+}
+
+func NewMemoryChatRepository() *MemoryChatRepository {
+	return &MemoryChatRepository{}
+}
+
+func (r *MemoryChatRepository) CreateMessage(c context.Context, message *ChatMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if message.ID.IsZero() {
+		message.ID = primitive.NewObjectID()
+	}
+	copied := *message
+	r.messages = append(r.messages, &copied)
+
+	return nil
+}
+
+// Newest first, starting before the given message if there is one. Messages are stored in
+// the order they were created, so this reads them backwards.
+func (r *MemoryChatRepository) ListMessages(c context.Context, matchID, before primitive.ObjectID, limit int) ([]*ChatMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := []*ChatMessage{}
+	for i := len(r.messages) - 1; i >= 0 && len(messages) < limit; i-- {
+		message := r.messages[i]
+		if message.MatchID != matchID || (!before.IsZero() && message.ID.Hex() >= before.Hex()) {
+			continue
+		}
+		copied := *message
+		messages = append(messages, &copied)
+	}
+	return messages, nil
+}
+
+func (r *MemoryChatRepository) DeleteMessage(c context.Context, matchID, messageID, deletedBy primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, message := range r.messages {
+		if message.ID == messageID && message.MatchID == matchID {
+			now := utcNow()
+			message.Body = ""
+			message.DeletedAt = &now
+			message.DeletedBy = &deletedBy
+			return nil
+		}
+	}
+	return ErrChatMessageNotFound
+}
+
+func (r *MemoryChatRepository) GetActiveMute(c context.Context, matchID, userID primitive.ObjectID) (*ChatMute, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := utcNow()
+	for _, mute := range r.mutes {
+		if mute.MatchID == matchID && mute.UserID == userID && mute.Until.After(now) {
+			copied := *mute
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// The caller holds the lock
+func (r *MemoryChatRepository) unmuteLocked(matchID, userID primitive.ObjectID) {
+	mutes := r.mutes[:0:0]
+	for _, mute := range r.mutes {
+		if mute.MatchID != matchID || mute.UserID != userID {
+			mutes = append(mutes, mute)
+		}
+	}
+	r.mutes = mutes
+}
+
+func (r *MemoryChatRepository) Mute(c context.Context, mute *ChatMute) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unmuteLocked(mute.MatchID, mute.UserID)
+	copied := *mute
+	r.mutes = append(r.mutes, &copied)
+
+	return nil
+}
+
+func (r *MemoryChatRepository) Unmute(c context.Context, matchID, userID, unmutedBy primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unmuteLocked(matchID, userID)
+	return nil
+}
+
+type MemoryLiveScoreRepository struct {
+	mu         sync.RWMutex
+	scores     map[primitive.ObjectID]*LiveScore
+	mapResults []*MapResult
+}
+
+func NewMemoryLiveScoreRepository() *MemoryLiveScoreRepository {
+	return &MemoryLiveScoreRepository{scores: make(map[primitive.ObjectID]*LiveScore)}
+}
+
+func cloneLiveScore(score *LiveScore) *LiveScore {
+	copied := *score
+	copied.PlayerStats = append([]PlayerStatLine{}, score.PlayerStats...)
+	return &copied
+}
+
+func (r *MemoryLiveScoreRepository) Save(c context.Context, score *LiveScore) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.scores[score.MatchID] = cloneLiveScore(score)
+	return nil
+}
+
+func (r *MemoryLiveScoreRepository) GetByMatchID(c context.Context, matchID primitive.ObjectID) (*LiveScore, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	score, ok := r.scores[matchID]
+	if !ok {
+		return nil, ErrLiveScoreNotFound
+	}
+	return cloneLiveScore(score), nil
+}
+
+func (r *MemoryLiveScoreRepository) SaveFinal(c context.Context, match *Match, score *LiveScore, mapResult *MapResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.mapResults {
+		if existing.MatchID == mapResult.MatchID && existing.MapNumber == mapResult.MapNumber {
+			return ErrMapResultExists
+		}
+	}
+	if mapResult.ID.IsZero() {
+		mapResult.ID = primitive.NewObjectID()
+	}
+	copied := *mapResult
+	copied.PlayerStats = append([]PlayerStatLine{}, mapResult.PlayerStats...)
+	r.mapResults = append(r.mapResults, &copied)
+	r.scores[score.MatchID] = cloneLiveScore(score)
+
+	return nil
+}
+
+func (r *MemoryLiveScoreRepository) ListMapResults(c context.Context, matchID primitive.ObjectID) ([]*MapResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mapResults := []*MapResult{}
+	for _, mapResult := range r.mapResults {
+		if mapResult.MatchID == matchID {
+			copied := *mapResult
+			mapResults = append(mapResults, &copied)
+		}
+	}
+	sort.Slice(mapResults, func(i, j int) bool {
+		return mapResults[i].MapNumber < mapResults[j].MapNumber
+	})
+
+	return mapResults, nil
+}
+
+// Reads the published parts of the shared store
+type MemoryPublicReader struct {
+	store *MemoryStore
+}
+
+func NewMemoryPublicReader(store *MemoryStore) *MemoryPublicReader {
+	return &MemoryPublicReader{store: store}
+}
+
+func (r *MemoryPublicReader) ListPublishedTournaments(c context.Context, query ListQuery) (*Page[Tournament], error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var tournaments []*Tournament
+	for _, tournament := range r.store.tournaments {
+		if !tournament.deleted() && tournament.Published {
+			tournaments = append(tournaments, cloneTournament(tournament))
+		}
+	}
+	return listPage(tournaments, query)
+}
+
+func (r *MemoryPublicReader) GetPublishedTournament(c context.Context, id primitive.ObjectID) (*Tournament, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	tournament, ok := r.store.tournaments[id]
+	if !ok || tournament.deleted() || !tournament.Published {
+		return nil, ErrPublicTournamentNotFound
+	}
+	return cloneTournament(tournament), nil
+}
+
+func (r *MemoryPublicReader) ListTournamentTeams(c context.Context, tournament *Tournament) ([]*Team, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	teams := []*Team{}
+	for _, team := range r.store.teams {
+		if !team.deleted() && (team.TournamentID == tournament.ID || containsObjectID(tournament.Teams, team.ID)) {
+			teams = append(teams, cloneTeam(team))
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].Name != teams[j].Name {
+			return teams[i].Name < teams[j].Name
+		}
+		return teams[i].ID.Hex() < teams[j].ID.Hex()
+	})
+
+	return teams, nil
+}
+
+// Unscheduled matches sort first, like Mongo's missing dates
+func (r *MemoryPublicReader) ListTournamentMatches(c context.Context, tournamentID primitive.ObjectID) ([]*Match, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	matches := []*Match{}
+	for _, match := range r.store.matches {
+		if !match.deleted() && match.TournamentID == tournamentID {
+			matches = append(matches, cloneMatch(match))
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].Date.Equal(matches[j].Date) {
+			return matches[i].Date.Before(matches[j].Date)
+		}
+		return matches[i].ID.Hex() < matches[j].ID.Hex()
+	})
+
+	return matches, nil
+}
+
+func (r *MemoryPublicReader) LatestMatchResults(c context.Context, matchIDs []primitive.ObjectID) (map[primitive.ObjectID]*MatchResult, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	results := make(map[primitive.ObjectID]*MatchResult)
+	for _, matchResult := range r.store.matchResults {
+		if matchResult.deleted() || !containsObjectID(matchIDs, matchResult.MatchID) {
+			continue
+		}
+		if latest, ok := results[matchResult.MatchID]; !ok || latest.ID.Hex() < matchResult.ID.Hex() {
+			copied := *matchResult
+			results[matchResult.MatchID] = &copied
+		}
+	}
+	return results, nil
+}
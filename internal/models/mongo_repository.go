@@ -0,0 +1,840 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The Mongo repositories use the model functions for tournaments, teams, matches and
+// results, so writes still record their realtime events, and keep tournaments' team and
+// match lists up to date, in the same transaction. Deletes and restores cascade in one
+// transaction too.
+
+type MongoTournamentRepository struct{}
+
+func (MongoTournamentRepository) Create(c context.Context, tournament *Tournament) (*Tournament, error) {
+	return CreateTournament(c, tournament)
+}
+
+func (MongoTournamentRepository) GetByID(c context.Context, id primitive.ObjectID) (*Tournament, error) {
+	return GetTournamentByID(c, id)
+}
+
+func (MongoTournamentRepository) List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Tournament], error) {
+	return GetTournaments(c, scope, query)
+}
+
+func (MongoTournamentRepository) Update(c context.Context, id primitive.ObjectID, tournament *Tournament) error {
+	return UpdateTournament(c, id, tournament)
+}
+
+func (MongoTournamentRepository) Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return DeleteTournament(c, id, rules)
+}
+
+func (MongoTournamentRepository) GetDeletedByID(c context.Context, id primitive.ObjectID) (*Tournament, error) {
+	return GetDeletedTournamentByID(c, id)
+}
+
+func (MongoTournamentRepository) Restore(c context.Context, id primitive.ObjectID) error {
+	return RestoreTournament(c, id)
+}
+
+type MongoTeamRepository struct{}
+
+func (MongoTeamRepository) Create(c context.Context, team *Team) (*Team, error) {
+	return CreateTeam(c, team)
+}
+
+func (MongoTeamRepository) GetByID(c context.Context, id primitive.ObjectID) (*Team, error) {
+	return GetTeamByID(c, id)
+}
+
+func (MongoTeamRepository) List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Team], error) {
+	return GetTeams(c, scope, query)
+}
+
+func (MongoTeamRepository) Update(c context.Context, id primitive.ObjectID, team *Team) error {
+	return UpdateTeam(c, id, team)
+}
+
+func (MongoTeamRepository) Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return DeleteTeam(c, id, rules)
+}
+
+func (MongoTeamRepository) GetDeletedByID(c context.Context, id primitive.ObjectID) (*Team, error) {
+	return GetDeletedTeamByID(c, id)
+}
+
+func (MongoTeamRepository) Restore(c context.Context, id primitive.ObjectID) error {
+	return RestoreTeam(c, id)
+}
+
+type MongoMatchRepository struct{}
+
+func (MongoMatchRepository) Create(c context.Context, match *Match) (*Match, error) {
+	return CreateMatch(c, match)
+}
+
+func (MongoMatchRepository) GetByID(c context.Context, id primitive.ObjectID) (*Match, error) {
+	return GetMatchByID(c, id)
+}
+
+func (MongoMatchRepository) List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Match], error) {
+	return GetMatches(c, scope, query)
+}
+
+func (MongoMatchRepository) Update(c context.Context, id primitive.ObjectID, match *Match) error {
+	return UpdateMatch(c, id, match)
+}
+
+func (MongoMatchRepository) Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return DeleteMatch(c, id, rules)
+}
+
+func (MongoMatchRepository) GetDeletedByID(c context.Context, id primitive.ObjectID) (*Match, error) {
+	return GetDeletedMatchByID(c, id)
+}
+
+func (MongoMatchRepository) Restore(c context.Context, id primitive.ObjectID) error {
+	return RestoreMatch(c, id)
+}
+
+type MongoMatchResultRepository struct{}
+
+func (MongoMatchResultRepository) Create(c context.Context, matchResult *MatchResult) (*MatchResult, error) {
+	return CreateMatchResult(c, matchResult)
+}
+
+func (MongoMatchResultRepository) GetByID(c context.Context, id primitive.ObjectID) (*MatchResult, error) {
+	return GetMatchResultByID(c, id)
+}
+
+func (MongoMatchResultRepository) List(c context.Context, scope OwnerScope, query ListQuery) (*Page[MatchResult], error) {
+	return GetMatchResults(c, scope, query)
+}
+
+func (MongoMatchResultRepository) Update(c context.Context, id primitive.ObjectID, matchResult *MatchResult) error {
+	return UpdateMatchResult(c, id, matchResult)
+}
+
+func (MongoMatchResultRepository) Delete(c context.Context, id primitive.ObjectID) error {
+	return DeleteMatchResult(c, id)
+}
+
+func (MongoMatchResultRepository) GetDeletedByID(c context.Context, id primitive.ObjectID) (*MatchResult, error) {
+	return GetDeletedMatchResultByID(c, id)
+}
+
+func (MongoMatchResultRepository) Restore(c context.Context, id primitive.ObjectID) error {
+	return RestoreMatchResult(c, id)
+}
+
+type MongoPurger struct{}
+
+func (MongoPurger) PurgeDeleted(c context.Context, before time.Time) (int64, error) {
+	return PurgeDeleted(c, before)
+}
+
+type MongoSearcher struct{}
+
+func (MongoSearcher) Search(c context.Context, scope OwnerScope, query SearchQuery) (*SearchResults, error) {
+	return SearchMongo(c, scope, query)
+}
+
+type MongoUserRepository struct{}
+
+func usersCollection() *mongo.Collection {
+	return database.GetMongoClient().Database("esports-tournament-manager").Collection("users")
+}
+
+func (MongoUserRepository) Create(c context.Context, user *User) error {
+	user.created()
+	result, err := usersCollection().InsertOne(c, user)
+	if err != nil {
+		// The unique email index catches registrations that race past the check
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrEmailInUse
+		}
+		return err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+
+	return nil
+}
+
+func (r MongoUserRepository) GetByID(c context.Context, id primitive.ObjectID) (*User, error) {
+	return r.findOne(c, bson.M{"_id": id})
+}
+
+func (r MongoUserRepository) GetByEmail(c context.Context, email string) (*User, error) {
+	return r.findOne(c, bson.M{"email": email})
+}
+
+func (MongoUserRepository) findOne(c context.Context, filter bson.M) (*User, error) {
+	var user User
+	err := usersCollection().FindOne(c, filter).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (MongoUserRepository) Update(c context.Context, user *User) error {
+	user.updated()
+	_, err := usersCollection().UpdateOne(c, bson.M{"_id": user.ID}, bson.M{"$set": user})
+	return err
+}
+
+type MongoOrganisationRepository struct{}
+
+func (MongoOrganisationRepository) Create(c context.Context, organisation *Organisation) error {
+	result, err := organisationsCollection().InsertOne(c, organisation)
+	if err != nil {
+		return err
+	}
+	organisation.ID = result.InsertedID.(primitive.ObjectID)
+
+	return nil
+}
+
+func (MongoOrganisationRepository) GetByID(c context.Context, id primitive.ObjectID) (*Organisation, error) {
+	var organisation Organisation
+	err := organisationsCollection().FindOne(c, bson.M{"_id": id}).Decode(&organisation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrOrganisationNotFound
+		}
+		return nil, err
+	}
+
+	return &organisation, nil
+}
+
+func (MongoOrganisationRepository) ListForUser(c context.Context, userID primitive.ObjectID) ([]*Organisation, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := organisationsCollection().Find(c, bson.M{"members.user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(c)
+
+	organisations := []*Organisation{}
+	for cursor.Next(c) {
+		var organisation Organisation
+		if err := cursor.Decode(&organisation); err != nil {
+			return nil, err
+		}
+		organisations = append(organisations, &organisation)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return organisations, nil
+}
+
+// Only pushes the member if they aren't already in the organisation
+func (MongoOrganisationRepository) AddMember(c context.Context, organisationID primitive.ObjectID, member OrganisationMember) error {
+	filter := bson.M{"_id": organisationID, "members.user_id": bson.M{"$ne": member.UserID}}
+	result, err := organisationsCollection().UpdateOne(c, filter, touched(bson.M{"$push": bson.M{"members": member}}))
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrAlreadyOrganisationMember
+	}
+
+	return nil
+}
+
+func (MongoOrganisationRepository) UpdateMemberRole(c context.Context, organisationID, userID primitive.ObjectID, role string) error {
+	filter := bson.M{"_id": organisationID, "members.user_id": userID}
+	_, err := organisationsCollection().UpdateOne(c, filter, touched(bson.M{"$set": bson.M{"members.$.role": role}}))
+	return err
+}
+
+func (MongoOrganisationRepository) RemoveMember(c context.Context, organisationID, userID primitive.ObjectID) error {
+	_, err := organisationsCollection().UpdateOne(c, bson.M{"_id": organisationID}, touched(bson.M{"$pull": bson.M{"members": bson.M{"user_id": userID}}}))
+	return err
+}
+
+// Finds every document matching the filter, or none
+func findAll[T any](c context.Context, collection *mongo.Collection, filter bson.M, opts ...*options.FindOptions) ([]*T, error) {
+	cursor, err := collection.Find(c, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(c)
+
+	documents := []*T{}
+	if err := cursor.All(c, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+func (r MongoUserRepository) GetByIdentity(c context.Context, provider, subject string) (*User, error) {
+	return r.findOne(c, bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}})
+}
+
+// Applies an update to one user, failing with ErrUserNotFound if the filter matches nobody
+func (MongoUserRepository) updateOne(c context.Context, filter, update bson.M) error {
+	result, err := usersCollection().UpdateOne(c, filter, touched(update))
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r MongoUserRepository) AddIdentity(c context.Context, userID primitive.ObjectID, identity UserIdentity) error {
+	return r.updateOne(c, bson.M{"_id": userID}, bson.M{"$push": bson.M{"identities": identity}})
+}
+
+func (r MongoUserRepository) RemoveIdentity(c context.Context, userID primitive.ObjectID, provider string) error {
+	return r.updateOne(c, bson.M{"_id": userID}, bson.M{"$pull": bson.M{"identities": bson.M{"provider": provider}}})
+}
+
+func (r MongoUserRepository) VerifyEmail(c context.Context, userID primitive.ObjectID) error {
+	return r.updateOne(c, bson.M{"_id": userID}, bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": utcNow()}})
+}
+
+// Following a reset link proves the user owns the address, so it is verified too
+func (r MongoUserRepository) ResetPassword(c context.Context, userID primitive.ObjectID, passwordHash string) error {
+	return r.updateOne(c, bson.M{"_id": userID}, bson.M{"$set": bson.M{"password": passwordHash, "email_verified": true}})
+}
+
+func (r MongoUserRepository) SetTwoFactorPendingSecret(c context.Context, userID primitive.ObjectID, secret string) error {
+	return r.updateOne(c, bson.M{"_id": userID}, bson.M{"$set": bson.M{"two_factor_pending_secret": secret}})
+}
+
+func (r MongoUserRepository) EnableTwoFactor(c context.Context, userID primitive.ObjectID, pendingSecret string, recoveryCodeHashes []string, step int64) error {
+	update := bson.M{
+		"$set": bson.M{
+			"two_factor_enabled":        true,
+			"two_factor_secret":         pendingSecret,
+			"two_factor_recovery_codes": recoveryCodeHashes,
+			"two_factor_last_step":      step,
+		},
+		"$unset": bson.M{"two_factor_pending_secret": ""},
+	}
+	err := r.updateOne(c, bson.M{"_id": userID, "two_factor_pending_secret": pendingSecret}, update)
+	if err == ErrUserNotFound {
+		return ErrTwoFactorNotSetUp
+	}
+	return err
+}
+
+func (r MongoUserRepository) DisableTwoFactor(c context.Context, userID primitive.ObjectID) error {
+	update := bson.M{
+		"$set": bson.M{"two_factor_enabled": false},
+		"$unset": bson.M{
+			"two_factor_secret":         "",
+			"two_factor_recovery_codes": "",
+			"two_factor_last_step":      "",
+		},
+	}
+	return r.updateOne(c, bson.M{"_id": userID}, update)
+}
+
+func (r MongoUserRepository) SetRecoveryCodes(c context.Context, userID primitive.ObjectID, recoveryCodeHashes []string) error {
+	return r.updateOne(c, bson.M{"_id": userID}, bson.M{"$set": bson.M{"two_factor_recovery_codes": recoveryCodeHashes}})
+}
+
+func (MongoUserRepository) UseTwoFactorStep(c context.Context, userID primitive.ObjectID, step int64) (bool, error) {
+	filter := bson.M{"_id": userID, "$or": bson.A{
+		bson.M{"two_factor_last_step": bson.M{"$lt": step}},
+		bson.M{"two_factor_last_step": bson.M{"$exists": false}},
+	}}
+	result, err := usersCollection().UpdateOne(c, filter, bson.M{"$set": bson.M{"two_factor_last_step": step}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (MongoUserRepository) UseRecoveryCode(c context.Context, userID primitive.ObjectID, codeHash string) (bool, error) {
+	filter := bson.M{"_id": userID, "two_factor_recovery_codes": codeHash}
+	result, err := usersCollection().UpdateOne(c, filter, bson.M{"$pull": bson.M{"two_factor_recovery_codes": codeHash}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+type MongoSessionRepository struct{}
+
+func (MongoSessionRepository) Create(c context.Context, session *Session) error {
+	_, err := collectionNamed("sessions").InsertOne(c, session)
+	return err
+}
+
+func (MongoSessionRepository) GetByID(c context.Context, id primitive.ObjectID) (*Session, error) {
+	var session Session
+	if err := collectionNamed("sessions").FindOne(c, bson.M{"_id": id}).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (MongoSessionRepository) Rotate(c context.Context, session *Session, previousHash string) error {
+	filter := bson.M{"_id": session.ID, "refresh_token_hash": previousHash, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{
+		"refresh_token_hash": session.RefreshTokenHash,
+		"last_used_at":       session.LastUsedAt,
+		"expires_at":         session.ExpiresAt,
+		"user_agent":         session.UserAgent,
+		"ip_address":         session.IPAddress,
+	}}
+	result, err := collectionNamed("sessions").UpdateOne(c, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+func (MongoSessionRepository) Revoke(c context.Context, filter SessionFilter) error {
+	query := bson.M{"revoked_at": bson.M{"$exists": false}}
+	if !filter.ID.IsZero() {
+		query["_id"] = filter.ID
+	}
+	if !filter.UserID.IsZero() {
+		query["user_id"] = filter.UserID
+	}
+	if filter.RefreshTokenHash != "" {
+		query["refresh_token_hash"] = filter.RefreshTokenHash
+	}
+
+	_, err := collectionNamed("sessions").UpdateMany(c, query, bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	return err
+}
+
+func activeSessionFilter(filter bson.M) bson.M {
+	filter["revoked_at"] = bson.M{"$exists": false}
+	filter["expires_at"] = bson.M{"$gt": time.Now().UTC()}
+	return filter
+}
+
+// Most recently used first
+func (MongoSessionRepository) ListActive(c context.Context, userID primitive.ObjectID) ([]*Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+	return findAll[Session](c, collectionNamed("sessions"), activeSessionFilter(bson.M{"user_id": userID}), opts)
+}
+
+func (MongoSessionRepository) IsActive(c context.Context, id primitive.ObjectID) (bool, error) {
+	count, err := collectionNamed("sessions").CountDocuments(c, activeSessionFilter(bson.M{"_id": id}))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+type MongoAccountTokenRepository struct{}
+
+func (MongoAccountTokenRepository) Replace(c context.Context, token *AccountToken) error {
+	collection := collectionNamed("account_tokens")
+
+	filter := bson.M{"user_id": token.UserID, "purpose": token.Purpose, "used_at": bson.M{"$exists": false}}
+	if _, err := collection.UpdateMany(c, filter, bson.M{"$set": bson.M{"used_at": time.Now().UTC()}}); err != nil {
+		return err
+	}
+
+	result, err := collection.InsertOne(c, token)
+	if err != nil {
+		return err
+	}
+	token.ID = result.InsertedID.(primitive.ObjectID)
+
+	return nil
+}
+
+func usableAccountTokenFilter(tokenHash, purpose string) bson.M {
+	return bson.M{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now().UTC()},
+	}
+}
+
+func (MongoAccountTokenRepository) Find(c context.Context, tokenHash, purpose string) (*AccountToken, error) {
+	var accountToken AccountToken
+	if err := collectionNamed("account_tokens").FindOne(c, usableAccountTokenFilter(tokenHash, purpose)).Decode(&accountToken); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidAccountToken
+		}
+		return nil, err
+	}
+	return &accountToken, nil
+}
+
+func (MongoAccountTokenRepository) Consume(c context.Context, tokenHash, purpose string) (*AccountToken, error) {
+	update := bson.M{"$set": bson.M{"used_at": time.Now().UTC()}}
+
+	var accountToken AccountToken
+	err := collectionNamed("account_tokens").FindOneAndUpdate(c, usableAccountTokenFilter(tokenHash, purpose), update).Decode(&accountToken)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidAccountToken
+		}
+		return nil, err
+	}
+	return &accountToken, nil
+}
+
+type MongoLoginAttemptRepository struct{}
+
+func (MongoLoginAttemptRepository) ListLocked(c context.Context, keys []string) ([]*LoginAttempt, error) {
+	filter := bson.M{"_id": bson.M{"$in": keys}, "locked_until": bson.M{"$gt": time.Now().UTC()}}
+	return findAll[LoginAttempt](c, collectionNamed("login_attempts"), filter)
+}
+
+// Counts the failure in one write, so concurrent failures are all counted
+func (MongoLoginAttemptRepository) RecordFailure(c context.Context, key string, window time.Duration) (*LoginAttempt, error) {
+	now := time.Now().UTC()
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"failures": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$last_failure_at", now.Add(-window)}},
+			bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+			1,
+		}},
+		"last_failure_at": now,
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var attempt LoginAttempt
+	if err := collectionNamed("login_attempts").FindOneAndUpdate(c, bson.M{"_id": key}, update, opts).Decode(&attempt); err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (MongoLoginAttemptRepository) Lock(c context.Context, key string, until time.Time) error {
+	_, err := collectionNamed("login_attempts").UpdateOne(c, bson.M{"_id": key}, bson.M{"$set": bson.M{"locked_until": until}})
+	return err
+}
+
+func (MongoLoginAttemptRepository) Clear(c context.Context, key string) error {
+	_, err := collectionNamed("login_attempts").DeleteOne(c, bson.M{"_id": key})
+	return err
+}
+
+type MongoAuditLogRepository struct{}
+
+func (MongoAuditLogRepository) Create(c context.Context, entry *AuditLog) error {
+	result, err := collectionNamed("audit_logs").InsertOne(c, entry)
+	if err != nil {
+		return err
+	}
+	entry.ID = result.InsertedID.(primitive.ObjectID)
+
+	return nil
+}
+
+type MongoAPIKeyRepository struct{}
+
+func (MongoAPIKeyRepository) Create(c context.Context, apiKey *APIKey) error {
+	_, err := collectionNamed("api_keys").InsertOne(c, apiKey)
+	return err
+}
+
+func (MongoAPIKeyRepository) GetByID(c context.Context, id primitive.ObjectID) (*APIKey, error) {
+	var apiKey APIKey
+	if err := collectionNamed("api_keys").FindOne(c, bson.M{"_id": id}).Decode(&apiKey); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// Newest first
+func (MongoAPIKeyRepository) ListActive(c context.Context, userID primitive.ObjectID) ([]*APIKey, error) {
+	filter := bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return findAll[APIKey](c, collectionNamed("api_keys"), filter, opts)
+}
+
+func (MongoAPIKeyRepository) Revoke(c context.Context, userID, id primitive.ObjectID) error {
+	filter := bson.M{"_id": id, "user_id": userID, "revoked_at": bson.M{"$exists": false}}
+	result, err := collectionNamed("api_keys").UpdateOne(c, filter, bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (MongoAPIKeyRepository) MarkUsed(c context.Context, id primitive.ObjectID, interval time.Duration) error {
+	now := time.Now().UTC()
+	filter := bson.M{"_id": id, "$or": bson.A{
+		bson.M{"last_used_at": bson.M{"$exists": false}},
+		bson.M{"last_used_at": bson.M{"$lt": now.Add(-interval)}},
+	}}
+	_, err := collectionNamed("api_keys").UpdateOne(c, filter, bson.M{"$set": bson.M{"last_used_at": now}})
+	return err
+}
+
+type MongoOIDCStateRepository struct{}
+
+func (MongoOIDCStateRepository) Create(c context.Context, state *OIDCLoginState) error {
+	_, err := collectionNamed("oidc_login_states").InsertOne(c, state)
+	return err
+}
+
+func (MongoOIDCStateRepository) Take(c context.Context, stateHash, provider string) (*OIDCLoginState, error) {
+	filter := bson.M{
+		"state_hash": stateHash,
+		"provider":   provider,
+		"expires_at": bson.M{"$gt": time.Now().UTC()},
+	}
+
+	var state OIDCLoginState
+	if err := collectionNamed("oidc_login_states").FindOneAndDelete(c, filter).Decode(&state); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Chat events are only published on the private chat topic, never the public match topic
+type MongoChatRepository struct{}
+
+func (MongoChatRepository) CreateMessage(c context.Context, chatMessage *ChatMessage) error {
+	return withTransaction(c, func(sc mongo.SessionContext) error {
+		result, err := collectionNamed("chat_messages").InsertOne(sc, chatMessage)
+		if err != nil {
+			return err
+		}
+		chatMessage.ID = result.InsertedID.(primitive.ObjectID)
+
+		message := map[string]interface{}{
+			"action":     "chat_message_created",
+			"message_id": chatMessage.ID.Hex(),
+			"match_id":   chatMessage.MatchID.Hex(),
+			"user_id":    chatMessage.UserID.Hex(),
+			"username":   chatMessage.Username,
+			"body":       chatMessage.Body,
+			"created_at": chatMessage.CreatedAt,
+		}
+
+		return recordOutboxEventForTopics(sc, message, []string{ChatTopic(chatMessage.MatchID)})
+	})
+}
+
+// Newest first, starting before the given message if there is one
+func (MongoChatRepository) ListMessages(c context.Context, matchID, before primitive.ObjectID, limit int) ([]*ChatMessage, error) {
+	filter := bson.M{"match_id": matchID}
+	if !before.IsZero() {
+		filter["_id"] = bson.M{"$lt": before}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit))
+	return findAll[ChatMessage](c, collectionNamed("chat_messages"), filter, opts)
+}
+
+func (MongoChatRepository) DeleteMessage(c context.Context, matchID, messageID, deletedBy primitive.ObjectID) error {
+	return withTransaction(c, func(sc mongo.SessionContext) error {
+		update := bson.M{"$set": bson.M{"body": "", "deleted_at": time.Now().UTC(), "deleted_by": deletedBy}}
+		result, err := collectionNamed("chat_messages").UpdateOne(sc, bson.M{"_id": messageID, "match_id": matchID}, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrChatMessageNotFound
+		}
+
+		message := map[string]interface{}{
+			"action":     "chat_message_deleted",
+			"message_id": messageID.Hex(),
+			"match_id":   matchID.Hex(),
+			"deleted_by": deletedBy.Hex(),
+		}
+
+		return recordOutboxEventForTopics(sc, message, []string{ChatTopic(matchID)})
+	})
+}
+
+func (MongoChatRepository) GetActiveMute(c context.Context, matchID, userID primitive.ObjectID) (*ChatMute, error) {
+	var mute ChatMute
+	filter := bson.M{"match_id": matchID, "user_id": userID, "until": bson.M{"$gt": time.Now().UTC()}}
+	if err := collectionNamed("chat_mutes").FindOne(c, filter).Decode(&mute); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mute, nil
+}
+
+// A new mute replaces any existing one for the user
+func (MongoChatRepository) Mute(c context.Context, mute *ChatMute) error {
+	return withTransaction(c, func(sc mongo.SessionContext) error {
+		filter := bson.M{"match_id": mute.MatchID, "user_id": mute.UserID}
+		opts := options.Replace().SetUpsert(true)
+		if _, err := collectionNamed("chat_mutes").ReplaceOne(sc, filter, mute, opts); err != nil {
+			return err
+		}
+
+		message := map[string]interface{}{
+			"action":   "chat_user_muted",
+			"match_id": mute.MatchID.Hex(),
+			"user_id":  mute.UserID.Hex(),
+			"muted_by": mute.MutedBy.Hex(),
+			"until":    mute.Until,
+		}
+
+		return recordOutboxEventForTopics(sc, message, []string{ChatTopic(mute.MatchID)})
+	})
+}
+
+func (MongoChatRepository) Unmute(c context.Context, matchID, userID, unmutedBy primitive.ObjectID) error {
+	return withTransaction(c, func(sc mongo.SessionContext) error {
+		if _, err := collectionNamed("chat_mutes").DeleteOne(sc, bson.M{"match_id": matchID, "user_id": userID}); err != nil {
+			return err
+		}
+
+		message := map[string]interface{}{
+			"action":     "chat_user_unmuted",
+			"match_id":   matchID.Hex(),
+			"user_id":    userID.Hex(),
+			"unmuted_by": unmutedBy.Hex(),
+		}
+
+		return recordOutboxEventForTopics(sc, message, []string{ChatTopic(matchID)})
+	})
+}
+
+type MongoLiveScoreRepository struct{}
+
+// Replaces the match's previous live score
+func (MongoLiveScoreRepository) Save(c context.Context, score *LiveScore) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := collectionNamed("live_scores").ReplaceOne(c, bson.M{"match_id": score.MatchID}, score, opts)
+	return err
+}
+
+func (MongoLiveScoreRepository) GetByMatchID(c context.Context, matchID primitive.ObjectID) (*LiveScore, error) {
+	var score LiveScore
+	if err := collectionNamed("live_scores").FindOne(c, bson.M{"match_id": matchID}).Decode(&score); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrLiveScoreNotFound
+		}
+		return nil, err
+	}
+	return &score, nil
+}
+
+func (MongoLiveScoreRepository) SaveFinal(c context.Context, match *Match, score *LiveScore, mapResult *MapResult) error {
+	collection := collectionNamed("map_results")
+
+	return withTransaction(c, func(sc mongo.SessionContext) error {
+		count, err := collection.CountDocuments(sc, bson.M{"match_id": mapResult.MatchID, "map_number": mapResult.MapNumber})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrMapResultExists
+		}
+
+		result, err := collection.InsertOne(sc, mapResult)
+		if err != nil {
+			return err
+		}
+		mapResult.ID = result.InsertedID.(primitive.ObjectID)
+
+		opts := options.Replace().SetUpsert(true)
+		if _, err := collectionNamed("live_scores").ReplaceOne(sc, bson.M{"match_id": score.MatchID}, score, opts); err != nil {
+			return err
+		}
+
+		if err := recordOutboxEvent(sc, score.Message()); err != nil {
+			return err
+		}
+		return recordOutboxEvent(sc, mapResult.createdMessage(match))
+	})
+}
+
+// In map order
+func (MongoLiveScoreRepository) ListMapResults(c context.Context, matchID primitive.ObjectID) ([]*MapResult, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "map_number", Value: 1}})
+	return findAll[MapResult](c, collectionNamed("map_results"), bson.M{"match_id": matchID}, opts)
+}
+
+type MongoPublicReader struct{}
+
+func (MongoPublicReader) ListPublishedTournaments(c context.Context, query ListQuery) (*Page[Tournament], error) {
+	return findPage[Tournament](c, collectionNamed("tournaments"), notDeleted(bson.M{"published": true}), query)
+}
+
+func (MongoPublicReader) GetPublishedTournament(c context.Context, id primitive.ObjectID) (*Tournament, error) {
+	var tournament Tournament
+	err := collectionNamed("tournaments").FindOne(c, notDeleted(bson.M{"_id": id, "published": true})).Decode(&tournament)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrPublicTournamentNotFound
+		}
+		return nil, err
+	}
+	return &tournament, nil
+}
+
+func (MongoPublicReader) ListTournamentTeams(c context.Context, tournament *Tournament) ([]*Team, error) {
+	filter := notDeleted(bson.M{"$or": bson.A{
+		bson.M{"tournament_id": tournament.ID},
+		bson.M{"_id": bson.M{"$in": append([]primitive.ObjectID{}, tournament.Teams...)}},
+	}})
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	return findAll[Team](c, collectionNamed("teams"), filter, opts)
+}
+
+// Unscheduled matches sort first, as Mongo puts missing dates before any date
+func (MongoPublicReader) ListTournamentMatches(c context.Context, tournamentID primitive.ObjectID) ([]*Match, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}})
+	return findAll[Match](c, collectionNamed("matches"), notDeleted(bson.M{"tournament_id": tournamentID}), opts)
+}
+
+func (MongoPublicReader) LatestMatchResults(c context.Context, matchIDs []primitive.ObjectID) (map[primitive.ObjectID]*MatchResult, error) {
+	results := make(map[primitive.ObjectID]*MatchResult)
+	if len(matchIDs) == 0 {
+		return results, nil
+	}
+
+	// Sorted oldest first so later results replace earlier ones
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	matchResults, err := findAll[MatchResult](c, collectionNamed("match_results"), notDeleted(bson.M{"match_id": bson.M{"$in": matchIDs}}), opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range matchResults {
+		results[result.MatchID] = result
+	}
+
+	return results, nil
+}
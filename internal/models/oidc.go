@@ -0,0 +1,242 @@
+package models
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How long a user has to finish logging in at the provider
+const OIDCLoginStateLifetime = 10 * time.Minute
+
+// An account at an external login provider, linked to a user
+type UserIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"subject"`
+	Email    string    `bson:"email,omitempty" json:"email,omitempty"`
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
+}
+
+// A login in progress at a provider. Only hashes of the state sent to the provider and of
+// the nonce are stored. The nonce itself is kept in a cookie in the browser that started the
+// login, so the login can only be finished in that browser.
+type OIDCLoginState struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	StateHash    string             `bson:"state_hash"`
+	Provider     string             `bson:"provider"`
+	NonceHash    string             `bson:"nonce_hash"`
+	CodeVerifier string             `bson:"code_verifier"`
+	// Set when a signed in user is linking the provider to their account
+	LinkUserID *primitive.ObjectID `bson:"link_user_id,omitempty"`
+	ExpiresAt  time.Time           `bson:"expires_at"`
+}
+
+var (
+	ErrInvalidOIDCState      = errors.New("Login has expired or was already used, please try again")
+	ErrIdentityAlreadyLinked = errors.New("This account is already linked to another user")
+	ErrOIDCEmailInUse        = errors.New("An account with this email already exists, log in and link the provider from your account")
+	ErrProviderNotLinked     = errors.New("Provider is not linked")
+	ErrLastLoginMethod       = errors.New("Set a password before unlinking your only login provider")
+	ErrOIDCLinkUserMismatch  = errors.New("Log in as the user who started linking the provider and try again")
+)
+
+var usernameCleaner = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// Starts a login at a provider, returning the URL to send the user to and the nonce the
+// browser must send back with the callback. Pass the signed in user's ID to link the
+// provider to their account instead.
+func StartOIDCLogin(c context.Context, states OIDCStateRepository, provider *auth.OIDCProvider, linkUserID *primitive.ObjectID) (string, string, error) {
+	state, err := auth.GenerateOIDCRandom()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := auth.GenerateOIDCRandom()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := auth.GenerateOIDCRandom()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(c, state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	loginState := &OIDCLoginState{
+		StateHash:    hashToken(state),
+		Provider:     provider.Name,
+		NonceHash:    hashToken(nonce),
+		CodeVerifier: codeVerifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().UTC().Add(OIDCLoginStateLifetime),
+	}
+	if err := states.Create(c, loginState); err != nil {
+		return "", "", err
+	}
+
+	return authURL, nonce, nil
+}
+
+// Finishes a login when the provider sends the user back. nonce is the one the browser
+// kept when the login started, and currentUserID the user signed in to the callback
+// request, if any. Logs in the user the identity is linked to, links it to a matching
+// verified account, or creates a new account. When the login was started to link an
+// account, the identity is linked, as long as the same user is still signed in, and no
+// session is started.
+func FinishOIDCLogin(c context.Context, repos *Repositories, provider *auth.OIDCProvider, code, state, nonce string, currentUserID primitive.ObjectID, client ClientInfo) (*LoginResult, error) {
+	if code == "" || state == "" || nonce == "" {
+		return nil, ErrInvalidOIDCState
+	}
+
+	// Each state can only be used once
+	loginState, err := repos.OIDCStates.Take(c, hashToken(state), provider.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// A callback sent to a browser that didn't start the login is refused
+	if subtle.ConstantTimeCompare([]byte(hashToken(nonce)), []byte(loginState.NonceHash)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+	if loginState.LinkUserID != nil && *loginState.LinkUserID != currentUserID {
+		return nil, ErrOIDCLinkUserMismatch
+	}
+
+	identity, err := provider.Exchange(c, code, loginState.CodeVerifier, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if loginState.LinkUserID != nil {
+		if err := linkIdentity(c, repos.Users, *loginState.LinkUserID, identity); err != nil {
+			return nil, err
+		}
+		return &LoginResult{}, nil
+	}
+
+	user, err := findOrCreateOIDCUser(c, repos.Users, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled {
+		return startTwoFactorLogin(c, repos.AccountTokens, user)
+	}
+
+	return startLoginSession(c, repos, user, client)
+}
+
+// Gets the user a provider identity is linked to, linking or creating one if needed
+func findOrCreateOIDCUser(c context.Context, users UserRepository, identity *auth.OIDCIdentity) (*User, error) {
+	user, err := users.GetByIdentity(c, identity.Provider, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if err != ErrUserNotFound {
+		return nil, err
+	}
+
+	if identity.Email != "" {
+		existing, err := users.GetByEmail(c, identity.Email)
+		if err != nil && err != ErrUserNotFound {
+			return nil, err
+		}
+		if existing != nil {
+			// Only link automatically when both sides have proven they own the address
+			if !identity.EmailVerified || !existing.EmailVerified {
+				return nil, ErrOIDCEmailInUse
+			}
+			if err := linkIdentity(c, users, existing.ID, identity); err != nil {
+				return nil, err
+			}
+			return users.GetByID(c, existing.ID)
+		}
+	}
+
+	now := time.Now().UTC()
+	newUser := &User{
+		Username:      oidcUsername(identity),
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Identities: []UserIdentity{{
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+			LinkedAt: now,
+		}},
+	}
+	if identity.EmailVerified {
+		newUser.EmailVerifiedAt = &now
+	}
+
+	// Users created this way have no password, so they can only log in through the provider
+	if err := users.Create(c, newUser); err != nil {
+		if err == ErrEmailInUse {
+			return nil, ErrOIDCEmailInUse
+		}
+		return nil, err
+	}
+
+	return newUser, nil
+}
+
+// Picks a username for a new user from what the provider knows about them
+func oidcUsername(identity *auth.OIDCIdentity) string {
+	for _, candidate := range []string{identity.PreferredUsername, identity.Name, strings.Split(identity.Email, "@")[0]} {
+		if username := usernameCleaner.ReplaceAllString(strings.TrimSpace(candidate), "_"); strings.Trim(username, "_") != "" {
+			return username
+		}
+	}
+	return identity.Provider + "_" + identity.Subject
+}
+
+// Links a provider identity to a user, unless another user already has it
+func linkIdentity(c context.Context, users UserRepository, userID primitive.ObjectID, identity *auth.OIDCIdentity) error {
+	owner, err := users.GetByIdentity(c, identity.Provider, identity.Subject)
+	if err == nil {
+		if owner.ID == userID {
+			return nil
+		}
+		return ErrIdentityAlreadyLinked
+	}
+	if err != ErrUserNotFound {
+		return err
+	}
+
+	linked := UserIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: time.Now().UTC(),
+	}
+	return users.AddIdentity(c, userID, linked)
+}
+
+// Unlinks a provider from a user, as long as they can still log in some other way
+func UnlinkIdentity(c context.Context, users UserRepository, user *User, provider string) error {
+	remaining := 0
+	found := false
+	for _, identity := range user.Identities {
+		if identity.Provider == provider {
+			found = true
+		} else {
+			remaining++
+		}
+	}
+	if !found {
+		return ErrProviderNotLinked
+	}
+	if user.Password == "" && remaining == 0 {
+		return ErrLastLoginMethod
+	}
+
+	return users.RemoveIdentity(c, user.ID, provider)
+}
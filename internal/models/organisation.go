@@ -0,0 +1,292 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Roles a member can hold in an organisation. Staff run tournaments, teams and matches,
+// admins also manage members and owners also manage admins and owners.
+const (
+	OrganisationRoleOwner = "owner"
+	OrganisationRoleAdmin = "admin"
+	OrganisationRoleStaff = "staff"
+)
+
+// A group of staff that owns tournaments, teams and matches together
+type Organisation struct {
+	ID        primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name      string               `bson:"name" json:"name"`
+	Members   []OrganisationMember `bson:"members" json:"members"`
+	CreatedAt time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// A user's membership of an organisation
+type OrganisationMember struct {
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Role     string             `bson:"role" json:"role"`
+	JoinedAt time.Time          `bson:"joined_at" json:"joined_at"`
+}
+
+var (
+	ErrOrganisationNotFound    = errors.New("Organisation not found")
+	ErrNotOrganisationMember   = errors.New("You are not a member of this organisation")
+	ErrOrganisationForbidden   = errors.New("Your role in this organisation does not allow this")
+	ErrInvalidOrganisationRole = errors.New("Role must be owner, admin or staff")
+	ErrLastOrganisationOwner   = errors.New("An organisation must keep at least one owner")
+	ErrOrganisationNameMissing = errors.New("Organisation name is required")
+	// Returned when changing or removing someone who isn't in the organisation
+	ErrOrganisationMemberNotFound = errors.New("User is not a member of this organisation")
+	// Returned by OrganisationRepository.AddMember when the user already belongs to the organisation
+	ErrAlreadyOrganisationMember = errors.New("User is already a member of this organisation")
+)
+
+func organisationsCollection() *mongo.Collection {
+	return database.GetMongoClient().Database("esports-tournament-manager").Collection("organisations")
+}
+
+// Reports whether a role is one organisation members can hold
+func validOrganisationRole(role string) bool {
+	switch role {
+	case OrganisationRoleOwner, OrganisationRoleAdmin, OrganisationRoleStaff:
+		return true
+	}
+	return false
+}
+
+// Ranks roles so they can be compared, higher can do more
+func organisationRoleRank(role string) int {
+	switch role {
+	case OrganisationRoleOwner:
+		return 3
+	case OrganisationRoleAdmin:
+		return 2
+	case OrganisationRoleStaff:
+		return 1
+	}
+	return 0
+}
+
+// Gets the role a user holds in an organisation, or an empty string if they aren't a member
+func (o *Organisation) RoleOf(userID primitive.ObjectID) string {
+	for _, member := range o.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+func (o *Organisation) ownerCount() int {
+	count := 0
+	for _, member := range o.Members {
+		if member.Role == OrganisationRoleOwner {
+			count++
+		}
+	}
+	return count
+}
+
+// Creates an organisation with its creator as the owner
+func CreateOrganisation(c context.Context, organisations OrganisationRepository, name string, ownerID primitive.ObjectID) (*Organisation, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrOrganisationNameMissing
+	}
+
+	now := time.Now().UTC()
+	organisation := &Organisation{
+		Name:      name,
+		Members:   []OrganisationMember{{UserID: ownerID, Role: OrganisationRoleOwner, JoinedAt: now}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := organisations.Create(c, organisation); err != nil {
+		return nil, err
+	}
+
+	return organisation, nil
+}
+
+// Gets the role a user holds in an organisation, returning ErrNotOrganisationMember if they hold none
+func GetOrganisationRole(c context.Context, organisations OrganisationRepository, organisationID, userID primitive.ObjectID) (string, error) {
+	organisation, err := organisations.GetByID(c, organisationID)
+	if err != nil {
+		return "", err
+	}
+
+	role := organisation.RoleOf(userID)
+	if role == "" {
+		return "", ErrNotOrganisationMember
+	}
+	return role, nil
+}
+
+// Reports whether a user can manage something owned by an organisation. Anything created
+// before organisations existed has no organisation and is still managed by its organiser.
+func CanManageResource(c context.Context, organisations OrganisationRepository, organisationID, organiserID, userID primitive.ObjectID) (bool, error) {
+	if organisationID.IsZero() {
+		return organiserID == userID, nil
+	}
+
+	_, err := GetOrganisationRole(c, organisations, organisationID, userID)
+	if err != nil {
+		if err == ErrNotOrganisationMember || err == ErrOrganisationNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Gets the scope of documents a user can list in their organisations, including documents
+// from before organisations that they organise, or in just one organisation if organisationID is set
+func GetOwnerScope(c context.Context, organisations OrganisationRepository, userID, organisationID primitive.ObjectID) (OwnerScope, error) {
+	if !organisationID.IsZero() {
+		if _, err := GetOrganisationRole(c, organisations, organisationID, userID); err != nil {
+			return OwnerScope{}, err
+		}
+		return OwnerScope{OrganisationIDs: []primitive.ObjectID{organisationID}}, nil
+	}
+
+	memberOf, err := organisations.ListForUser(c, userID)
+	if err != nil {
+		return OwnerScope{}, err
+	}
+
+	scope := OwnerScope{OrganisationIDs: []primitive.ObjectID{}, OrganiserID: userID}
+	for _, organisation := range memberOf {
+		scope.OrganisationIDs = append(scope.OrganisationIDs, organisation.ID)
+	}
+	return scope, nil
+}
+
+// Adds a user to an organisation. Only admins and owners can add members, and only owners can add owners.
+func AddOrganisationMember(c context.Context, organisations OrganisationRepository, users UserRepository, organisationID, actorID, userID primitive.ObjectID, role string) error {
+	if !validOrganisationRole(role) {
+		return ErrInvalidOrganisationRole
+	}
+
+	organisation, err := organisations.GetByID(c, organisationID)
+	if err != nil {
+		return err
+	}
+	if err := checkCanGrant(organisation, actorID, role); err != nil {
+		return err
+	}
+	if organisation.RoleOf(userID) != "" {
+		return ErrAlreadyOrganisationMember
+	}
+	if _, err := users.GetByID(c, userID); err != nil {
+		return ErrUserNotFound
+	}
+
+	member := OrganisationMember{UserID: userID, Role: role, JoinedAt: time.Now().UTC()}
+	return organisations.AddMember(c, organisationID, member)
+}
+
+// Changes a member's role
+func UpdateOrganisationMemberRole(c context.Context, organisations OrganisationRepository, organisationID, actorID, userID primitive.ObjectID, role string) error {
+	if !validOrganisationRole(role) {
+		return ErrInvalidOrganisationRole
+	}
+
+	organisation, err := organisations.GetByID(c, organisationID)
+	if err != nil {
+		return err
+	}
+
+	currentRole := organisation.RoleOf(userID)
+	if currentRole == "" {
+		return ErrOrganisationMemberNotFound
+	}
+	if err := checkCanGrant(organisation, actorID, role); err != nil {
+		return err
+	}
+	if err := checkCanGrant(organisation, actorID, currentRole); err != nil {
+		return err
+	}
+	if currentRole == OrganisationRoleOwner && role != OrganisationRoleOwner && organisation.ownerCount() == 1 {
+		return ErrLastOrganisationOwner
+	}
+
+	return organisations.UpdateMemberRole(c, organisationID, userID, role)
+}
+
+// Removes a member from an organisation. Members can always remove themselves.
+func RemoveOrganisationMember(c context.Context, organisations OrganisationRepository, organisationID, actorID, userID primitive.ObjectID) error {
+	organisation, err := organisations.GetByID(c, organisationID)
+	if err != nil {
+		return err
+	}
+
+	currentRole := organisation.RoleOf(userID)
+	if currentRole == "" {
+		return ErrOrganisationMemberNotFound
+	}
+	if actorID != userID {
+		if err := checkCanGrant(organisation, actorID, currentRole); err != nil {
+			return err
+		}
+	}
+	if currentRole == OrganisationRoleOwner && organisation.ownerCount() == 1 {
+		return ErrLastOrganisationOwner
+	}
+
+	return organisations.RemoveMember(c, organisationID, userID)
+}
+
+// Checks the actor can manage members and hand out or take away the given role
+func checkCanGrant(organisation *Organisation, actorID primitive.ObjectID, role string) error {
+	actorRole := organisation.RoleOf(actorID)
+	if actorRole == "" {
+		return ErrNotOrganisationMember
+	}
+	if organisationRoleRank(actorRole) < organisationRoleRank(OrganisationRoleAdmin) {
+		return ErrOrganisationForbidden
+	}
+	if role == OrganisationRoleOwner && actorRole != OrganisationRoleOwner {
+		return ErrOrganisationForbidden
+	}
+	return nil
+}
+
+var ErrCrossOrganisationReference = errors.New("Tournaments and teams must belong to the same organisation")
+
+// Checks that the tournament and teams something refers to belong to the same organisation as
+// it does, so one organisation can never attach its data to another's. Zero IDs are skipped.
+func CheckOrganisationReferences(c context.Context, tournaments TournamentRepository, teams TeamRepository, organisationID, tournamentID primitive.ObjectID, teamIDs ...primitive.ObjectID) error {
+	if !tournamentID.IsZero() {
+		tournament, err := tournaments.GetByID(c, tournamentID)
+		if err != nil {
+			return err
+		}
+		if tournament.OrganisationID != organisationID {
+			return ErrCrossOrganisationReference
+		}
+	}
+
+	for _, teamID := range teamIDs {
+		if teamID.IsZero() {
+			continue
+		}
+		team, err := teams.GetByID(c, teamID)
+		if err != nil {
+			return err
+		}
+		if team.OrganisationID != organisationID {
+			return ErrCrossOrganisationReference
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How long delivered outbox entries are kept before Mongo removes them
+const outboxRetention = 24 * time.Hour
+
+// Maximum number of entries published per dispatch
+const outboxBatchSize = 100
+
+// How long a dispatcher has to publish an entry it claimed before another may take it over
+const outboxClaimLease = 30 * time.Second
+
+// An event recorded in the same transaction as the write it describes
+type OutboxEntry struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	Action       string             `bson:"action"`
+	Topics       []string           `bson:"topics"`
+	Payload      string             `bson:"payload"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	ClaimedBy    string             `bson:"claimed_by,omitempty"`
+	ClaimedUntil *time.Time         `bson:"claimed_until,omitempty"`
+	DeliveredAt  *time.Time         `bson:"delivered_at,omitempty"`
+}
+
+// Wakes the dispatcher when a transaction has committed new entries
+var outboxSignal = make(chan struct{}, 1)
+
+func signalOutbox() {
+	select {
+	case outboxSignal <- struct{}{}:
+	default:
+	}
+}
+
+func outboxCollection() *mongo.Collection {
+	return database.GetMongoClient().Database("esports-tournament-manager").Collection("outbox")
+}
+
+// Records a WebSocket message in the outbox as part of the session's transaction
+func recordOutboxEvent(sc mongo.SessionContext, message map[string]interface{}) error {
+	return recordOutboxEventForTopics(sc, message, realtimemanager.TopicsForMessage(message))
+}
+
+// Records a WebSocket message for explicit topics rather than the ones found in the message
+func recordOutboxEventForTopics(sc mongo.SessionContext, message map[string]interface{}, topics []string) error {
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	action, _ := message["action"].(string)
+	entry := OutboxEntry{
+		Action:    action,
+		Topics:    topics,
+		Payload:   string(messageJSON),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	_, err = outboxCollection().InsertOne(sc, entry)
+	return err
+}
+
+// Publishes committed outbox entries to the WebSocket hub and marks them delivered.
+// Each entry is claimed before it is published, so with several instances only one
+// publishes it, onto a hub that must share events between instances (EVENT_BUS=mongo)
+// for every client to see it. An entry can be published again if its dispatcher stops
+// before marking it delivered and the claim lapses, so its ID is used as the event ID
+// and clients can discard repeats.
+type OutboxDispatcher struct {
+	WebSocketHub *realtimemanager.WebSocketHub
+	Interval     time.Duration
+	owner        string
+}
+
+// Creates the dispatcher and the TTL index that clears out delivered entries
+func NewOutboxDispatcher(ctx context.Context, webSocketHub *realtimemanager.WebSocketHub) (*OutboxDispatcher, error) {
+	_, err := outboxCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "delivered_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(outboxRetention.Seconds())),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxDispatcher{
+		WebSocketHub: webSocketHub,
+		Interval:     time.Second,
+		owner:        primitive.NewObjectID().Hex(),
+	}, nil
+}
+
+// Run dispatches entries whenever a transaction commits, and on an interval to pick
+// up entries left behind by a crash or by other instances, until ctx is cancelled
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error dispatching outbox: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-outboxSignal:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch claims and publishes the undelivered entries in the order they were recorded,
+// skipping the ones another dispatcher holds an unexpired claim on
+func (d *OutboxDispatcher) Dispatch(ctx context.Context) error {
+	collection := outboxCollection()
+
+	for i := 0; i < outboxBatchSize; i++ {
+		entry, err := d.claim(ctx, collection)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		d.WebSocketHub.Publish(realtimemanager.Event{
+			ID:     entry.ID.Hex(),
+			Action: entry.Action,
+			Topics: entry.Topics,
+			Data:   []byte(entry.Payload),
+		})
+
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": entry.ID, "claimed_by": d.owner}, bson.M{"$set": bson.M{"delivered_at": time.Now().UTC()}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Atomically takes the oldest undelivered entry that is unclaimed or whose claim has
+// lapsed, failing with mongo.ErrNoDocuments when there is none
+func (d *OutboxDispatcher) claim(ctx context.Context, collection *mongo.Collection) (*OutboxEntry, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"delivered_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"claimed_until": bson.M{"$exists": false}},
+			bson.M{"claimed_until": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"claimed_by": d.owner, "claimed_until": now.Add(outboxClaimLease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "_id", Value: 1}}).SetReturnDocument(options.After)
+
+	var entry OutboxEntry
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How often changed overlays are rebuilt, so bursts of live updates cost one rebuild
+const overlayRebuildInterval = 250 * time.Millisecond
+
+// Everything a broadcast overlay needs to render a match, in one document
+type OverlaySnapshot struct {
+	MatchID        primitive.ObjectID `json:"match_id"`
+	TournamentID   primitive.ObjectID `json:"tournament_id"`
+	TournamentName string             `json:"tournament_name"`
+	Team1          OverlayTeam        `json:"team1"`
+	Team2          OverlayTeam        `json:"team2"`
+	CurrentMap     *LiveScore         `json:"current_map"`
+	Maps           []*MapResult       `json:"maps"`
+	Vetoes         []MapVeto          `json:"vetoes"`
+	PlayerStats    []PlayerStatLine   `json:"player_stats"`
+	NextMatch      *OverlayNextMatch  `json:"next_match"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// A team as shown on the overlay, with the number of maps it has won in the series
+type OverlayTeam struct {
+	ID          primitive.ObjectID `json:"id"`
+	Name        string             `json:"name"`
+	LogoURL     string             `json:"logo_url"`
+	SeriesScore int                `json:"series_score"`
+}
+
+// The match scheduled after this one in the same tournament
+type OverlayNextMatch struct {
+	MatchID   primitive.ObjectID `json:"match_id"`
+	Team1Name string             `json:"team1_name"`
+	Team2Name string             `json:"team2_name"`
+	Date      time.Time          `json:"date"`
+}
+
+// Builds the overlay for a match from its teams, map results and live score
+func BuildOverlaySnapshot(c context.Context, repos *Repositories, matchID primitive.ObjectID) (*OverlaySnapshot, error) {
+	match, err := repos.Matches.GetByID(c, matchID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &OverlaySnapshot{
+		MatchID:      match.ID,
+		TournamentID: match.TournamentID,
+		Team1:        OverlayTeam{ID: match.Team1ID, Name: match.Team1Name},
+		Team2:        OverlayTeam{ID: match.Team2ID, Name: match.Team2Name},
+		Vetoes:       match.Vetoes,
+		UpdatedAt:    time.Now().UTC(),
+	}
+
+	if tournament, err := repos.Tournaments.GetByID(c, match.TournamentID); err == nil {
+		snapshot.TournamentName = tournament.Name
+	}
+	if team, err := repos.Teams.GetByID(c, match.Team1ID); err == nil {
+		snapshot.Team1.Name = team.Name
+		snapshot.Team1.LogoURL = team.LogoURL
+	}
+	if team, err := repos.Teams.GetByID(c, match.Team2ID); err == nil {
+		snapshot.Team2.Name = team.Name
+		snapshot.Team2.LogoURL = team.LogoURL
+	}
+
+	snapshot.Maps, err = repos.LiveScores.ListMapResults(c, match.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Player stat lines are totals across the series so far
+	var statLines [][]PlayerStatLine
+	playedMaps := make(map[int]struct{})
+	for _, mapResult := range snapshot.Maps {
+		if mapResult.WinnerID == match.Team1ID {
+			snapshot.Team1.SeriesScore++
+		} else if mapResult.WinnerID == match.Team2ID {
+			snapshot.Team2.SeriesScore++
+		}
+		playedMaps[mapResult.MapNumber] = struct{}{}
+		statLines = append(statLines, mapResult.PlayerStats)
+	}
+
+	// The live score is only the current map while that map has no result yet
+	if score, err := repos.LiveScores.GetByMatchID(c, match.ID); err == nil {
+		if _, played := playedMaps[score.MapNumber]; !played && !score.Final {
+			snapshot.CurrentMap = score
+			statLines = append(statLines, score.PlayerStats)
+		}
+	}
+	snapshot.PlayerStats = sumPlayerStats(statLines)
+
+	snapshot.NextMatch, err = getNextMatch(c, repos.Public, match)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Adds up each player's stat lines across several maps
+func sumPlayerStats(statLines [][]PlayerStatLine) []PlayerStatLine {
+	var totals []PlayerStatLine
+	index := make(map[string]int)
+	for _, lines := range statLines {
+		for _, line := range lines {
+			key := line.TeamID.Hex() + ":" + strings.ToLower(line.Player)
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(totals)
+				totals = append(totals, PlayerStatLine{Player: line.Player, TeamID: line.TeamID})
+				i = len(totals) - 1
+			}
+			totals[i].Kills += line.Kills
+			totals[i].Deaths += line.Deaths
+			totals[i].Assists += line.Assists
+			totals[i].Damage += line.Damage
+		}
+	}
+	return totals
+}
+
+// Finds the match scheduled after the given one in its tournament
+func getNextMatch(c context.Context, public PublicReader, match *Match) (*OverlayNextMatch, error) {
+	if match.TournamentID.IsZero() || match.Date.IsZero() {
+		return nil, nil
+	}
+
+	matches, err := public.ListTournamentMatches(c, match.TournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, next := range matches {
+		if next.ID == match.ID || next.Date.IsZero() || next.Date.Before(match.Date) {
+			continue
+		}
+		return &OverlayNextMatch{
+			MatchID:   next.ID,
+			Team1Name: next.Team1Name,
+			Team2Name: next.Team2Name,
+			Date:      next.Date,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// Keeps overlay subscribers up to date by rebuilding a match's snapshot whenever
+// an event for that match passes through the hub
+type OverlayFeed struct {
+	WebSocketHub *realtimemanager.WebSocketHub
+	Repos        *Repositories
+}
+
+func NewOverlayFeed(webSocketHub *realtimemanager.WebSocketHub, repos *Repositories) *OverlayFeed {
+	return &OverlayFeed{
+		WebSocketHub: webSocketHub,
+		Repos:        repos,
+	}
+}
+
+// Run watches hub events and publishes fresh snapshots until ctx is cancelled
+func (f *OverlayFeed) Run(ctx context.Context) {
+	sub := f.WebSocketHub.Subscribe()
+	defer f.WebSocketHub.Unsubscribe(sub)
+
+	ticker := time.NewTicker(overlayRebuildInterval)
+	defer ticker.Stop()
+
+	changed := make(map[string]struct{})
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if event.Action == "overlay_updated" {
+				continue
+			}
+			for _, topic := range event.Topics {
+				if matchID, ok := strings.CutPrefix(topic, "match:"); ok {
+					changed[matchID] = struct{}{}
+				}
+			}
+		case <-ticker.C:
+			for matchID := range changed {
+				f.publish(ctx, matchID)
+			}
+			changed = make(map[string]struct{})
+		}
+	}
+}
+
+// Rebuilds and publishes a match's snapshot if any overlay is watching it
+func (f *OverlayFeed) publish(ctx context.Context, matchIDStr string) {
+	topic := realtimemanager.Topic("overlay", matchIDStr)
+	if f.WebSocketHub.SubscriberCount(topic) == 0 {
+		return
+	}
+
+	matchID, err := primitive.ObjectIDFromHex(matchIDStr)
+	if err != nil {
+		return
+	}
+
+	snapshot, err := BuildOverlaySnapshot(ctx, f.Repos, matchID)
+	if err != nil {
+		log.Printf("Error building overlay for match %s: %v", matchIDStr, err)
+		return
+	}
+
+	messageJSON, err := json.Marshal(map[string]interface{}{
+		"action":   "overlay_updated",
+		"match_id": matchIDStr,
+		"overlay":  snapshot,
+	})
+	if err != nil {
+		log.Printf("Error marshaling WebSocket message: %v", err)
+		return
+	}
+
+	f.WebSocketHub.Publish(realtimemanager.Event{
+		Action: "overlay_updated",
+		Topics: []string{topic},
+		Data:   messageJSON,
+	})
+}
@@ -0,0 +1,367 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Spectators only see published tournaments, so anything else is reported as missing
+var (
+	ErrPublicTournamentNotFound = errors.New("Tournament not found")
+	ErrPublicMatchNotFound      = errors.New("Match not found")
+)
+
+// Match statuses shown to spectators
+const (
+	PublicMatchScheduled = "scheduled"
+	PublicMatchCompleted = "completed"
+)
+
+// A published tournament as shown to spectators, without organiser or staff details
+type PublicTournament struct {
+	ID          primitive.ObjectID `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	StartDate   time.Time          `json:"start_date"`
+	EndDate     time.Time          `json:"end_date"`
+	TimeZone    string             `json:"time_zone"`
+	Teams       []*PublicTeam      `json:"teams,omitempty"`
+}
+
+// A team as shown to spectators
+type PublicTeam struct {
+	ID      primitive.ObjectID `json:"id"`
+	Name    string             `json:"name"`
+	LogoURL string             `json:"logo_url"`
+	Players []string           `json:"players"`
+}
+
+// A match as shown to spectators, with its result once there is one
+type PublicMatch struct {
+	ID           primitive.ObjectID `json:"id"`
+	TournamentID primitive.ObjectID `json:"tournament_id"`
+	Team1ID      primitive.ObjectID `json:"team1_id"`
+	Team2ID      primitive.ObjectID `json:"team2_id"`
+	Team1Name    string             `json:"team1_name"`
+	Team2Name    string             `json:"team2_name"`
+	Date         *time.Time         `json:"date"`
+	Vetoes       []MapVeto          `json:"vetoes"`
+	Status       string             `json:"status"`
+	Result       *PublicMatchResult `json:"result"`
+}
+
+// The outcome of a match as shown to spectators
+type PublicMatchResult struct {
+	WinnerID    primitive.ObjectID `json:"winner_id"`
+	LoserID     primitive.ObjectID `json:"loser_id"`
+	WinnerScore int                `json:"winner_score"`
+	LoserScore  int                `json:"loser_score"`
+}
+
+// One round of a tournament bracket
+type PublicBracketRound struct {
+	Round   int            `json:"round"`
+	Matches []*PublicMatch `json:"matches"`
+}
+
+// A team's record across a tournament's completed matches
+type PublicStanding struct {
+	Rank            int                `json:"rank"`
+	TeamID          primitive.ObjectID `json:"team_id"`
+	TeamName        string             `json:"team_name"`
+	Played          int                `json:"played"`
+	Wins            int                `json:"wins"`
+	Losses          int                `json:"losses"`
+	ScoreFor        int                `json:"score_for"`
+	ScoreAgainst    int                `json:"score_against"`
+	ScoreDifference int                `json:"score_difference"`
+}
+
+// Dates are shown in the tournament's time zone
+func newPublicTournament(tournament *Tournament) *PublicTournament {
+	location := tournament.Location()
+	return &PublicTournament{
+		ID:          tournament.ID,
+		Name:        tournament.Name,
+		Description: tournament.Description,
+		StartDate:   tournament.StartDate.In(location),
+		EndDate:     tournament.EndDate.In(location),
+		TimeZone:    location.String(),
+	}
+}
+
+func newPublicMatch(match *Match, result *MatchResult, location *time.Location) *PublicMatch {
+	publicMatch := &PublicMatch{
+		ID:           match.ID,
+		TournamentID: match.TournamentID,
+		Team1ID:      match.Team1ID,
+		Team2ID:      match.Team2ID,
+		Team1Name:    match.Team1Name,
+		Team2Name:    match.Team2Name,
+		Vetoes:       match.Vetoes,
+		Status:       PublicMatchScheduled,
+	}
+	if !match.Date.IsZero() {
+		date := match.Date.In(location)
+		publicMatch.Date = &date
+	}
+
+	if result != nil {
+		publicMatch.Status = PublicMatchCompleted
+		publicMatch.Result = &PublicMatchResult{
+			WinnerID:    result.WinnerID,
+			LoserID:     result.LoserID,
+			WinnerScore: result.WinnerScore,
+			LoserScore:  result.LoserScore,
+		}
+	}
+
+	return publicMatch
+}
+
+// The filters and sorts for listing published tournaments
+var PublicTournamentListSchema = ListSchema{
+	Filters: map[string]FilterParser{
+		"status": statusFilter(tournamentStatuses),
+		"from":   fromFilter("end_date"),
+		"to":     toFilter("start_date"),
+	},
+	Sorts: map[string]string{
+		"start_date": "start_date",
+		"end_date":   "end_date",
+		"name":       "name",
+	},
+	DefaultSort: "start_date",
+}
+
+// Gets a page of the published tournaments, soonest first unless sorted otherwise
+func GetPublishedTournaments(c context.Context, public PublicReader, query ListQuery) (*Page[PublicTournament], error) {
+	tournaments, err := public.ListPublishedTournaments(c, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page[PublicTournament]{Items: []*PublicTournament{}, Total: tournaments.Total, NextCursor: tournaments.NextCursor}
+	for _, tournament := range tournaments.Items {
+		page.Items = append(page.Items, newPublicTournament(tournament))
+	}
+
+	return page, nil
+}
+
+// Gets a published tournament along with its teams
+func GetPublishedTournament(c context.Context, public PublicReader, id primitive.ObjectID) (*PublicTournament, error) {
+	tournament, err := public.GetPublishedTournament(c, id)
+	if err != nil {
+		return nil, err
+	}
+
+	publicTournament := newPublicTournament(tournament)
+	publicTournament.Teams, err = getPublicTeams(c, public, tournament)
+	if err != nil {
+		return nil, err
+	}
+
+	return publicTournament, nil
+}
+
+// Gets the teams entered in a tournament, either listed on it or pointing back at it
+func getPublicTeams(c context.Context, public PublicReader, tournament *Tournament) ([]*PublicTeam, error) {
+	teams, err := public.ListTournamentTeams(c, tournament)
+	if err != nil {
+		return nil, err
+	}
+
+	publicTeams := []*PublicTeam{}
+	for _, team := range teams {
+		publicTeams = append(publicTeams, &PublicTeam{
+			ID:      team.ID,
+			Name:    team.Name,
+			LogoURL: team.LogoURL,
+			Players: team.Players,
+		})
+	}
+
+	return publicTeams, nil
+}
+
+// Gets a tournament's matches in date order with their results, unscheduled ones first
+func getPublicMatches(c context.Context, public PublicReader, tournament *Tournament) ([]*PublicMatch, error) {
+	matches, err := public.ListTournamentMatches(c, tournament.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matchIDs []primitive.ObjectID
+	for _, match := range matches {
+		matchIDs = append(matchIDs, match.ID)
+	}
+
+	results, err := public.LatestMatchResults(c, matchIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	location := tournament.Location()
+	publicMatches := []*PublicMatch{}
+	for _, match := range matches {
+		publicMatches = append(publicMatches, newPublicMatch(match, results[match.ID], location))
+	}
+
+	return publicMatches, nil
+}
+
+// Gets a published tournament's matches in the order they are played
+func GetPublicSchedule(c context.Context, public PublicReader, tournamentID primitive.ObjectID) ([]*PublicMatch, error) {
+	tournament, err := public.GetPublishedTournament(c, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return getPublicMatches(c, public, tournament)
+}
+
+// Gets a published tournament's bracket. Matches don't store a round, so each match is
+// placed in the round after the latest one either of its teams has already played in
+func GetPublicBracket(c context.Context, public PublicReader, tournamentID primitive.ObjectID) ([]*PublicBracketRound, error) {
+	matches, err := GetPublicSchedule(c, public, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	rounds := []*PublicBracketRound{}
+	teamRounds := make(map[primitive.ObjectID]int)
+	for _, match := range matches {
+		round := teamRounds[match.Team1ID]
+		if teamRounds[match.Team2ID] > round {
+			round = teamRounds[match.Team2ID]
+		}
+		round++
+
+		teamRounds[match.Team1ID] = round
+		teamRounds[match.Team2ID] = round
+
+		if round > len(rounds) {
+			rounds = append(rounds, &PublicBracketRound{Round: round})
+		}
+		rounds[round-1].Matches = append(rounds[round-1].Matches, match)
+	}
+
+	return rounds, nil
+}
+
+// Gets a published tournament's standings, ranked by wins and then score difference
+func GetPublicStandings(c context.Context, public PublicReader, tournamentID primitive.ObjectID) ([]*PublicStanding, error) {
+	tournament, err := public.GetPublishedTournament(c, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := getPublicTeams(c, public, tournament)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := getPublicMatches(c, public, tournament)
+	if err != nil {
+		return nil, err
+	}
+
+	standings := []*PublicStanding{}
+	byTeam := make(map[primitive.ObjectID]*PublicStanding)
+	standingFor := func(teamID primitive.ObjectID, name string) *PublicStanding {
+		standing, ok := byTeam[teamID]
+		if !ok {
+			standing = &PublicStanding{TeamID: teamID, TeamName: name}
+			byTeam[teamID] = standing
+			standings = append(standings, standing)
+		}
+		return standing
+	}
+
+	// Every entered team is listed, even before it has played
+	for _, team := range teams {
+		standingFor(team.ID, team.Name)
+	}
+
+	for _, match := range matches {
+		team1 := standingFor(match.Team1ID, match.Team1Name)
+		team2 := standingFor(match.Team2ID, match.Team2Name)
+
+		result := match.Result
+		if result == nil || result.WinnerID.IsZero() {
+			continue
+		}
+
+		team1.Played++
+		team2.Played++
+
+		if winner, ok := byTeam[result.WinnerID]; ok {
+			winner.Wins++
+			winner.ScoreFor += result.WinnerScore
+			winner.ScoreAgainst += result.LoserScore
+		}
+		if loser, ok := byTeam[result.LoserID]; ok {
+			loser.Losses++
+			loser.ScoreFor += result.LoserScore
+			loser.ScoreAgainst += result.WinnerScore
+		}
+	}
+
+	for _, standing := range standings {
+		standing.ScoreDifference = standing.ScoreFor - standing.ScoreAgainst
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.ScoreDifference != b.ScoreDifference {
+			return a.ScoreDifference > b.ScoreDifference
+		}
+		if a.ScoreFor != b.ScoreFor {
+			return a.ScoreFor > b.ScoreFor
+		}
+		return a.TeamName < b.TeamName
+	})
+
+	for i, standing := range standings {
+		standing.Rank = i + 1
+	}
+
+	return standings, nil
+}
+
+// Gets a match if it belongs to a published tournament
+func GetPublicMatch(c context.Context, public PublicReader, matches MatchRepository, matchID primitive.ObjectID) (*PublicMatch, error) {
+	match, err := matches.GetByID(c, matchID)
+	if err != nil {
+		if errors.Is(err, ErrMatchNotFound) {
+			return nil, ErrPublicMatchNotFound
+		}
+		return nil, err
+	}
+
+	if match.TournamentID.IsZero() {
+		return nil, ErrPublicMatchNotFound
+	}
+	tournament, err := public.GetPublishedTournament(c, match.TournamentID)
+	if err != nil {
+		if errors.Is(err, ErrPublicTournamentNotFound) {
+			return nil, ErrPublicMatchNotFound
+		}
+		return nil, err
+	}
+
+	results, err := public.LatestMatchResults(c, []primitive.ObjectID{match.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	return newPublicMatch(match, results[match.ID], tournament.Location()), nil
+}
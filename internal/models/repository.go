@@ -0,0 +1,300 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Returned by repositories when the document asked for doesn't exist
+var (
+	ErrTournamentNotFound  = errors.New("Tournament not found")
+	ErrTeamNotFound        = errors.New("Team not found")
+	ErrMatchNotFound       = errors.New("Match not found")
+	ErrMatchResultNotFound = errors.New("Match result not found")
+	ErrUserNotFound        = errors.New("User not found")
+)
+
+// The documents a user can list: everything in their organisations, plus documents
+// from before organisations that they organise when OrganiserID is set
+type OwnerScope struct {
+	OrganisationIDs []primitive.ObjectID
+	OrganiserID     primitive.ObjectID
+}
+
+func (s OwnerScope) filter() bson.M {
+	organisationIDs := append([]primitive.ObjectID{}, s.OrganisationIDs...)
+	if s.OrganiserID.IsZero() {
+		return bson.M{"organisation_id": bson.M{"$in": organisationIDs}}
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{"organisation_id": bson.M{"$in": organisationIDs}},
+		bson.M{"organisation_id": bson.M{"$exists": false}, "organiser_id": s.OrganiserID},
+	}}
+}
+
+// Reports whether a document with the given owners is in the scope
+func (s OwnerScope) includes(organisationID, organiserID primitive.ObjectID) bool {
+	if organisationID.IsZero() {
+		return !s.OrganiserID.IsZero() && organiserID == s.OrganiserID
+	}
+	return containsObjectID(s.OrganisationIDs, organisationID)
+}
+
+// Stores tournaments. Update only applies if the tournament is still at tournament.Version,
+// failing with ErrVersionConflict otherwise, and sets it to the new version. Deleting one
+// soft deletes it along with whatever the cascade rules reach, and GetByID, List and Update
+// act as if it were gone until it is restored.
+type TournamentRepository interface {
+	Create(c context.Context, tournament *Tournament) (*Tournament, error)
+	GetByID(c context.Context, id primitive.ObjectID) (*Tournament, error)
+	List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Tournament], error)
+	Update(c context.Context, id primitive.ObjectID, tournament *Tournament) error
+	Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error
+	GetDeletedByID(c context.Context, id primitive.ObjectID) (*Tournament, error)
+	Restore(c context.Context, id primitive.ObjectID) error
+}
+
+// Stores teams. Creating or updating a team with a tournament adds it to that tournament's
+// teams in the same write, failing with ErrTournamentNotFound and storing nothing if the
+// tournament doesn't exist. Teams are versioned and soft deleted like tournaments.
+type TeamRepository interface {
+	Create(c context.Context, team *Team) (*Team, error)
+	GetByID(c context.Context, id primitive.ObjectID) (*Team, error)
+	List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Team], error)
+	Update(c context.Context, id primitive.ObjectID, team *Team) error
+	Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error
+	GetDeletedByID(c context.Context, id primitive.ObjectID) (*Team, error)
+	Restore(c context.Context, id primitive.ObjectID) error
+}
+
+// Stores matches, keeping tournaments' matches up to date the same way teams do
+type MatchRepository interface {
+	Create(c context.Context, match *Match) (*Match, error)
+	GetByID(c context.Context, id primitive.ObjectID) (*Match, error)
+	List(c context.Context, scope OwnerScope, query ListQuery) (*Page[Match], error)
+	Update(c context.Context, id primitive.ObjectID, match *Match) error
+	Delete(c context.Context, id primitive.ObjectID, rules CascadeRules) error
+	GetDeletedByID(c context.Context, id primitive.ObjectID) (*Match, error)
+	Restore(c context.Context, id primitive.ObjectID) error
+}
+
+// Stores match results, versioning and soft deleting them like matches
+type MatchResultRepository interface {
+	Create(c context.Context, matchResult *MatchResult) (*MatchResult, error)
+	GetByID(c context.Context, id primitive.ObjectID) (*MatchResult, error)
+	List(c context.Context, scope OwnerScope, query ListQuery) (*Page[MatchResult], error)
+	Update(c context.Context, id primitive.ObjectID, matchResult *MatchResult) error
+	Delete(c context.Context, id primitive.ObjectID) error
+	GetDeletedByID(c context.Context, id primitive.ObjectID) (*MatchResult, error)
+	Restore(c context.Context, id primitive.ObjectID) error
+}
+
+// Removes soft deleted tournaments, teams, matches and results for good
+type DeletedPurger interface {
+	PurgeDeleted(c context.Context, before time.Time) (int64, error)
+}
+
+// Stores user accounts. Apart from Update, changes are made a field at a time so they
+// don't overwrite other changes to the same user, and fail with ErrUserNotFound if there is
+// no such user. EnableTwoFactor fails with ErrTwoFactorNotSetUp unless the pending secret is
+// still the one given. UseTwoFactorStep reports false if the step is not after the last one
+// used, and UseRecoveryCode if the code isn't one of the user's, so each only works once.
+type UserRepository interface {
+	Create(c context.Context, user *User) error
+	GetByID(c context.Context, id primitive.ObjectID) (*User, error)
+	GetByEmail(c context.Context, email string) (*User, error)
+	GetByIdentity(c context.Context, provider, subject string) (*User, error)
+	Update(c context.Context, user *User) error
+	AddIdentity(c context.Context, userID primitive.ObjectID, identity UserIdentity) error
+	RemoveIdentity(c context.Context, userID primitive.ObjectID, provider string) error
+	VerifyEmail(c context.Context, userID primitive.ObjectID) error
+	ResetPassword(c context.Context, userID primitive.ObjectID, passwordHash string) error
+	SetTwoFactorPendingSecret(c context.Context, userID primitive.ObjectID, secret string) error
+	EnableTwoFactor(c context.Context, userID primitive.ObjectID, pendingSecret string, recoveryCodeHashes []string, step int64) error
+	DisableTwoFactor(c context.Context, userID primitive.ObjectID) error
+	SetRecoveryCodes(c context.Context, userID primitive.ObjectID, recoveryCodeHashes []string) error
+	UseTwoFactorStep(c context.Context, userID primitive.ObjectID, step int64) (bool, error)
+	UseRecoveryCode(c context.Context, userID primitive.ObjectID, codeHash string) (bool, error)
+}
+
+// Picks the sessions to revoke. Fields left zero match any session.
+type SessionFilter struct {
+	ID               primitive.ObjectID
+	UserID           primitive.ObjectID
+	RefreshTokenHash string
+}
+
+// Stores signed in sessions. GetByID fails with ErrInvalidRefreshToken if there is no such
+// session. Rotate saves a session's new refresh token, last use and client, but only if its
+// token is still previousHash and it hasn't been revoked, failing with ErrInvalidRefreshToken
+// otherwise so each refresh token can only be swapped once. Active sessions are the ones
+// that haven't been revoked or expired.
+type SessionRepository interface {
+	Create(c context.Context, session *Session) error
+	GetByID(c context.Context, id primitive.ObjectID) (*Session, error)
+	Rotate(c context.Context, session *Session, previousHash string) error
+	Revoke(c context.Context, filter SessionFilter) error
+	ListActive(c context.Context, userID primitive.ObjectID) ([]*Session, error)
+	IsActive(c context.Context, id primitive.ObjectID) (bool, error)
+}
+
+// Stores single use account tokens by their hash. Replace uses up the user's unused tokens
+// for the same purpose before storing the new one. Find and Consume fail with
+// ErrInvalidAccountToken if the token is unknown, used or expired, and Consume uses it up so
+// only one caller gets it.
+type AccountTokenRepository interface {
+	Replace(c context.Context, token *AccountToken) error
+	Find(c context.Context, tokenHash, purpose string) (*AccountToken, error)
+	Consume(c context.Context, tokenHash, purpose string) (*AccountToken, error)
+}
+
+// Counts failed logins by key. RecordFailure adds one to the count, starting again if the
+// last failure is older than window, and returns the count it made.
+type LoginAttemptRepository interface {
+	ListLocked(c context.Context, keys []string) ([]*LoginAttempt, error)
+	RecordFailure(c context.Context, key string, window time.Duration) (*LoginAttempt, error)
+	Lock(c context.Context, key string, until time.Time) error
+	Clear(c context.Context, key string) error
+}
+
+// Stores the audit log
+type AuditLogRepository interface {
+	Create(c context.Context, entry *AuditLog) error
+}
+
+// Stores API keys. GetByID returns revoked and expired keys too, and fails with
+// ErrAPIKeyNotFound if there is no such key, as does revoking a key that isn't the user's or
+// is already revoked. MarkUsed only writes the time if the last one is older than interval.
+type APIKeyRepository interface {
+	Create(c context.Context, apiKey *APIKey) error
+	GetByID(c context.Context, id primitive.ObjectID) (*APIKey, error)
+	ListActive(c context.Context, userID primitive.ObjectID) ([]*APIKey, error)
+	Revoke(c context.Context, userID, id primitive.ObjectID) error
+	MarkUsed(c context.Context, id primitive.ObjectID, interval time.Duration) error
+}
+
+// Stores logins in progress at OIDC providers. Take removes the state as it returns it, so
+// each can only be used once, and fails with ErrInvalidOIDCState if it is unknown or expired.
+type OIDCStateRepository interface {
+	Create(c context.Context, state *OIDCLoginState) error
+	Take(c context.Context, stateHash, provider string) (*OIDCLoginState, error)
+}
+
+// Stores match lobby chat. Every write records its event on the match's chat topic in the
+// same transaction. DeleteMessage fails with ErrChatMessageNotFound if the message isn't in
+// the match, and GetActiveMute returns nil if the user isn't muted.
+type ChatRepository interface {
+	CreateMessage(c context.Context, message *ChatMessage) error
+	ListMessages(c context.Context, matchID, before primitive.ObjectID, limit int) ([]*ChatMessage, error)
+	DeleteMessage(c context.Context, matchID, messageID, deletedBy primitive.ObjectID) error
+	GetActiveMute(c context.Context, matchID, userID primitive.ObjectID) (*ChatMute, error)
+	Mute(c context.Context, mute *ChatMute) error
+	Unmute(c context.Context, matchID, userID, unmutedBy primitive.ObjectID) error
+}
+
+// Stores each match's live score and the results of the maps it has finished. GetByMatchID
+// fails with ErrLiveScoreNotFound before the first update. SaveFinal stores a map's final
+// score and its result in one transaction, recording the events for both, and fails with
+// ErrMapResultExists, leaving the live score alone, if the map already has a result.
+type LiveScoreRepository interface {
+	Save(c context.Context, score *LiveScore) error
+	GetByMatchID(c context.Context, matchID primitive.ObjectID) (*LiveScore, error)
+	SaveFinal(c context.Context, match *Match, score *LiveScore, mapResult *MapResult) error
+	ListMapResults(c context.Context, matchID primitive.ObjectID) ([]*MapResult, error)
+}
+
+// Reads what spectators can see: only published tournaments are found, and deleted documents
+// are left out. GetPublishedTournament fails with ErrPublicTournamentNotFound. A tournament's
+// teams are the ones listed on it or pointing back at it, by name, and its matches are in
+// date order. LatestMatchResults maps each match to the last result recorded for it.
+type PublicReader interface {
+	ListPublishedTournaments(c context.Context, query ListQuery) (*Page[Tournament], error)
+	GetPublishedTournament(c context.Context, id primitive.ObjectID) (*Tournament, error)
+	ListTournamentTeams(c context.Context, tournament *Tournament) ([]*Team, error)
+	ListTournamentMatches(c context.Context, tournamentID primitive.ObjectID) ([]*Match, error)
+	LatestMatchResults(c context.Context, matchIDs []primitive.ObjectID) (map[primitive.ObjectID]*MatchResult, error)
+}
+
+// Stores organisations and their members. Member changes are made one at a time so
+// concurrent changes to the same organisation don't overwrite each other.
+type OrganisationRepository interface {
+	Create(c context.Context, organisation *Organisation) error
+	GetByID(c context.Context, id primitive.ObjectID) (*Organisation, error)
+	ListForUser(c context.Context, userID primitive.ObjectID) ([]*Organisation, error)
+	AddMember(c context.Context, organisationID primitive.ObjectID, member OrganisationMember) error
+	UpdateMemberRole(c context.Context, organisationID, userID primitive.ObjectID, role string) error
+	RemoveMember(c context.Context, organisationID, userID primitive.ObjectID) error
+}
+
+// Every repository the handlers need, so they can be built together
+type Repositories struct {
+	Tournaments   TournamentRepository
+	Teams         TeamRepository
+	Matches       MatchRepository
+	MatchResults  MatchResultRepository
+	Users         UserRepository
+	Organisations OrganisationRepository
+	Purger        DeletedPurger
+	Search        Searcher
+	Sessions      SessionRepository
+	AccountTokens AccountTokenRepository
+	LoginAttempts LoginAttemptRepository
+	AuditLogs     AuditLogRepository
+	APIKeys       APIKeyRepository
+	OIDCStates    OIDCStateRepository
+	Chat          ChatRepository
+	LiveScores    LiveScoreRepository
+	Public        PublicReader
+}
+
+// Repositories backed by MongoDB, used by the server
+func NewMongoRepositories() *Repositories {
+	return &Repositories{
+		Tournaments:   MongoTournamentRepository{},
+		Teams:         MongoTeamRepository{},
+		Matches:       MongoMatchRepository{},
+		MatchResults:  MongoMatchResultRepository{},
+		Users:         MongoUserRepository{},
+		Organisations: MongoOrganisationRepository{},
+		Purger:        MongoPurger{},
+		Search:        MongoSearcher{},
+		Sessions:      MongoSessionRepository{},
+		AccountTokens: MongoAccountTokenRepository{},
+		LoginAttempts: MongoLoginAttemptRepository{},
+		AuditLogs:     MongoAuditLogRepository{},
+		APIKeys:       MongoAPIKeyRepository{},
+		OIDCStates:    MongoOIDCStateRepository{},
+		Chat:          MongoChatRepository{},
+		LiveScores:    MongoLiveScoreRepository{},
+		Public:        MongoPublicReader{},
+	}
+}
+
+// Repositories held in memory, so the HTTP API can run without a database
+func NewMemoryRepositories() *Repositories {
+	store := NewMemoryStore()
+	return &Repositories{
+		Tournaments:   NewMemoryTournamentRepository(store),
+		Teams:         NewMemoryTeamRepository(store),
+		Matches:       NewMemoryMatchRepository(store),
+		MatchResults:  NewMemoryMatchResultRepository(store),
+		Users:         NewMemoryUserRepository(),
+		Organisations: NewMemoryOrganisationRepository(),
+		Purger:        store,
+		Search:        NewMemorySearcher(store),
+		Sessions:      NewMemorySessionRepository(),
+		AccountTokens: NewMemoryAccountTokenRepository(),
+		LoginAttempts: NewMemoryLoginAttemptRepository(),
+		AuditLogs:     NewMemoryAuditLogRepository(),
+		APIKeys:       NewMemoryAPIKeyRepository(),
+		OIDCStates:    NewMemoryOIDCStateRepository(),
+		Chat:          NewMemoryChatRepository(),
+		LiveScores:    NewMemoryLiveScoreRepository(),
+		Public:        NewMemoryPublicReader(store),
+	}
+}
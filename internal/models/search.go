@@ -0,0 +1,371 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidSearch = errors.New("Invalid search")
+
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 50
+	minSearchLength    = 2
+	// Length of the runs of letters search keys are made of
+	searchKeyLength = 3
+)
+
+// What to search for. Limit is how many results of each type to return.
+type SearchQuery struct {
+	Text  string
+	Limit int
+}
+
+// Search results grouped by type, best match first
+type SearchResults struct {
+	Tournaments []*TournamentHit `json:"tournaments"`
+	Teams       []*TeamHit       `json:"teams"`
+	Players     []*PlayerHit     `json:"players"`
+}
+
+// A tournament whose name or description matched
+type TournamentHit struct {
+	ID          primitive.ObjectID `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	StartDate   time.Time          `json:"start_date"`
+	EndDate     time.Time          `json:"end_date"`
+	Score       float64            `json:"score"`
+}
+
+// A team whose name matched
+type TeamHit struct {
+	ID           primitive.ObjectID  `json:"id"`
+	Name         string              `json:"name"`
+	TournamentID *primitive.ObjectID `json:"tournament_id,omitempty"`
+	Players      []string            `json:"players"`
+	Score        float64             `json:"score"`
+}
+
+// A player handle that matched, with the team it plays for
+type PlayerHit struct {
+	Handle   string             `json:"handle"`
+	TeamID   primitive.ObjectID `json:"team_id"`
+	TeamName string             `json:"team_name"`
+	Score    float64            `json:"score"`
+}
+
+// Searches tournament names and descriptions, team names and player handles in a scope,
+// ignoring deleted documents. Mongo text indexes back the server's, but anything that
+// finds the same things can stand in for it.
+type Searcher interface {
+	Search(c context.Context, scope OwnerScope, query SearchQuery) (*SearchResults, error)
+}
+
+// Parses q=<text> and limit=<n>
+func ParseSearchQuery(values url.Values) (SearchQuery, error) {
+	query := SearchQuery{Text: strings.TrimSpace(values.Get("q")), Limit: DefaultSearchLimit}
+
+	if len([]rune(query.Text)) < minSearchLength || len(searchWords(query.Text)) == 0 {
+		return query, fmt.Errorf("%w: q must have at least %d characters", ErrInvalidSearch, minSearchLength)
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxSearchLimit {
+			return query, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearch, MaxSearchLimit)
+		}
+		query.Limit = n
+	}
+
+	for name := range values {
+		if name != "q" && name != "limit" {
+			return query, fmt.Errorf("%w: unknown parameter %q", ErrInvalidSearch, name)
+		}
+	}
+
+	return query, nil
+}
+
+// Splits text into lower case words, so "xX_Sniper_Xx" is xx, sniper and xx
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// How many typos a search word can have and still match, more for longer words
+func allowedTypos(word []rune) int {
+	switch {
+	case len(word) < 4:
+		return 0
+	case len(word) < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// How well a search word matches a word in the text: 1 for the same word, less for a
+// word it starts, and less again for one it is a typo or two away from
+func wordScore(term, word []rune) float64 {
+	if string(term) == string(word) {
+		return 1
+	}
+	if len(word) > len(term) && string(word[:len(term)]) == string(term) {
+		return 0.8
+	}
+
+	allowed := allowedTypos(term)
+	if allowed == 0 {
+		return 0
+	}
+	if d := editDistance(term, word); d <= allowed {
+		return 0.6 - 0.2*float64(d-1)
+	}
+	if len(word) > len(term) {
+		if d := editDistance(term, word[:len(term)]); d <= allowed {
+			return 0.5 - 0.2*float64(d-1)
+		}
+	}
+	return 0
+}
+
+// Scores text against every search word. Each one has to match some word in the text, or
+// the score is 0.
+func textScore(terms [][]rune, text string) float64 {
+	words := searchWords(text)
+	if len(terms) == 0 || len(words) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for _, word := range words {
+			if score := wordScore(term, []rune(word)); score > best {
+				best = score
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total / float64(len(terms))
+}
+
+// The number of single character insertions, deletions, substitutions and swaps of
+// neighbouring characters between two words
+func editDistance(a, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+// The lower case keys text is found by: the start of each word, marked with ^, and every
+// run of three letters in it. A search's own keys are made the same way, and a document is
+// a candidate if it shares any of them. Prefixes share the start of a word, and a word with
+// a typo, even in its first letters, still shares most of its runs.
+func SearchKeys(texts ...string) []string {
+	seen := make(map[string]bool)
+	keys := []string{}
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	for _, text := range texts {
+		for _, word := range searchWords(text) {
+			runes := []rune(word)
+			add("^" + string(runes[:min(len(runes), minSearchLength)]))
+			for i := 0; i+searchKeyLength <= len(runes); i++ {
+				add(string(runes[i : i+searchKeyLength]))
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func searchTerms(text string) [][]rune {
+	var terms [][]rune
+	for _, word := range searchWords(text) {
+		terms = append(terms, []rune(word))
+	}
+	return terms
+}
+
+// Scores and orders the candidate tournaments and teams an engine found. Candidates can be
+// repeated or not match at all; only the ones that match are kept.
+func rankSearchResults(query SearchQuery, tournaments []*Tournament, teams []*Team) *SearchResults {
+	terms := searchTerms(query.Text)
+	results := &SearchResults{Tournaments: []*TournamentHit{}, Teams: []*TeamHit{}, Players: []*PlayerHit{}}
+
+	seen := make(map[primitive.ObjectID]bool)
+	for _, tournament := range tournaments {
+		if seen[tournament.ID] {
+			continue
+		}
+		seen[tournament.ID] = true
+
+		// A match in the name counts for more than one in the description
+		score := max(textScore(terms, tournament.Name)*2, textScore(terms, tournament.Description))
+		if score > 0 {
+			results.Tournaments = append(results.Tournaments, &TournamentHit{
+				ID:          tournament.ID,
+				Name:        tournament.Name,
+				Description: tournament.Description,
+				StartDate:   tournament.StartDate,
+				EndDate:     tournament.EndDate,
+				Score:       score,
+			})
+		}
+	}
+
+	for _, team := range teams {
+		if seen[team.ID] {
+			continue
+		}
+		seen[team.ID] = true
+
+		if score := textScore(terms, team.Name); score > 0 {
+			hit := &TeamHit{ID: team.ID, Name: team.Name, Players: team.Players, Score: score}
+			if !team.TournamentID.IsZero() {
+				tournamentID := team.TournamentID
+				hit.TournamentID = &tournamentID
+			}
+			results.Teams = append(results.Teams, hit)
+		}
+		for _, player := range team.Players {
+			if score := textScore(terms, player); score > 0 {
+				results.Players = append(results.Players, &PlayerHit{
+					Handle:   player,
+					TeamID:   team.ID,
+					TeamName: team.Name,
+					Score:    score,
+				})
+			}
+		}
+	}
+
+	// Ties are broken by name and then ID, so the order doesn't depend on the order the
+	// candidates were found in
+	sort.Slice(results.Tournaments, func(i, j int) bool {
+		a, b := results.Tournaments[i], results.Tournaments[j]
+		return rankedBefore(a.Score, b.Score, a.Name, b.Name, a.ID, b.ID)
+	})
+	sort.Slice(results.Teams, func(i, j int) bool {
+		a, b := results.Teams[i], results.Teams[j]
+		return rankedBefore(a.Score, b.Score, a.Name, b.Name, a.ID, b.ID)
+	})
+	sort.Slice(results.Players, func(i, j int) bool {
+		a, b := results.Players[i], results.Players[j]
+		return rankedBefore(a.Score, b.Score, a.Handle, b.Handle, a.TeamID, b.TeamID)
+	})
+
+	results.Tournaments = results.Tournaments[:min(len(results.Tournaments), query.Limit)]
+	results.Teams = results.Teams[:min(len(results.Teams), query.Limit)]
+	results.Players = results.Players[:min(len(results.Players), query.Limit)]
+	return results
+}
+
+// Reports whether a hit with score a, name aName and ID aID is ranked before one with b, bName and bID
+func rankedBefore(a, b float64, aName, bName string, aID, bID primitive.ObjectID) bool {
+	if a != b {
+		return a > b
+	}
+	if aName != bName {
+		return aName < bName
+	}
+	return aID.Hex() < bID.Hex()
+}
+
+// How many candidates each Mongo query fetches before they are ranked
+const searchCandidates = 200
+
+// Searches with the text indexes on tournaments and teams. A text index only finds whole
+// words, so each collection is also searched by the indexed search keys, which find
+// prefixes and words with typos. The documents sharing the most keys with the search are
+// taken, and both sets of candidates are then ranked the same way as any other engine's.
+func SearchMongo(c context.Context, scope OwnerScope, query SearchQuery) (*SearchResults, error) {
+	tournaments, err := searchCandidatesIn[Tournament](c, collectionNamed("tournaments"), scope, query)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := searchCandidatesIn[Team](c, collectionNamed("teams"), scope, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankSearchResults(query, tournaments, teams), nil
+}
+
+// Ties are broken by ID so the same search always finds the same candidates
+func searchCandidatesIn[T any](c context.Context, collection *mongo.Collection, scope OwnerScope, query SearchQuery) ([]*T, error) {
+	textFilter := notDeleted(scope.filter())
+	textFilter["$text"] = bson.M{"$search": strings.Join(searchWords(query.Text), " ")}
+	textOptions := options.Find().
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}}).
+		SetLimit(searchCandidates)
+
+	candidates, err := findAll[T](c, collection, textFilter, textOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := SearchKeys(query.Text)
+	keyFilter := notDeleted(scope.filter())
+	keyFilter["search_keys"] = bson.M{"$in": keys}
+	cursor, err := collection.Aggregate(c, mongo.Pipeline{
+		{{Key: "$match", Value: keyFilter}},
+		{{Key: "$addFields", Value: bson.M{"search_key_matches": bson.M{"$size": bson.M{"$setIntersection": bson.A{"$search_keys", keys}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "search_key_matches", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: searchCandidates}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(c)
+
+	more := []*T{}
+	if err := cursor.All(c, &more); err != nil {
+		return nil, err
+	}
+	return append(candidates, more...), nil
+}
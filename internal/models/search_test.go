@@ -0,0 +1,48 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+// Reports whether a search shares a key with the text, making the text a candidate
+func sharesSearchKey(search, text string) bool {
+	keys := SearchKeys(text)
+	for _, key := range SearchKeys(search) {
+		if slices.Contains(keys, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSearchKeysFindPrefixesAndTypos(t *testing.T) {
+	for _, test := range []struct {
+		search, text string
+		want         bool
+	}{
+		{"sn", "xX_Sniper_Xx", true},
+		{"snip", "xX_Sniper_Xx", true},
+		{"sniper", "xX_Sniper_Xx", true},
+		// Typos in the first letters still share the rest of the word
+		{"wniper", "xX_Sniper_Xx", true},
+		{"optci", "OpTic Texas", true},
+		// Short searches only find the start of words
+		{"ip", "xX_Sniper_Xx", false},
+		{"faze", "OpTic Texas", false},
+	} {
+		if got := sharesSearchKey(test.search, test.text); got != test.want {
+			t.Errorf("search %q on %q: got candidate %v, want %v", test.search, test.text, got, test.want)
+		}
+	}
+}
+
+func TestSearchKeysAreStable(t *testing.T) {
+	keys := SearchKeys("Sniper Sniper", "SNIPER")
+	if !slices.IsSorted(keys) || !slices.Equal(keys, slices.Compact(slices.Clone(keys))) {
+		t.Fatalf("got keys %v, want them sorted without repeats", keys)
+	}
+	if !slices.Equal(keys, SearchKeys("sniper")) {
+		t.Fatalf("got keys %v, want the same as one lower case word", keys)
+	}
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How long a refresh token can go unused before the session ends
+const RefreshTokenLifetime = 30 * 24 * time.Hour
+
+// A signed in device. Each session holds one refresh token, which is replaced every time it is used.
+type Session struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RefreshTokenHash string             `bson:"refresh_token_hash" json:"-"`
+	UserAgent        string             `bson:"user_agent" json:"user_agent"`
+	IPAddress        string             `bson:"ip_address" json:"ip_address"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt       time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt        time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt        *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	Current          bool               `bson:"-" json:"current"`
+}
+
+// Tokens handed to a client when it signs in or refreshes
+type AuthTokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+var ErrInvalidRefreshToken = errors.New("Invalid refresh token")
+
+func getJWTSecret() (string, error) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		return "", errors.New("SECRET_KEY environment variable is not set")
+	}
+	return jwtSecret, nil
+}
+
+// Refresh tokens are the session ID and a random secret, so the session can be found
+// from the token and only a hash of the secret needs to be stored
+func newRefreshToken(sessionID primitive.ObjectID) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return sessionID.Hex() + "." + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issues an access token for a session along with its new refresh token
+func issueTokens(session *Session, refreshToken string) (*AuthTokens, error) {
+	jwtSecret, err := getJWTSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := auth.GenerateJWT(session.UserID, session.ID, jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenLifetime.Seconds()),
+	}, nil
+}
+
+// Where a request came from, recorded on the session so users can recognise it
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// Starts a new session for a user who has just signed in
+func CreateSession(c context.Context, sessions SessionRepository, user *User, client ClientInfo) (*AuthTokens, error) {
+	now := time.Now().UTC()
+	session := &Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     user.ID,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(RefreshTokenLifetime),
+	}
+
+	refreshToken, err := newRefreshToken(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	session.RefreshTokenHash = hashToken(refreshToken)
+
+	if err := sessions.Create(c, session); err != nil {
+		return nil, err
+	}
+
+	return issueTokens(session, refreshToken)
+}
+
+// Gets the session ID a refresh token starts with
+func refreshTokenSessionID(refreshToken string) (primitive.ObjectID, error) {
+	sessionIDStr, _, found := strings.Cut(refreshToken, ".")
+	if !found {
+		return primitive.NilObjectID, ErrInvalidRefreshToken
+	}
+	sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidRefreshToken
+	}
+	return sessionID, nil
+}
+
+// Swaps a refresh token for a new access token and refresh token. Using a refresh
+// token that has already been swapped means it has leaked, so the session is revoked.
+func RefreshSession(c context.Context, sessions SessionRepository, refreshToken string, client ClientInfo) (*AuthTokens, error) {
+	sessionID, err := refreshTokenSessionID(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := sessions.GetByID(c, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if session.RefreshTokenHash != hashToken(refreshToken) {
+		if err := sessions.Revoke(c, SessionFilter{ID: session.ID}); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newToken, err := newRefreshToken(session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only rotate if nobody else used the same token in the meantime
+	previousHash := session.RefreshTokenHash
+	session.RefreshTokenHash = hashToken(newToken)
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(RefreshTokenLifetime)
+	session.UserAgent = client.UserAgent
+	session.IPAddress = client.IPAddress
+	if err := sessions.Rotate(c, session, previousHash); err != nil {
+		return nil, err
+	}
+
+	return issueTokens(session, newToken)
+}
+
+// Revokes one of a user's sessions
+func RevokeSession(c context.Context, sessions SessionRepository, userID, sessionID primitive.ObjectID) error {
+	return sessions.Revoke(c, SessionFilter{ID: sessionID, UserID: userID})
+}
+
+// Revokes the session a refresh token belongs to, used when signing out without an access token
+func RevokeSessionByRefreshToken(c context.Context, sessions SessionRepository, refreshToken string) error {
+	sessionID, err := refreshTokenSessionID(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return sessions.Revoke(c, SessionFilter{ID: sessionID, RefreshTokenHash: hashToken(refreshToken)})
+}
+
+// Revokes every session a user has, signing them out everywhere
+func RevokeAllSessions(c context.Context, sessions SessionRepository, userID primitive.ObjectID) error {
+	return sessions.Revoke(c, SessionFilter{UserID: userID})
+}
+
+// Checks sessions for auth.AuthMiddleware
+type SessionStore struct {
+	Sessions SessionRepository
+}
+
+func (s SessionStore) IsSessionActive(c context.Context, sessionIDStr string) (bool, error) {
+	sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
+	if err != nil {
+		return false, nil
+	}
+
+	return s.Sessions.IsActive(c, sessionID)
+}
@@ -1,109 +1,106 @@
 package models
 
 import (
-	"encoding/json"
-	"errors"
-	"log"
+	"context"
 
-	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
-	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Team struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty"`
-	Name         string             `bson:"name" binding:"required"`
-	OrganiserID  primitive.ObjectID `bson:"organiser_id" binding:"required"`
-	Players      []string           `bson:"players"`
-	TournamentID primitive.ObjectID `bson:"tournament_id,omitempty"`
-	WebSocketHub *realtimemanager.WebSocketHub
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Name        string             `bson:"name" binding:"required"`
+	LogoURL     string             `bson:"logo_url"`
+	OrganiserID primitive.ObjectID `bson:"organiser_id" binding:"required"`
+	// Organisation that owns the team, empty for teams created before organisations
+	OrganisationID primitive.ObjectID `bson:"organisation_id,omitempty"`
+	Players        []string           `bson:"players"`
+	TournamentID   primitive.ObjectID `bson:"tournament_id,omitempty"`
+	// What search finds the team by, from its name and players
+	SearchKeys []string `bson:"search_keys" json:"-"`
+	// Incremented on every update
+	Version    int64 `bson:"version"`
+	Timestamps `bson:",inline"`
+	Deletion   `bson:",inline"`
 }
 
-// add a team to a tournament
-func AddTeamToTournament(c *gin.Context, teamID, tournamentID primitive.ObjectID) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournament")
-
-	update := bson.M{"$push": bson.M{"teams": teamID}}
-
-	_, err := collection.UpdateOne(c, bson.M{"_id": tournamentID}, update)
-	if err != nil {
-		return err
-	}
-
-	return nil
+// The filters and sorts for listing teams. from and to are on when they were created.
+var TeamListSchema = ListSchema{
+	Filters: map[string]FilterParser{
+		"tournament": idFilter("tournament_id"),
+		"from":       fromFilter("created_at"),
+		"to":         toFilter("created_at"),
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "name",
 }
 
 // Creates a new Team
-func CreateTeam(c *gin.Context, team *Team) (*Team, error) {
+func CreateTeam(c context.Context, team *Team) (*Team, error) {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("teams")
 
-	result, err := collection.InsertOne(c, team)
-	if err != nil {
-		return nil, err
-	}
-
-	team.ID = result.InsertedID.(primitive.ObjectID)
-
-	// Broadcast the message to WebSocket clients
-	message := map[string]interface{}{
-		"action":        "team_created",
-		"match_id":      team.ID.Hex(),
-		"name":          team.Name,
-		"players":       team.Players,
-		"tournament_id": team.TournamentID.Hex(),
-	}
+	team.Version = 1
+	team.created()
+	team.SearchKeys = SearchKeys(append([]string{team.Name}, team.Players...)...)
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
-		return team, nil
-	}
+	// Insert the team and record its event together so one never exists without the other
+	err := withTransaction(c, func(sc mongo.SessionContext) error {
+		result, err := collection.InsertOne(sc, team)
+		if err != nil {
+			return err
+		}
 
-	log.Printf("Broadcasting WebSocket message: %s", messageJSON)
-	team.WebSocketHub.Broadcast(messageJSON)
+		team.ID = result.InsertedID.(primitive.ObjectID)
 
-	return team, nil
-}
+		// Join the tournament in the same transaction, so a missing tournament rolls back the insert
+		if !team.TournamentID.IsZero() {
+			if err := addToTournament(sc, "teams", team.TournamentID, team.ID); err != nil {
+				return err
+			}
+		}
 
-// Get Matches by organiser id
-func GetTeamsByOrganiserID(c *gin.Context, organiserID primitive.ObjectID) ([]*Team, error) {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("teams")
+		// Construct the WebSocket message for team creation
+		message := map[string]interface{}{
+			"action":        "team_created",
+			"team_id":       team.ID.Hex(),
+			"name":          team.Name,
+			"logo_url":      team.LogoURL,
+			"players":       team.Players,
+			"tournament_id": team.TournamentID.Hex(),
+			"version":       team.Version,
+		}
 
-	cursor, err := collection.Find(c, bson.M{"organiser_id": organiserID})
+		return recordOutboxEvent(sc, message)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(c)
 
-	var teams []*Team
-	for cursor.Next(c) {
-		var team Team
-		if err := cursor.Decode(&team); err != nil {
-			return nil, err
-		}
-		teams = append(teams, &team)
-	}
+	return team, nil
+}
 
-	if err := cursor.Err(); err != nil {
-		return nil, err
-	}
+// Gets a page of the teams a user can see
+func GetTeams(c context.Context, scope OwnerScope, query ListQuery) (*Page[Team], error) {
+	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("teams")
 
-	return teams, nil
+	return findPage[Team](c, collection, notDeleted(scope.filter()), query)
 }
 
 // Retrieves a team by id
-func GetTeamByID(c *gin.Context, id primitive.ObjectID) (*Team, error) {
+func GetTeamByID(c context.Context, id primitive.ObjectID) (*Team, error) {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("teams")
 
 	var team Team
-	err := collection.FindOne(c, bson.M{"_id": id}).Decode(&team)
+	err := collection.FindOne(c, notDeleted(bson.M{"_id": id})).Decode(&team)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("Team not found")
+			return nil, ErrTeamNotFound
 		}
 		return nil, err
 	}
@@ -111,45 +108,64 @@ func GetTeamByID(c *gin.Context, id primitive.ObjectID) (*Team, error) {
 	return &team, nil
 }
 
-// Updates an existing team
-func UpdateTeam(c *gin.Context, id primitive.ObjectID, updatedTeam *Team) error {
+// Updates a team if it is still at updatedTeam.Version, which is then incremented
+func UpdateTeam(c context.Context, id primitive.ObjectID, updatedTeam *Team) error {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("teams")
 
-	update := bson.M{"$set": updatedTeam}
-	_, err := collection.UpdateOne(c, bson.M{"_id": id}, update)
-	if err != nil {
-		return err
-	}
+	expectedVersion := updatedTeam.Version
+	err := withTransaction(c, func(sc mongo.SessionContext) error {
+		updatedTeam.Version = expectedVersion + 1
+		updatedTeam.updated()
+		updatedTeam.SearchKeys = SearchKeys(append([]string{updatedTeam.Name}, updatedTeam.Players...)...)
+		update := bson.M{"$set": updatedTeam}
+		var previous Team
+		err := collection.FindOneAndUpdate(sc, versionFilter(notDeleted(bson.M{"_id": id}), expectedVersion), update).Decode(&previous)
+		if err != nil {
+			// Nothing changed, so there is nothing to tell clients about
+			if err == mongo.ErrNoDocuments {
+				return versionMismatch(sc, "teams", id, ErrTeamNotFound)
+			}
+			return err
+		}
 
-	// Broadcast the message to WebSocket clients
-	message := map[string]interface{}{
-		"action":        "team_created",
-		"match_id":      updatedTeam.ID.Hex(),
-		"name":          updatedTeam.Name,
-		"players":       updatedTeam.Players,
-		"tournament_id": updatedTeam.TournamentID.Hex(),
-	}
+		// Move it between tournaments in the same transaction. An empty tournament isn't set, so it stays put.
+		if !updatedTeam.TournamentID.IsZero() {
+			if err := moveBetweenTournaments(sc, "teams", id, previous.TournamentID, updatedTeam.TournamentID); err != nil {
+				return err
+			}
+		}
+
+		// Construct the WebSocket message for team update
+		message := map[string]interface{}{
+			"action":        "team_updated",
+			"team_id":       id.Hex(),
+			"name":          updatedTeam.Name,
+			"logo_url":      updatedTeam.LogoURL,
+			"players":       updatedTeam.Players,
+			"tournament_id": updatedTeam.TournamentID.Hex(),
+			"version":       updatedTeam.Version,
+		}
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(message)
+		return recordOutboxEvent(sc, message)
+	})
 	if err != nil {
-		return err
+		updatedTeam.Version = expectedVersion
 	}
 
-	log.Printf("Broadcasting WebSocket message: %s", messageJSON)
-	updatedTeam.WebSocketHub.Broadcast(messageJSON)
-
-	return nil
+	return err
 }
 
-// Delets a team by it's ID
-func DeleteTeam(c *gin.Context, id primitive.ObjectID) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("teams")
+// Soft deletes a team, cascading to its matches by the rules
+func DeleteTeam(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return softDelete(c, "teams", id, rules, ErrTeamNotFound)
+}
 
-	_, err := collection.DeleteOne(c, bson.M{"_id": id})
-	if err != nil {
-		return err
-	}
+// Gets a soft deleted team
+func GetDeletedTeamByID(c context.Context, id primitive.ObjectID) (*Team, error) {
+	return getDeleted[Team](c, "teams", id, ErrTeamNotFound)
+}
 
-	return nil
+// Restores a soft deleted team along with everything deleted with it
+func RestoreTeam(c context.Context, id primitive.ObjectID) error {
+	return restoreDeleted(c, "teams", id, ErrTeamNotFound)
 }
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"time"
+	// Time zones are looked up from a copy built into the binary, so they work on hosts without one
+	_ "time/tzdata"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	ErrInvalidTimeZone           = errors.New("Time zone must be an IANA time zone such as Europe/London")
+	ErrTournamentDatesRequired   = errors.New("The tournament needs a start and end date")
+	ErrTournamentEndsBeforeStart = errors.New("The tournament can't end before it starts")
+	ErrMatchOutsideTournament    = errors.New("The match must be scheduled between the tournament's start and end dates")
+)
+
+// When a document was created and last changed, in UTC. A zero CreatedAt isn't written, so
+// updating a document with one keeps the stored value.
+type Timestamps struct {
+	CreatedAt time.Time `bson:"created_at,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// Stamps a new document
+func (t *Timestamps) created() {
+	t.CreatedAt = utcNow()
+	t.UpdatedAt = t.CreatedAt
+}
+
+// Stamps a changed document
+func (t *Timestamps) updated() {
+	t.UpdatedAt = utcNow()
+}
+
+// The current time in UTC to the millisecond, which is as precise as MongoDB stores it,
+// so a document returned after a write matches the one read back later
+func utcNow() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// Adds the version increment and updated_at that every change to an entity makes to an
+// update, for writes that don't $set the whole document
+func bumped(update bson.M) bson.M {
+	update["$inc"] = bson.M{"version": 1}
+	return touched(update)
+}
+
+// Adds updated_at to an update
+func touched(update bson.M) bson.M {
+	update["$currentDate"] = bson.M{"updated_at": true}
+	return update
+}
+
+// Converts a time to UTC, to the millisecond
+func UTC(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC().Truncate(time.Millisecond)
+}
+
+// Formats a time for an event, empty if it isn't set
+func eventTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
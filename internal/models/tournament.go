@@ -1,154 +1,218 @@
 package models
 
 import (
-	"encoding/json"
-	"errors"
-	"log"
+	"context"
+	"strings"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
-	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/realtimemanager"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Tournament struct {
-	ID           primitive.ObjectID   `bson:"_id,omitempty"`
-	Name         string               `bson:"name" binding:"required"`
-	Description  string               `bson:"description"`
-	StartDate    string               `bson:"start_date" binding:"required"`
-	EndDate      string               `bson:"end_date" binding:"required"`
-	OrganiserID  primitive.ObjectID   `bson:"organiser_id" binding:"required"`
-	Teams        []primitive.ObjectID `bson:"teams"`
-	Matches      []primitive.ObjectID `bson:"matches"`
-	WebSocketHub *realtimemanager.WebSocketHub
-}
-
-// Adds teams to tournaments
-func AddTeamsToTournament(c *gin.Context, tournamentID primitive.ObjectID, teamIDs []primitive.ObjectID) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Name        string             `bson:"name" binding:"required"`
+	Description string             `bson:"description"`
+	StartDate   time.Time          `bson:"start_date" binding:"required"`
+	EndDate     time.Time          `bson:"end_date" binding:"required"`
+	// IANA time zone the tournament's dates are shown in, UTC if not set
+	TimeZone    string             `bson:"time_zone"`
+	OrganiserID primitive.ObjectID `bson:"organiser_id" binding:"required"`
+	// Organisation that owns the tournament, empty for tournaments created before organisations
+	OrganisationID primitive.ObjectID   `bson:"organisation_id,omitempty"`
+	Teams          []primitive.ObjectID `bson:"teams"`
+	Matches        []primitive.ObjectID `bson:"matches"`
+	StaffIDs       []primitive.ObjectID `bson:"staff_ids"`
+	// Only published tournaments are shown on the public spectator API
+	Published bool `bson:"published"`
+	// What search finds the tournament by, from its name and description
+	SearchKeys []string `bson:"search_keys" json:"-"`
+	// Incremented on every update
+	Version    int64 `bson:"version"`
+	Timestamps `bson:",inline"`
+	Deletion   `bson:",inline"`
+}
 
-	update := bson.M{"$push": bson.M{"teams": bson.M{"$each": teamIDs}}}
-	_, err := collection.UpdateOne(c, bson.M{"_id": tournamentID}, update)
-	if err != nil {
-		return err
-	}
+// The filters and sorts for listing tournaments. from and to find the tournaments running
+// at any time between them.
+var TournamentListSchema = ListSchema{
+	Filters: map[string]FilterParser{
+		"status":    statusFilter(tournamentStatuses),
+		"from":      fromFilter("end_date"),
+		"to":        toFilter("start_date"),
+		"team":      idFilter("teams"),
+		"published": boolFilter("published"),
+	},
+	Sorts: map[string]string{
+		"start_date": "start_date",
+		"end_date":   "end_date",
+		"name":       "name",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "start_date",
+}
 
-	return nil
+// A tournament is upcoming until it starts, live until it ends and then finished
+var tournamentStatuses = map[string]func(now time.Time) []Condition{
+	"upcoming": func(now time.Time) []Condition {
+		return []Condition{{Fields: []string{"start_date"}, From: now.Add(time.Millisecond)}}
+	},
+	"live": func(now time.Time) []Condition {
+		return []Condition{{Fields: []string{"start_date"}, To: now}, {Fields: []string{"end_date"}, From: now}}
+	},
+	"finished": func(now time.Time) []Condition {
+		return []Condition{{Fields: []string{"end_date"}, To: now.Add(-time.Millisecond)}}
+	},
 }
 
-// Add matches to a tournament
-func AddMatchesToTournament(c *gin.Context, tournamentID primitive.ObjectID, matchIDs []primitive.ObjectID) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
+// Gets the time zone the tournament's dates are shown in
+func (t *Tournament) Location() *time.Location {
+	if location, err := time.LoadLocation(t.TimeZone); err == nil {
+		return location
+	}
+	return time.UTC
+}
 
-	update := bson.M{"$push": bson.M{"matches": bson.M{"$each": matchIDs}}}
-	_, err := collection.UpdateOne(c, bson.M{"_id": tournamentID}, update)
-	if err != nil {
-		return err
+// Converts the tournament's dates to UTC and checks they are set and in order, and checks
+// its time zone, which is set to UTC if empty
+func (t *Tournament) NormaliseSchedule() error {
+	if t.TimeZone == "" {
+		t.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(t.TimeZone); err != nil || strings.EqualFold(t.TimeZone, "Local") {
+		return ErrInvalidTimeZone
 	}
 
+	// binding's required doesn't catch zero times, which is what null leaves in a patch
+	if t.StartDate.IsZero() || t.EndDate.IsZero() {
+		return ErrTournamentDatesRequired
+	}
+
+	t.StartDate, t.EndDate = UTC(t.StartDate), UTC(t.EndDate)
+	if t.EndDate.Before(t.StartDate) {
+		return ErrTournamentEndsBeforeStart
+	}
 	return nil
 }
 
-// Get user id from context
-func GetUserIDFromContext(c *gin.Context) (string, error) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		return "", errors.New("User ID not found in context")
+// Checks a match date falls within the tournament. Unscheduled matches always do.
+func (t *Tournament) CheckMatchDate(date time.Time) error {
+	if date.IsZero() {
+		return nil
 	}
-
-	userIDStr, ok := userID.(string)
-	if !ok {
-		return "", errors.New("User ID is not a string")
+	if date.Before(t.StartDate) || date.After(t.EndDate) {
+		return ErrMatchOutsideTournament
 	}
+	return nil
+}
 
-	return userIDStr, nil
+// Works out whether a user runs a tournament, either because they can manage it or
+// because they are on its staff. A zero userID is someone who isn't signed in.
+func IsTournamentStaff(c context.Context, organisations OrganisationRepository, tournament *Tournament, userID primitive.ObjectID) (bool, error) {
+	if userID.IsZero() {
+		return false, nil
+	}
+	if containsObjectID(tournament.StaffIDs, userID) {
+		return true, nil
+	}
+	return CanManageResource(c, organisations, tournament.OrganisationID, tournament.OrganiserID, userID)
 }
 
-// - Creates a new tournament
-func CreateTournament(c *gin.Context, tournament *Tournament) (*Tournament, error) {
+// Adds a team or match to a tournament's "teams" or "matches" list as part of a
+// transaction, failing if the tournament doesn't exist or is deleted so the transaction rolls back
+func addToTournament(sc mongo.SessionContext, field string, tournamentID, id primitive.ObjectID) error {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
 
-	result, err := collection.InsertOne(c, tournament)
+	update := bumped(bson.M{"$addToSet": bson.M{field: id}})
+	result, err := collection.UpdateOne(sc, notDeleted(bson.M{"_id": tournamentID}), update)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrTournamentNotFound
 	}
 
-	tournament.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
 
-	if len(tournament.Teams) > 0 {
-		err = AddTeamsToTournament(c, tournament.ID, tournament.Teams)
-		if err != nil {
-			return nil, err
-		}
+// Moves a team or match from one tournament's list to another's as part of a transaction.
+// Either tournament may be empty.
+func moveBetweenTournaments(sc mongo.SessionContext, field string, id, from, to primitive.ObjectID) error {
+	if from == to {
+		return nil
 	}
 
-	if len(tournament.Matches) > 0 {
-		err = AddMatchesToTournament(c, tournament.ID, tournament.Matches)
-		if err != nil {
-			return nil, err
+	if !from.IsZero() {
+		collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
+		update := bumped(bson.M{"$pull": bson.M{field: id}})
+		if _, err := collection.UpdateOne(sc, bson.M{"_id": from}, update); err != nil {
+			return err
 		}
 	}
 
-	// Broadcast the message to WebSocket clients
-	message := map[string]interface{}{
-		"action":        "tournament_created",
-		"tournament_id": tournament.ID.Hex(),
-		"name":          tournament.Name,
-		"desccription":  tournament.Description,
-		"start_date":    tournament.StartDate,
-		"end_date":      tournament.EndDate,
+	if !to.IsZero() {
+		return addToTournament(sc, field, to, id)
 	}
+	return nil
+}
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
-		return tournament, nil
-	}
+// - Creates a new tournament
+func CreateTournament(c context.Context, tournament *Tournament) (*Tournament, error) {
+	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
 
-	log.Printf("Broadcasting WebSocket message: %s", messageJSON)
-	tournament.WebSocketHub.Broadcast(messageJSON)
+	tournament.Version = 1
+	tournament.created()
+	tournament.SearchKeys = SearchKeys(tournament.Name, tournament.Description)
 
-	return tournament, nil
-}
+	// Insert the tournament and record its event together so one never exists without the other
+	err := withTransaction(c, func(sc mongo.SessionContext) error {
+		result, err := collection.InsertOne(sc, tournament)
+		if err != nil {
+			return err
+		}
 
-// GetAllTournaments
-func GetTournamentsByOrganiserID(c *gin.Context, OrganiserID primitive.ObjectID) ([]*Tournament, error) {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
+		tournament.ID = result.InsertedID.(primitive.ObjectID)
+
+		// Construct the WebSocket message for tournament creation
+		message := map[string]interface{}{
+			"action":        "tournament_created",
+			"tournament_id": tournament.ID.Hex(),
+			"name":          tournament.Name,
+			"desccription":  tournament.Description,
+			"start_date":    eventTime(tournament.StartDate),
+			"end_date":      eventTime(tournament.EndDate),
+			"time_zone":     tournament.TimeZone,
+			"version":       tournament.Version,
+		}
 
-	cursor, err := collection.Find(c, bson.M{"organiser_id": OrganiserID})
+		return recordOutboxEvent(sc, message)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(c)
 
-	var tournaments []*Tournament
-	for cursor.Next(c) {
-		var tournament Tournament
-		if err := cursor.Decode(&tournament); err != nil {
-			return nil, err
-		}
-		tournaments = append(tournaments, &tournament)
-	}
+	return tournament, nil
+}
 
-	if err := cursor.Err(); err != nil {
-		return nil, err
-	}
+// Gets a page of the tournaments a user can see
+func GetTournaments(c context.Context, scope OwnerScope, query ListQuery) (*Page[Tournament], error) {
+	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
 
-	return tournaments, nil
+	return findPage[Tournament](c, collection, notDeleted(scope.filter()), query)
 }
 
 // - GetTournamentByID
-func GetTournamentByID(c *gin.Context, id primitive.ObjectID) (*Tournament, error) {
+func GetTournamentByID(c context.Context, id primitive.ObjectID) (*Tournament, error) {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
 
 	var tournament Tournament
-	err := collection.FindOne(c, bson.M{"_id": id}).Decode(&tournament)
+	err := collection.FindOne(c, notDeleted(bson.M{"_id": id})).Decode(&tournament)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("Tournament not found")
+			return nil, ErrTournamentNotFound
 		}
 		return nil, err
 	}
@@ -156,46 +220,58 @@ func GetTournamentByID(c *gin.Context, id primitive.ObjectID) (*Tournament, erro
 	return &tournament, nil
 }
 
-// - UpdateTournament
-func UpdateTournament(c *gin.Context, id primitive.ObjectID, updatedTournament *Tournament) error {
+// Updates a tournament if it is still at updatedTournament.Version, which is then incremented
+func UpdateTournament(c context.Context, id primitive.ObjectID, updatedTournament *Tournament) error {
 	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
 
-	update := bson.M{"$set": updatedTournament}
-	_, err := collection.UpdateOne(c, bson.M{"_id": id}, update)
-	if err != nil {
-		return err
-	}
+	expectedVersion := updatedTournament.Version
+	err := withTransaction(c, func(sc mongo.SessionContext) error {
+		updatedTournament.Version = expectedVersion + 1
+		updatedTournament.updated()
+		updatedTournament.SearchKeys = SearchKeys(updatedTournament.Name, updatedTournament.Description)
+		update := bson.M{"$set": updatedTournament}
+		result, err := collection.UpdateOne(sc, versionFilter(notDeleted(bson.M{"_id": id}), expectedVersion), update)
+		if err != nil {
+			return err
+		}
 
-	// Broadcast the message to WebSocket clients
-	message := map[string]interface{}{
-		"action":        "tournament_created",
-		"tournament_id": updatedTournament.ID.Hex(),
-		"name":          updatedTournament.Name,
-		"desccription":  updatedTournament.Description,
-		"start_date":    updatedTournament.StartDate,
-		"end_date":      updatedTournament.EndDate,
-	}
+		// Nothing changed, so there is nothing to tell clients about
+		if result.MatchedCount == 0 {
+			return versionMismatch(sc, "tournaments", id, ErrTournamentNotFound)
+		}
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(message)
+		// Construct the WebSocket message for tournament update
+		message := map[string]interface{}{
+			"action":        "tournament_updated",
+			"tournament_id": id.Hex(),
+			"name":          updatedTournament.Name,
+			"desccription":  updatedTournament.Description,
+			"start_date":    eventTime(updatedTournament.StartDate),
+			"end_date":      eventTime(updatedTournament.EndDate),
+			"time_zone":     updatedTournament.TimeZone,
+			"version":       updatedTournament.Version,
+		}
+
+		return recordOutboxEvent(sc, message)
+	})
 	if err != nil {
-		return err
+		updatedTournament.Version = expectedVersion
 	}
 
-	log.Printf("Broadcasting WebSocket message: %s", messageJSON)
-	updatedTournament.WebSocketHub.Broadcast(messageJSON)
-
-	return nil
+	return err
 }
 
-// - DeleteTournament
-func DeleteTournament(c *gin.Context, id primitive.ObjectID) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("tournaments")
+// Soft deletes a tournament, cascading to its teams and matches by the rules
+func DeleteTournament(c context.Context, id primitive.ObjectID, rules CascadeRules) error {
+	return softDelete(c, "tournaments", id, rules, ErrTournamentNotFound)
+}
 
-	_, err := collection.DeleteOne(c, bson.M{"_id": id})
-	if err != nil {
-		return err
-	}
+// Gets a soft deleted tournament
+func GetDeletedTournamentByID(c context.Context, id primitive.ObjectID) (*Tournament, error) {
+	return getDeleted[Tournament](c, "tournaments", id, ErrTournamentNotFound)
+}
 
-	return nil
+// Restores a soft deleted tournament along with everything deleted with it
+func RestoreTournament(c context.Context, id primitive.ObjectID) error {
+	return restoreDeleted(c, "tournaments", id, ErrTournamentNotFound)
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"context"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Runs fn inside a MongoDB transaction, committing only if it returns nil.
+// fn may be retried on transient errors so it must not have side effects outside the session.
+func withTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := database.GetMongoClient().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Let the dispatcher publish any outbox entries the transaction recorded
+	signalOutbox()
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+)
+
+// Roles a user can hold. Members of an organisation and anyone who organises
+// a tournament also count as organisers.
+const (
+	RoleAdmin     = "admin"
+	RoleOrganiser = "organiser"
+)
+
+// Number of recovery codes handed out when two-factor authentication is turned on
+const recoveryCodeCount = 10
+
+// How long a user has to enter their code after their password was accepted
+const TwoFactorLoginTokenLifetime = 5 * time.Minute
+
+// A new authenticator secret waiting to be confirmed with a code
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+var (
+	ErrInvalidTwoFactorCode    = errors.New("Invalid two-factor code")
+	ErrTwoFactorAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("Two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp       = errors.New("Two-factor authentication has not been set up")
+	ErrTwoFactorRequired       = errors.New("Two-factor authentication must be enabled for this account")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Roles that must use two-factor authentication, from the comma separated REQUIRE_2FA_ROLES
+func twoFactorRequiredRoles() []string {
+	var roles []string
+	for _, role := range strings.Split(os.Getenv("REQUIRE_2FA_ROLES"), ",") {
+		if role = strings.ToLower(strings.TrimSpace(role)); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Reports whether a user holds a role, counting organisation members and tournament organisers as organisers
+func userHasRole(c context.Context, repos *Repositories, user *User, role string) (bool, error) {
+	for _, userRole := range user.Roles {
+		if strings.EqualFold(userRole, role) {
+			return true, nil
+		}
+	}
+
+	if role != RoleOrganiser {
+		return false, nil
+	}
+
+	organisations, err := repos.Organisations.ListForUser(c, user.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(organisations) > 0 {
+		return true, nil
+	}
+
+	// Tournaments from before organisations are still owned by their organiser
+	tournaments, err := repos.Tournaments.List(c, OwnerScope{OrganiserID: user.ID}, ListQuery{Sort: "created_at", Limit: 1})
+	if err != nil {
+		return false, err
+	}
+
+	return tournaments.Total > 0, nil
+}
+
+// Reports whether the two-factor policy requires a user to have two-factor authentication
+func TwoFactorRequired(c context.Context, repos *Repositories, user *User) (bool, error) {
+	for _, role := range twoFactorRequiredRoles() {
+		hasRole, err := userHasRole(c, repos, user, role)
+		if err != nil {
+			return false, err
+		}
+		if hasRole {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Generates a new authenticator secret for a user. It isn't used until EnableTwoFactor confirms it.
+func SetupTwoFactor(c context.Context, users UserRepository, user *User) (*TwoFactorSetup, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := users.SetTwoFactorPendingSecret(c, user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(user.Email, secret),
+	}, nil
+}
+
+// Turns on two-factor authentication once the user proves their authenticator works,
+// returning their recovery codes. The codes are only ever shown this once.
+func EnableTwoFactor(c context.Context, users UserRepository, user *User, code string) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TwoFactorPendingSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	step, ok := auth.ValidateTOTP(user.TwoFactorPendingSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fails if the secret was replaced by another setup since the user read it
+	if err := users.EnableTwoFactor(c, user.ID, user.TwoFactorPendingSecret, recoveryCodeHashes, step); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// Turns off two-factor authentication, unless the policy requires the user to keep it
+func DisableTwoFactor(c context.Context, repos *Repositories, user *User, code string) error {
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	required, err := TwoFactorRequired(c, repos, user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+
+	if err := verifyTwoFactorCode(c, repos.Users, user, code); err != nil {
+		return err
+	}
+
+	return repos.Users.DisableTwoFactor(c, user.ID)
+}
+
+// Replaces a user's recovery codes with new ones
+func RegenerateRecoveryCodes(c context.Context, users UserRepository, user *User, code string) ([]string, error) {
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err := verifyTwoFactorCode(c, users, user, code); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := users.SetRecoveryCodes(c, user.ID, recoveryCodeHashes); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// Finishes a login that is waiting on a two-factor code, starting the user's session
+func CompleteTwoFactorLogin(c context.Context, repos *Repositories, loginToken, code string, client ClientInfo) (*AuthTokens, error) {
+	accountToken, err := findAccountToken(c, repos.AccountTokens, loginToken, TokenPurposeTwoFactorLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := repos.Users.GetByID(c, accountToken.UserID)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, ErrInvalidAccountToken
+		}
+		return nil, err
+	}
+
+	// Codes are guessed as easily as passwords, so they share the login lockouts
+	ip := client.IPAddress
+	remaining, err := loginLockRemaining(c, repos.LoginAttempts, accountLoginKey(user.Email), ipLoginKey(ip))
+	if err != nil {
+		return nil, err
+	}
+	if remaining > 0 {
+		return nil, &LoginLockedError{RetryAfter: remaining}
+	}
+
+	if err := verifyTwoFactorCode(c, repos.Users, user, code); err != nil {
+		if err == ErrInvalidTwoFactorCode {
+			if err := handleLoginFailure(c, repos, user.Email, ip, &user.ID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	if _, err := consumeAccountToken(c, repos.AccountTokens, loginToken, TokenPurposeTwoFactorLogin); err != nil {
+		return nil, err
+	}
+
+	if err := repos.LoginAttempts.Clear(c, accountLoginKey(user.Email)); err != nil {
+		return nil, err
+	}
+
+	return CreateSession(c, repos.Sessions, user, client)
+}
+
+// Checks a code from the user's authenticator, or one of their recovery codes.
+// Each authenticator code and recovery code only works once.
+func verifyTwoFactorCode(c context.Context, users UserRepository, user *User, code string) error {
+	used := false
+	var err error
+	if step, ok := auth.ValidateTOTP(user.TwoFactorSecret, code, time.Now()); ok {
+		used, err = users.UseTwoFactorStep(c, user.ID, step)
+	} else {
+		used, err = users.UseRecoveryCode(c, user.ID, hashToken(normaliseRecoveryCode(code)))
+	}
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// Generates a set of recovery codes along with the hashes that are stored
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		secret := make([]byte, 6)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+		encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(secret))
+		code := encoded[:5] + "-" + encoded[5:10]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normaliseRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// Recovery codes are accepted with or without the dash and in any case
+func normaliseRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -1,18 +1,13 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
-	"os"
 	"regexp"
+	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
-	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/database"
-	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/validation"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -22,6 +17,28 @@ type User struct {
 	Username string             `bson:"username"`
 	Email    string             `bson:"email"`
 	Password string             `bson:"password"`
+	// Users cannot sign in until they have followed the link in their verification email
+	EmailVerified   bool       `bson:"email_verified"`
+	EmailVerifiedAt *time.Time `bson:"email_verified_at,omitempty"`
+	Roles           []string   `bson:"roles,omitempty"`
+	// Accounts at external login providers linked to the user
+	Identities []UserIdentity `bson:"identities,omitempty"`
+	// TOTP two-factor authentication. Recovery codes are stored hashed.
+	TwoFactorEnabled       bool     `bson:"two_factor_enabled"`
+	TwoFactorSecret        string   `bson:"two_factor_secret,omitempty"`
+	TwoFactorPendingSecret string   `bson:"two_factor_pending_secret,omitempty"`
+	TwoFactorRecoveryCodes []string `bson:"two_factor_recovery_codes,omitempty"`
+	TwoFactorLastStep      int64    `bson:"two_factor_last_step,omitempty"`
+	Timestamps             `bson:",inline"`
+}
+
+// What a login returns. When two-factor authentication is on there are no tokens yet,
+// just a token to send with the code to /users/login/2fa.
+type LoginResult struct {
+	*AuthTokens
+	TwoFactorRequired      bool   `json:"two_factor_required,omitempty"`
+	TwoFactorToken         string `json:"two_factor_token,omitempty"`
+	TwoFactorSetupRequired bool   `json:"two_factor_setup_required,omitempty"`
 }
 
 var (
@@ -29,65 +46,40 @@ var (
 	minPasswordLength = 8
 )
 
-// Queries DB to get a user by ID
-func GetUserByID(id primitive.ObjectID) (*User, error) {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("users")
-	var user User
-	err := collection.FindOne(nil, bson.M{"_id": id}).Decode(&user)
-	if err != nil {
-		return nil, err
-	}
-	return &user, nil
-}
-
-// Retrieves a user by their email fromthe db
-func GetUserByEmail(email string) (*User, error) {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("users")
-	var user User
-	err := collection.FindOne(nil, bson.M{"email": email}).Decode(&user)
-	if err != nil {
-		return nil, err
-	}
-
-	return &user, nil
-}
-
-// Updates a user document in the db
-func UpdateUserInDB(user *User) error {
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("users")
-	update := bson.M{"$set": user}
-	 _, err := collection.UpdateOne(nil, bson.M{"_id": user.ID}, update)
-	 if err != nil {
-		return err
-	 }
+// Errors returned when a user cannot sign in
+var (
+	ErrInvalidCredentials = errors.New("Invalid credentials")
+	ErrEmailNotVerified   = errors.New("Email address has not been verified")
+)
 
-	 return nil
-}
+// Errors returned when a user cannot register
+var (
+	ErrInvalidEmail = errors.New("invalid email format")
+	ErrEmailInUse   = errors.New("Email already exists")
+)
 
 // Register a new user and store them in the database.
-func RegisterUser(c *gin.Context, newUser *User) error {
-	fmt.Println("Received registration request:", newUser)
-
+func RegisterUser(c context.Context, users UserRepository, newUser *User) error {
 	// Validate email
 	if !emailRegex.MatchString(newUser.Email) {
-		return errors.New("invalid email format")
+		return ErrInvalidEmail
 	}
 
 	// Check if the email already exists in the db
-	emailExists, err := validation.EmailExistsInDB(newUser.Email)
-	if err != nil {
+	_, err := users.GetByEmail(c, newUser.Email)
+	if err != nil && err != ErrUserNotFound {
 		fmt.Println("Error checking email existence in the database:", err)
 		return err
 	}
-	if emailExists {
+	if err == nil {
 		fmt.Println("Email already exists")
-		return errors.New("Email already exists")
+		return ErrEmailInUse
 	}
 
 	// Validate password length - 9 characters
 	if len(newUser.Password) < minPasswordLength {
 		fmt.Println("Password must be at least 8 characters")
-		return errors.New("Password must be at least 8 characters")
+		return ErrPasswordTooShort
 	}
 
 	// Use Bcrypt to hash password
@@ -98,12 +90,20 @@ func RegisterUser(c *gin.Context, newUser *User) error {
 	}
 	newUser.Password = string(hashedPassword)
 
-	// Get a handle to the "users" collection
-	collection := database.GetMongoClient().Database("esports-tournament-manager").Collection("users")
+	// Addresses are only verified by following the emailed link
+	newUser.ID = primitive.NilObjectID
+	newUser.EmailVerified = false
+	newUser.EmailVerifiedAt = nil
+	newUser.Roles = nil
+	newUser.Identities = nil
+	newUser.TwoFactorEnabled = false
+	newUser.TwoFactorSecret = ""
+	newUser.TwoFactorPendingSecret = ""
+	newUser.TwoFactorRecoveryCodes = nil
+	newUser.TwoFactorLastStep = 0
 
 	// Insert new users document into collection
-	_, err = collection.InsertOne(c, newUser)
-	if err != nil {
+	if err := users.Create(c, newUser); err != nil {
 		fmt.Println("Error inserting user into the database:", err)
 		return err
 	}
@@ -111,95 +111,116 @@ func RegisterUser(c *gin.Context, newUser *User) error {
 	return nil
 }
 
-// Logs in a user and returns a JWT token on successful login
-func LoginUser(c *gin.Context, loginUser *struct {
-	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
-}) (string, error) {
+// Logs in a user and returns a JWT token and refresh token on successful login,
+// or a two-factor login token if the user has two-factor authentication on
+func LoginUser(c context.Context, repos *Repositories, email, password string, client ClientInfo) (*LoginResult, error) {
+	ip := client.IPAddress
 
-	// Check if user exists by email
-	user, err := GetUserByEmail(loginUser.Email)
-	fmt.Println("User from database:", user)
+	// Refuse locked out email and IP addresses before checking any password
+	remaining, err := loginLockRemaining(c, repos.LoginAttempts, accountLoginKey(email), ipLoginKey(ip))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if remaining > 0 {
+		return nil, &LoginLockedError{RetryAfter: remaining}
+	}
+
+	// Check if user exists by email. Unknown emails and wrong passwords get the
+	// same error so the response doesn't reveal which emails are registered.
+	user, err := repos.Users.GetByEmail(c, email)
+	if err != nil {
+		if err != ErrUserNotFound {
+			return nil, err
+		}
+		compareDummyPassword(password)
+		if err := handleLoginFailure(c, repos, email, ip, nil); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
 	// Compare provided password with the hashed password for the database
-	fmt.Println("user password:", user.Password)
-	fmt.Println("login password:", loginUser.Password)
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginUser.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return "", err
+		if err := handleLoginFailure(c, repos, email, ip, &user.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
-	// Generate a JWT token for the user
-	jwtSecret := os.Getenv("SECRET_KEY")
-	if jwtSecret == "" {
-		return "", errors.New("SECRET_KEY environment variable is not set")
+	if !user.EmailVerified {
+		return nil, ErrEmailNotVerified
 	}
-	token, err := auth.GenerateJWT(user.ID, jwtSecret)
-	if err != nil {
-		return "", err
+
+	// Failures are kept until the code has been entered too, so a known password
+	// can't be used to keep resetting the count while guessing codes
+	if user.TwoFactorEnabled {
+		return startTwoFactorLogin(c, repos.AccountTokens, user)
 	}
 
-	// Set the token ass an HTTP cookie
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     "jwtToken",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-	})
+	// Only the account's failures are forgotten, the IP may be trying other accounts
+	if err := repos.LoginAttempts.Clear(c, accountLoginKey(email)); err != nil {
+		return nil, err
+	}
 
-	return token, nil
+	return startLoginSession(c, repos, user, client)
 }
 
-// Logs out a user and and clears the JWT token cookie
-func LogoutUser(c *gin.Context) {
-	// Clear the JWT token cookie
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     "jwtToken",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		MaxAge:   -1,
-		SameSite: http.SameSiteNoneMode,
-	})
-
-	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+// Asks for the user's two-factor code before they are logged in
+func startTwoFactorLogin(c context.Context, tokens AccountTokenRepository, user *User) (*LoginResult, error) {
+	twoFactorToken, err := createAccountToken(c, tokens, user.ID, TokenPurposeTwoFactorLogin, TwoFactorLoginTokenLifetime)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{TwoFactorRequired: true, TwoFactorToken: twoFactorToken}, nil
 }
 
-// Updates the username and/or password of a user
-func UpdateUser(c *gin.Context, userID string, updateUser *struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}) error {
-
-	log.Println("Received updated user:", updateUser)
-	// Convert userID string to primitive.ObjectID
-	objectID, err := primitive.ObjectIDFromHex(userID)
+// Starts a session for a user who has proven who they are
+func startLoginSession(c context.Context, repos *Repositories, user *User, client ClientInfo) (*LoginResult, error) {
+	// Start a session and issue its tokens
+	tokens, err := CreateSession(c, repos.Sessions, user, client)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Let the client know to send the user to set up two-factor authentication
+	setupRequired, err := TwoFactorRequired(c, repos, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{AuthTokens: tokens, TwoFactorSetupRequired: setupRequired}, nil
+}
+
+// Logs out a user by revoking the session their access token belongs to, or failing
+// that the session of their refresh token. Nothing is revoked if neither is known.
+func LogoutUser(c context.Context, sessions SessionRepository, userID, sessionID primitive.ObjectID, refreshToken string) error {
+	if !userID.IsZero() && !sessionID.IsZero() {
+		return RevokeSession(c, sessions, userID, sessionID)
+	}
+	if refreshToken != "" {
+		return RevokeSessionByRefreshToken(c, sessions, refreshToken)
 	}
+	return nil
+}
 
+// Updates the username and/or password of a user
+func UpdateUser(c context.Context, repos *Repositories, userID primitive.ObjectID, username, password string) error {
 	// Check if user exists by ID
-	user, err := GetUserByID(objectID)
-	log.Println("Received user from the db:", user)
+	user, err := repos.Users.GetByID(c, userID)
 	if err != nil {
 		return err
 	}
 
 	// Update username if provided
-	if updateUser.Username != "" {
-		user.Username = updateUser.Username
+	if username != "" {
+		user.Username = username
 	}
 
 	// Update password if provided
-	if updateUser.Password != "" {
+	if password != "" {
 		// Hash new password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updateUser.Password), bcrypt.DefaultCost)
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 		if err != nil {
 			return err
 		}
@@ -207,12 +228,20 @@ func UpdateUser(c *gin.Context, userID string, updateUser *struct {
 	}
 
 	// Update the user in the database
-	err = UpdateUserInDB(user)
+	err = repos.Users.Update(c, user)
 
 	if err != nil {
 		log.Println("could not update the user:", err)
 		return err
 	}
 
+	// A new password signs the user out everywhere, in case the old one leaked
+	if password != "" {
+		if err := RevokeAllSessions(c, repos.Sessions, user.ID); err != nil {
+			log.Println("could not revoke the user's sessions:", err)
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tournaments, teams, matches and match results count their updates in a version. An
+// update is only made if the document is still at the version it was read at, so two
+// people editing the same document can't silently overwrite each other.
+
+var ErrVersionConflict = errors.New("It has been changed since you loaded it, reload it and try again")
+
+// Matches the version an update was made against. Documents from before versions have
+// none, which counts as 0.
+func versionFilter(filter bson.M, version int64) bson.M {
+	if version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	} else {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// Works out why a conditional update matched nothing: the document is gone, or it has
+// moved on to another version
+func versionMismatch(c context.Context, collection string, id primitive.ObjectID, notFound error) error {
+	live, err := mongoCascadeReader{}.isLive(c, collection, id)
+	if err != nil {
+		return err
+	}
+	if !live {
+		return notFound
+	}
+	return ErrVersionConflict
+}
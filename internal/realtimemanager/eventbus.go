@@ -0,0 +1,60 @@
+package realtimemanager
+
+import (
+	"context"
+	"sync"
+)
+
+// EventBus carries hub events between server instances. Every hub subscribed to
+// the bus receives every published event, including the ones it published itself.
+type EventBus interface {
+	// Publish sends an event to every subscriber of the bus
+	Publish(ctx context.Context, event Event) error
+	// Subscribe calls handler for each event on the bus until the returned func is called
+	Subscribe(handler func(Event)) (unsubscribe func(), err error)
+}
+
+// InProcessEventBus delivers events to subscribers in the same process only.
+// It is the default for single instance deployments and doubles as a fake in tests.
+type InProcessEventBus struct {
+	handlers map[int]func(Event)
+	nextID   int
+	mu       sync.RWMutex
+}
+
+func NewInProcessEventBus() *InProcessEventBus {
+	return &InProcessEventBus{
+		handlers: make(map[int]func(Event)),
+	}
+}
+
+// Publish synchronously hands the event to every subscriber. The handlers are called
+// without holding the lock, as they may publish events of their own.
+func (b *InProcessEventBus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := make([]func(Event), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return nil
+}
+
+func (b *InProcessEventBus) Subscribe(handler func(Event)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}, nil
+}
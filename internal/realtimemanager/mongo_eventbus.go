@@ -0,0 +1,141 @@
+package realtimemanager
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How long published events are kept in the events collection
+const mongoEventRetention = time.Hour
+
+// How long to wait before reopening a change stream that failed
+const mongoEventRetryDelay = 2 * time.Second
+
+// Shape of an event stored in the events collection
+type mongoEvent struct {
+	ID        string    `bson:"_id"`
+	Action    string    `bson:"action"`
+	Topics    []string  `bson:"topics"`
+	Data      string    `bson:"data"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// MongoEventBus shares events between server instances through a MongoDB collection.
+// Publishing inserts a document, and every instance tails the collection with a
+// change stream, so it needs a replica set (a single node replica set is enough).
+type MongoEventBus struct {
+	collection *mongo.Collection
+}
+
+// Creates the bus and the TTL index that stops the events collection growing forever
+func NewMongoEventBus(ctx context.Context, collection *mongo.Collection) (*MongoEventBus, error) {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(mongoEventRetention.Seconds())),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoEventBus{collection: collection}, nil
+}
+
+func (b *MongoEventBus) Publish(ctx context.Context, event Event) error {
+	_, err := b.collection.InsertOne(ctx, mongoEvent{
+		ID:        event.ID,
+		Action:    event.Action,
+		Topics:    event.Topics,
+		Data:      string(event.Data),
+		CreatedAt: time.Now().UTC(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		// Already published, subscribers will see the original insert
+		return nil
+	}
+	return err
+}
+
+// Subscribe opens a change stream on the events collection before returning, so
+// no event published after Subscribe returns is missed
+func (b *MongoEventBus) Subscribe(handler func(Event)) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := b.watch(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go b.tail(ctx, stream, handler)
+
+	return cancel, nil
+}
+
+// Opens a change stream for inserts, resuming after the token if there is one
+func (b *MongoEventBus) watch(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": "insert"}}},
+	}
+
+	opts := options.ChangeStream()
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	}
+
+	return b.collection.Watch(ctx, pipeline, opts)
+}
+
+// Reads the change stream until the context is cancelled, reopening it on errors
+func (b *MongoEventBus) tail(ctx context.Context, stream *mongo.ChangeStream, handler func(Event)) {
+	var resumeToken bson.Raw
+
+	for {
+		for stream.Next(ctx) {
+			var change struct {
+				FullDocument mongoEvent `bson:"fullDocument"`
+			}
+			if err := stream.Decode(&change); err != nil {
+				log.Printf("Error decoding event from change stream: %v", err)
+				continue
+			}
+
+			doc := change.FullDocument
+			handler(Event{
+				ID:     doc.ID,
+				Action: doc.Action,
+				Topics: doc.Topics,
+				Data:   []byte(doc.Data),
+			})
+			resumeToken = stream.ResumeToken()
+		}
+		if token := stream.ResumeToken(); token != nil {
+			resumeToken = token
+		}
+
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Event change stream error: %v", err)
+		}
+		stream.Close(context.Background())
+
+		// Reopen the stream where it left off until the subscription is cancelled
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(mongoEventRetryDelay):
+			}
+
+			var err error
+			stream, err = b.watch(ctx, resumeToken)
+			if err == nil {
+				break
+			}
+			log.Printf("Error reopening event change stream: %v", err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package realtimemanager
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How often each instance tells the others it is still running, and how long they wait
+// without hearing from it before dropping the connections it reported
+const (
+	presenceHeartbeatInterval = 10 * time.Second
+	presenceHeartbeatExpiry   = 3 * presenceHeartbeatInterval
+)
+
+// A connection subscribed to a topic
+type PresenceMember struct {
+	ConnectionID string    `json:"connection_id"`
+	UserID       string    `json:"user_id,omitempty"`
+	Username     string    `json:"username,omitempty"`
+	JoinedAt     time.Time `json:"joined_at"`
+	instanceID   string
+}
+
+// Who is currently watching a topic. Count includes anonymous spectators,
+// Users only lists authenticated users, once each.
+type Presence struct {
+	Topic string           `json:"topic"`
+	Count int              `json:"count"`
+	Users []PresenceMember `json:"users"`
+}
+
+// Payload of presence_joined, presence_left and presence_heartbeat events
+type presenceMessage struct {
+	Action       string    `json:"action"`
+	InstanceID   string    `json:"instance_id"`
+	Topic        string    `json:"topic,omitempty"`
+	ConnectionID string    `json:"connection_id,omitempty"`
+	UserID       string    `json:"user_id,omitempty"`
+	Username     string    `json:"username,omitempty"`
+	Count        int       `json:"count"`
+	At           time.Time `json:"at"`
+}
+
+// Connections per topic, built from the presence events on the bus, and when each
+// instance was last heard from. Only used while holding the hub's lock.
+type presenceRegistry struct {
+	topics    map[string]map[string]PresenceMember
+	instances map[string]time.Time
+}
+
+func newPresenceRegistry() *presenceRegistry {
+	return &presenceRegistry{
+		topics:    make(map[string]map[string]PresenceMember),
+		instances: make(map[string]time.Time),
+	}
+}
+
+// Applies a join, leave or heartbeat received at now, and returns the event with the
+// topic's new count filled in. Who joined or left is taken out, only staff can see that.
+func (r *presenceRegistry) apply(event Event, now time.Time) Event {
+	var message presenceMessage
+	if err := json.Unmarshal(event.Data, &message); err != nil {
+		log.Printf("Error reading presence event %s: %v", event.ID, err)
+		return event
+	}
+
+	if message.Action != "presence_left" {
+		r.instances[message.InstanceID] = now
+	}
+
+	members := r.topics[message.Topic]
+	switch message.Action {
+	case "presence_heartbeat":
+		return event
+	case "presence_joined":
+		if members == nil {
+			members = make(map[string]PresenceMember)
+			r.topics[message.Topic] = members
+		}
+		members[message.ConnectionID] = PresenceMember{
+			ConnectionID: message.ConnectionID,
+			UserID:       message.UserID,
+			Username:     message.Username,
+			JoinedAt:     message.At,
+			instanceID:   message.InstanceID,
+		}
+	case "presence_left":
+		delete(members, message.ConnectionID)
+		if len(members) == 0 {
+			delete(r.topics, message.Topic)
+		}
+	}
+
+	message.Count = len(r.topics[message.Topic])
+	message.UserID, message.Username = "", ""
+	if data, err := json.Marshal(message); err == nil {
+		event.Data = data
+	}
+
+	return event
+}
+
+// Forgets the instances not heard from since the cutoff, returning a leave for each
+// connection they reported so the caller can apply and deliver it
+func (r *presenceRegistry) lapsed(cutoff time.Time) []presenceMessage {
+	var left []presenceMessage
+	for instanceID, lastSeen := range r.instances {
+		if !lastSeen.Before(cutoff) {
+			continue
+		}
+		delete(r.instances, instanceID)
+
+		for topic, members := range r.topics {
+			for _, member := range members {
+				if member.instanceID != instanceID {
+					continue
+				}
+				left = append(left, presenceMessage{
+					Action:       "presence_left",
+					InstanceID:   instanceID,
+					Topic:        topic,
+					ConnectionID: member.ConnectionID,
+					UserID:       member.UserID,
+					Username:     member.Username,
+					At:           cutoff,
+				})
+			}
+		}
+	}
+	return left
+}
+
+func (r *presenceRegistry) get(topic string) Presence {
+	presence := Presence{Topic: topic, Users: []PresenceMember{}}
+
+	seen := make(map[string]struct{})
+	for _, member := range r.topics[topic] {
+		presence.Count++
+		if member.UserID == "" {
+			continue
+		}
+		if _, ok := seen[member.UserID]; ok {
+			continue
+		}
+		seen[member.UserID] = struct{}{}
+		presence.Users = append(presence.Users, member)
+	}
+
+	sort.Slice(presence.Users, func(i, j int) bool {
+		return presence.Users[i].JoinedAt.Before(presence.Users[j].JoinedAt)
+	})
+
+	return presence
+}
+
+// Presence returns who is currently subscribed to a topic across all instances
+func (wh *WebSocketHub) Presence(topic string) Presence {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	return wh.presence.get(topic)
+}
+
+// Publishes a join or leave for each topic so every instance can update its presence
+func (wh *WebSocketHub) publishPresence(action string, sub *Subscription, topics []string) {
+	for _, topic := range topics {
+		data, err := json.Marshal(presenceMessage{
+			Action:       action,
+			InstanceID:   wh.instanceID,
+			Topic:        topic,
+			ConnectionID: sub.ID,
+			UserID:       sub.Identity.UserID,
+			Username:     sub.Identity.Username,
+			At:           time.Now().UTC(),
+		})
+		if err != nil {
+			log.Printf("Error marshaling presence event: %v", err)
+			continue
+		}
+
+		wh.Publish(Event{Action: action, Topics: []string{topic}, Data: data})
+	}
+}
+
+// Tells the other instances this one is running until the hub is closed, and drops the
+// connections of any instance that has stopped, such as one that crashed without
+// publishing its leaves
+func (wh *WebSocketHub) heartbeat() {
+	ticker := time.NewTicker(presenceHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wh.stop:
+			return
+		case <-ticker.C:
+			wh.publishHeartbeat()
+			wh.expirePresence(time.Now())
+		}
+	}
+}
+
+func (wh *WebSocketHub) publishHeartbeat() {
+	data, err := json.Marshal(presenceMessage{Action: "presence_heartbeat", InstanceID: wh.instanceID, At: time.Now().UTC()})
+	if err != nil {
+		log.Printf("Error marshaling presence heartbeat: %v", err)
+		return
+	}
+	wh.Publish(Event{Action: "presence_heartbeat", Data: data})
+}
+
+// Delivers a leave, on this instance only, for each connection of the instances whose
+// heartbeat has lapsed. Every instance sees the same heartbeats so reaches the same result.
+func (wh *WebSocketHub) expirePresence(now time.Time) {
+	wh.mu.Lock()
+	left := wh.presence.lapsed(now.Add(-presenceHeartbeatExpiry))
+	wh.mu.Unlock()
+
+	for _, message := range left {
+		data, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Error marshaling presence event: %v", err)
+			continue
+		}
+		wh.deliver(Event{ID: primitive.NewObjectID().Hex(), Action: message.Action, Topics: []string{message.Topic}, Data: data})
+	}
+}
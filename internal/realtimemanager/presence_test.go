@@ -0,0 +1,61 @@
+package realtimemanager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresenceDropsInstancesWhoseHeartbeatLapses(t *testing.T) {
+	bus := NewInProcessEventBus()
+
+	// Two instances sharing a bus, like two servers with EVENT_BUS=mongo
+	running, err := NewWebSocketHubWithBus(bus)
+	if err != nil {
+		t.Fatalf("creating hub: %v", err)
+	}
+	defer running.Close()
+	crashed, err := NewWebSocketHubWithBus(bus)
+	if err != nil {
+		t.Fatalf("creating hub: %v", err)
+	}
+
+	topic := Topic("match", "1")
+	watcher := running.SubscribeAs(Identity{UserID: "watcher"}, topic)
+	defer running.Unsubscribe(watcher)
+	crashed.SubscribeAs(Identity{UserID: "spectator"}, topic)
+
+	if got := running.Presence(topic).Count; got != 2 {
+		t.Fatalf("got %d connections, want 2", got)
+	}
+
+	// The instance stops without publishing its leaves, while the other keeps beating
+	crashed.Close()
+	running.publishHeartbeat()
+
+	running.expirePresence(time.Now())
+	if got := running.Presence(topic).Count; got != 2 {
+		t.Fatalf("got %d connections before the heartbeat expired, want 2", got)
+	}
+
+	// Until nothing has been heard from it for longer than the expiry
+	running.mu.Lock()
+	running.presence.instances[crashed.instanceID] = time.Now().Add(-presenceHeartbeatExpiry - time.Second)
+	running.mu.Unlock()
+
+	running.expirePresence(time.Now())
+	presence := running.Presence(topic)
+	if presence.Count != 1 || len(presence.Users) != 1 || presence.Users[0].UserID != "watcher" {
+		t.Fatalf("got presence %+v, want only the running instance's connection", presence)
+	}
+
+	// Subscribers hear that the crashed instance's connection left
+	left := false
+	for len(watcher.Events()) > 0 {
+		if event := <-watcher.Events(); event.Action == "presence_left" {
+			left = true
+		}
+	}
+	if !left {
+		t.Fatalf("no presence_left event for the dropped connection")
+	}
+}
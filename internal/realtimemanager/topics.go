@@ -0,0 +1,156 @@
+package realtimemanager
+
+import (
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Size of each subscription's event buffer
+const subscriptionBufferSize = 64
+
+// Topics that are only delivered to subscriptions that were allowed to join them
+var privateTopicPrefixes = []string{"chat:"}
+
+// A single message published through the hub
+type Event struct {
+	ID     string
+	Action string
+	Topics []string
+	Data   []byte
+}
+
+// The user behind a subscription, empty for anonymous spectators
+type Identity struct {
+	UserID   string
+	Username string
+}
+
+// Subscription receives the hub events for the topics it is subscribed to
+type Subscription struct {
+	ID       string
+	Identity Identity
+	events   chan Event
+	topics   map[string]struct{}
+	// Set before events is closed when the subscriber fell too far behind
+	dropped bool
+	mu      sync.RWMutex
+}
+
+func newSubscription(topics []string) *Subscription {
+	sub := &Subscription{
+		ID:     primitive.NewObjectID().Hex(),
+		events: make(chan Event, subscriptionBufferSize),
+		topics: make(map[string]struct{}),
+	}
+	sub.Add(topics...)
+	return sub
+}
+
+// Events returns the channel events are delivered on. It is closed on unsubscribe, and
+// when the subscriber falls too far behind to keep up.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Add subscribes to more topics
+func (s *Subscription) Add(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, topic := range topics {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			s.topics[topic] = struct{}{}
+		}
+	}
+}
+
+// Remove unsubscribes from topics
+func (s *Subscription) Remove(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, topic := range topics {
+		delete(s.topics, strings.TrimSpace(topic))
+	}
+}
+
+// Has reports whether the subscription has explicitly subscribed to the topic
+func (s *Subscription) Has(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.topics[topic]
+	return ok
+}
+
+// Topics returns the topics the subscription is currently interested in
+func (s *Subscription) Topics() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	topics := make([]string, 0, len(s.topics))
+	for topic := range s.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// Matches reports whether the event should be delivered to this subscription.
+// Subscriptions without topics, and events without topics, always match unless
+// the event is for a private topic.
+func (s *Subscription) Matches(event Event) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(event.Topics) == 0 {
+		return true
+	}
+	if len(s.topics) == 0 && !isPrivateEvent(event) {
+		return true
+	}
+	for _, topic := range event.Topics {
+		if _, ok := s.topics[topic]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivateTopic reports whether a topic needs permission to subscribe to
+func IsPrivateTopic(topic string) bool {
+	for _, prefix := range privateTopicPrefixes {
+		if strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPrivateEvent(event Event) bool {
+	for _, topic := range event.Topics {
+		if IsPrivateTopic(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Builds a topic name such as "tournament:<id>"
+func Topic(kind, id string) string {
+	return kind + ":" + id
+}
+
+// Works out the topics a broadcast message belongs to from its id fields
+func TopicsForMessage(message map[string]interface{}) []string {
+	var topics []string
+	for field, kind := range map[string]string{
+		"tournament_id":   "tournament",
+		"match_id":        "match",
+		"team_id":         "team",
+		"match_result_id": "match_result",
+	} {
+		id, ok := message[field].(string)
+		if !ok || id == "" || id == "000000000000000000000000" {
+			continue
+		}
+		topics = append(topics, Topic(kind, id))
+	}
+	return topics
+}
@@ -1,15 +1,34 @@
 package realtimemanager
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"sync"
+	"time"
+
 	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// Manages WebSocket clients
+// Number of recent events kept so streaming clients can resume after a reconnect
+const eventHistorySize = 256
+
+// Sent to a WebSocket client before it is disconnected for falling behind
+var resyncMessage = []byte(`{"action":"resync"}`)
+
+// Manages WebSocket clients and any other subscribers to the event fan-out
 type WebSocketHub struct {
-	clients map[*websocket.Conn]struct{}
-	mu sync.Mutex
+	clients       map[*websocket.Conn]*Subscription
+	subscriptions map[*Subscription]struct{}
+	history       []Event
+	presence      *presenceRegistry
+	instanceID    string
+	bus           EventBus
+	unsubscribe   func()
+	stop          chan struct{}
+	closeOnce     sync.Once
+	mu            sync.Mutex
 }
 
 var upgrader = websocket.Upgrader{
@@ -22,36 +41,281 @@ func GetUpgrader() *websocket.Upgrader {
 	return &upgrader
 }
 
+// Creates a hub that only fans out events published on this instance
 func NewWebSocketHub() *WebSocketHub {
-	return &WebSocketHub{
-		clients: make(map[*websocket.Conn]struct{}),
+	hub, _ := NewWebSocketHubWithBus(NewInProcessEventBus())
+	return hub
+}
+
+// Creates a hub fed from the given event bus, so every instance sharing the bus
+// delivers every published event to its own clients
+func NewWebSocketHubWithBus(bus EventBus) (*WebSocketHub, error) {
+	hub := &WebSocketHub{
+		clients:       make(map[*websocket.Conn]*Subscription),
+		subscriptions: make(map[*Subscription]struct{}),
+		presence:      newPresenceRegistry(),
+		instanceID:    primitive.NewObjectID().Hex(),
+		bus:           bus,
+		stop:          make(chan struct{}),
+	}
+
+	unsubscribe, err := bus.Subscribe(hub.deliver)
+	if err != nil {
+		return nil, err
 	}
+	hub.unsubscribe = unsubscribe
+
+	go hub.heartbeat()
+
+	return hub, nil
+}
+
+// Close stops the hub consuming events from its bus and sending presence heartbeats
+func (wh *WebSocketHub) Close() {
+	wh.closeOnce.Do(func() {
+		close(wh.stop)
+		if wh.unsubscribe != nil {
+			wh.unsubscribe()
+		}
+	})
 }
 
-// Add client
-func (wh *WebSocketHub) AddClient(client *websocket.Conn) {
+// Subscribe registers a new anonymous subscription for the given topics.
+// A subscription without topics receives every event.
+func (wh *WebSocketHub) Subscribe(topics ...string) *Subscription {
+	return wh.SubscribeAs(Identity{}, topics...)
+}
+
+// SubscribeAs registers a subscription for a known user, so they show up in topic presence
+func (wh *WebSocketHub) SubscribeAs(identity Identity, topics ...string) *Subscription {
+	sub := newSubscription(topics)
+	sub.Identity = identity
+
+	wh.mu.Lock()
+	wh.subscriptions[sub] = struct{}{}
+	wh.mu.Unlock()
+
+	wh.publishPresence("presence_joined", sub, sub.Topics())
+
+	return sub
+}
+
+// AddTopics subscribes an existing subscription to more topics
+func (wh *WebSocketHub) AddTopics(sub *Subscription, topics ...string) {
+	var added []string
+	for _, topic := range topics {
+		if !sub.Has(topic) {
+			added = append(added, topic)
+		}
+	}
+
+	sub.Add(added...)
+	wh.publishPresence("presence_joined", sub, added)
+}
+
+// RemoveTopics unsubscribes an existing subscription from topics
+func (wh *WebSocketHub) RemoveTopics(sub *Subscription, topics ...string) {
+	var removed []string
+	for _, topic := range topics {
+		if sub.Has(topic) {
+			removed = append(removed, topic)
+		}
+	}
+
+	sub.Remove(removed...)
+	wh.publishPresence("presence_left", sub, removed)
+}
+
+// Unsubscribe removes a subscription and closes its event channel
+func (wh *WebSocketHub) Unsubscribe(sub *Subscription) {
+	wh.mu.Lock()
+	_, ok := wh.subscriptions[sub]
+	if ok {
+		delete(wh.subscriptions, sub)
+		close(sub.events)
+	}
+	wh.mu.Unlock()
+
+	if ok {
+		wh.publishPresence("presence_left", sub, sub.Topics())
+	}
+}
+
+// SendTo delivers an event to a single subscription, such as a reply to one client
+func (wh *WebSocketHub) SendTo(sub *Subscription, event Event) {
 	wh.mu.Lock()
 	defer wh.mu.Unlock()
-	wh.clients[client] = struct{}{}
+	if _, ok := wh.subscriptions[sub]; !ok {
+		return
+	}
+	select {
+	case sub.events <- event:
+	default:
+		log.Printf("Dropping direct event for slow subscriber")
+	}
+}
+
+// SubscriberCount returns how many subscriptions have explicitly subscribed to a topic
+func (wh *WebSocketHub) SubscriberCount(topic string) int {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+
+	count := 0
+	for sub := range wh.subscriptions {
+		if sub.Has(topic) {
+			count++
+		}
+	}
+	return count
+}
+
+// Add client and start forwarding hub events to it
+func (wh *WebSocketHub) AddClient(client *websocket.Conn, identity Identity) *Subscription {
+	sub := wh.SubscribeAs(identity)
+
+	wh.mu.Lock()
+	wh.clients[client] = sub
+	wh.mu.Unlock()
+
+	go func() {
+		for event := range sub.Events() {
+			if err := client.WriteMessage(websocket.TextMessage, event.Data); err != nil {
+				log.Printf("Error sending WebSocket message: %v", err)
+				client.Close()
+				wh.RemoveClient(client)
+				return
+			}
+		}
+
+		// A client that fell behind is told to refetch its state, then disconnected
+		if sub.dropped {
+			client.WriteMessage(websocket.TextMessage, resyncMessage)
+			client.Close()
+			wh.RemoveClient(client)
+		}
+	}()
+
+	return sub
 }
 
 // Remove client
 func (wh *WebSocketHub) RemoveClient(client *websocket.Conn) {
 	wh.mu.Lock()
-	defer wh.mu.Unlock()
+	sub, ok := wh.clients[client]
 	delete(wh.clients, client)
+	wh.mu.Unlock()
+
+	if ok {
+		wh.Unsubscribe(sub)
+	}
 }
 
-// Broadcast a message
+// Broadcast a message to every subscriber, using the topics found in the message
 func (wh *WebSocketHub) Broadcast(message []byte) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(message, &fields); err != nil {
+		wh.Publish(Event{Data: message})
+		return
+	}
+
+	action, _ := fields["action"].(string)
+	wh.Publish(Event{Action: action, Topics: TopicsForMessage(fields), Data: message})
+}
+
+// Publish assigns the event an ID if it has none and sends it on the event bus
+func (wh *WebSocketHub) Publish(event Event) Event {
+	if event.ID == "" {
+		event.ID = primitive.NewObjectID().Hex()
+	}
+
+	if err := wh.bus.Publish(context.Background(), event); err != nil {
+		log.Printf("Error publishing event %s: %v", event.ID, err)
+	}
+
+	return event
+}
+
+// Records an event received from the bus in the history and delivers it to
+// every subscription interested in its topics
+func (wh *WebSocketHub) deliver(event Event) {
+	wh.mu.Lock()
+	dropped := wh.deliverLocked(event)
+	wh.mu.Unlock()
+
+	for _, sub := range dropped {
+		wh.publishPresence("presence_left", sub, sub.Topics())
+	}
+}
+
+// Delivers an event while holding the hub's lock, returning the subscriptions dropped
+// for being too slow to take it
+func (wh *WebSocketHub) deliverLocked(event Event) []*Subscription {
+	// The bus may redeliver an event, clients should only see it once
+	for _, seen := range wh.history {
+		if seen.ID == event.ID {
+			return nil
+		}
+	}
+
+	// Presence is tracked from the bus so every instance sees every connection. Heartbeats
+	// only keep an instance's connections alive, they aren't for clients.
+	switch event.Action {
+	case "presence_heartbeat":
+		wh.presence.apply(event, time.Now())
+		return nil
+	case "presence_joined", "presence_left":
+		event = wh.presence.apply(event, time.Now())
+	}
+
+	wh.history = append(wh.history, event)
+	if len(wh.history) > eventHistorySize {
+		wh.history = wh.history[len(wh.history)-eventHistorySize:]
+	}
+
+	var dropped []*Subscription
+	for sub := range wh.subscriptions {
+		if !sub.Matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// A subscriber with a full buffer is dropped rather than blocking every other
+			// client or silently missing events. Closing its channel ends its stream, and
+			// the client reconnects and resumes from the history or resyncs.
+			log.Printf("Dropping slow subscriber %s at event %s", sub.ID, event.ID)
+			delete(wh.subscriptions, sub)
+			sub.dropped = true
+			close(sub.events)
+			dropped = append(dropped, sub)
+		}
+	}
+	return dropped
+}
+
+// EventsSince returns the buffered events published after the event with the given ID
+// that match the topics. ok is false when the ID is no longer in the history.
+func (wh *WebSocketHub) EventsSince(lastEventID string, topics ...string) (events []Event, ok bool) {
 	wh.mu.Lock()
 	defer wh.mu.Unlock()
-	for client := range wh.clients {
-		err := client.WriteMessage(websocket.TextMessage, message)
-		if err != nil {
-			log.Printf("Error sending WebSocket message: %v", err)
-			client.Close()
-			delete(wh.clients, client)
+
+	filter := newSubscription(topics)
+	start := -1
+	for i, event := range wh.history {
+		if event.ID == lastEventID {
+			start = i + 1
+			break
 		}
 	}
-}
\ No newline at end of file
+	if start == -1 {
+		return nil, false
+	}
+
+	for _, event := range wh.history[start:] {
+		if filter.Matches(event) {
+			events = append(events, event)
+		}
+	}
+
+	return events, true
+}
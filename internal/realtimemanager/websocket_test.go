@@ -0,0 +1,104 @@
+package realtimemanager
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSlowSubscribersAreDropped(t *testing.T) {
+	hub := NewWebSocketHub()
+	defer hub.Close()
+
+	topic := Topic("match", "1")
+	slow := hub.Subscribe(topic)
+	watcher := hub.Subscribe(topic)
+	defer hub.Unsubscribe(watcher)
+
+	// The watcher keeps up, the slow subscriber never reads
+	var watched []Event
+	for i := 0; i <= subscriptionBufferSize; i++ {
+		hub.Publish(Event{Action: "match_updated", Topics: []string{topic}, Data: []byte(strconv.Itoa(i))})
+		for len(watcher.Events()) > 0 {
+			watched = append(watched, <-watcher.Events())
+		}
+	}
+
+	// It gets what fitted in its buffer, then its channel is closed so it knows to resume
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if !slow.dropped {
+		t.Fatalf("slow subscriber's channel closed without it being dropped")
+	}
+	if received == 0 || received > subscriptionBufferSize {
+		t.Fatalf("slow subscriber received %d events before being dropped", received)
+	}
+	if got := hub.SubscriberCount(topic); got != 1 {
+		t.Fatalf("got %d subscribers, want only the watcher", got)
+	}
+	if got := hub.Presence(topic).Count; got != 1 {
+		t.Fatalf("got %d connections in presence, want only the watcher", got)
+	}
+
+	// Unsubscribing it afterwards is harmless
+	hub.Unsubscribe(slow)
+
+	left := false
+	for _, event := range watched {
+		if event.Action == "presence_left" {
+			left = true
+		}
+	}
+	if !left {
+		t.Fatalf("watcher wasn't told the slow subscriber left")
+	}
+}
+
+func TestDroppingSlowSubscribersWhileOthersSubscribe(t *testing.T) {
+	bus := NewInProcessEventBus()
+	hub, err := NewWebSocketHubWithBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another hub keeps joining and leaving the bus, which takes its lock to write
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if unsubscribe, err := bus.Subscribe(func(Event) {}); err == nil {
+				unsubscribe()
+			}
+		}
+	}()
+
+	// Each slow subscriber is dropped from inside the bus's handler, which publishes that it left
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		topic := Topic("match", "1")
+		for round := 0; round < 200; round++ {
+			for i := 0; i < 5; i++ {
+				hub.Subscribe(topic)
+			}
+			for i := 0; i <= subscriptionBufferSize; i++ {
+				hub.Publish(Event{Action: "match_updated", Topics: []string{topic}})
+			}
+		}
+	}()
+
+	// A deadlocked hub can't be closed either, so it is only closed once publishing finishes
+	select {
+	case <-done:
+		hub.Close()
+	case <-time.After(10 * time.Second):
+		t.Fatalf("publishing deadlocked while dropping slow subscribers")
+	}
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup match lobby chat routes
+func SetupChatRoutes(r *gin.Engine, chatHandler *handlers.ChatHandler, twoFactorPolicy gin.HandlerFunc) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		log.Fatalf("SECRET_KEY environment variable is not set")
+	}
+
+	chatRoutes := r.Group("/matches/:id/chat")
+	{
+		chatRoutes.Use(auth.AuthMiddleware(jwtSecret))
+
+		chatRoutes.GET("/messages", chatHandler.GetChatMessages)
+		chatRoutes.POST("/messages", twoFactorPolicy, chatHandler.CreateChatMessage)
+		chatRoutes.DELETE("/messages/:messageId", twoFactorPolicy, chatHandler.DeleteChatMessage)
+		chatRoutes.POST("/mutes", twoFactorPolicy, chatHandler.MuteChatUser)
+		chatRoutes.DELETE("/mutes/:userId", twoFactorPolicy, chatHandler.UnmuteChatUser)
+	}
+}
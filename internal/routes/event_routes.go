@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup event stream routes
+func SetupEventRoutes(r *gin.Engine, eventStreamHandler *handlers.EventStreamHandler) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		log.Fatalf("SECRET_KEY environment variable is not set")
+	}
+
+	// Signed in users are identified in presence, everyone else streams anonymously
+	r.GET("/events", auth.OptionalAuthMiddleware(jwtSecret), eventStreamHandler.StreamEvents)
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup live scoring routes
+func SetupLiveScoreRoutes(r *gin.Engine, liveScoreHandler *handlers.LiveScoreHandler, twoFactorPolicy gin.HandlerFunc) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		log.Fatalf("SECRET_KEY environment variable is not set")
+	}
+
+	liveScoreRoutes := r.Group("/matches/:id")
+	{
+		// Anyone can follow a published tournament's matches, its staff can before then too
+		liveScoreRoutes.GET("/live", auth.OptionalAuthMiddleware(jwtSecret), liveScoreHandler.GetLiveScore)
+		liveScoreRoutes.GET("/maps", auth.OptionalAuthMiddleware(jwtSecret), liveScoreHandler.GetMapResults)
+
+		liveScoreRoutes.Use(auth.AuthMiddleware(jwtSecret, auth.ScopeResultsWrite, auth.TournamentManageScope("*")), twoFactorPolicy)
+
+		liveScoreRoutes.POST("/live", liveScoreHandler.UpdateLiveScore)
+	}
+}
@@ -10,7 +10,7 @@ import (
 )
 
 // Setup user routes
-func SetupMatchResultRoutes(r *gin.Engine, matchResultHandler *handlers.MatchResultHandler) {
+func SetupMatchResultRoutes(r *gin.Engine, matchResultHandler *handlers.MatchResultHandler, twoFactorPolicy gin.HandlerFunc) {
 	jwtSecret := os.Getenv("SECRET_KEY")
 	if jwtSecret == "" {
 		log.Fatalf("SECRET_KEY environment variable is not set")
@@ -18,13 +18,13 @@ func SetupMatchResultRoutes(r *gin.Engine, matchResultHandler *handlers.MatchRes
 
 	matchResultRoutes := r.Group("/match-results")
 	{
-		matchResultRoutes.GET("/", matchResultHandler.GetMatchResultsByOrganiserID)
-		matchResultRoutes.GET("/:id", matchResultHandler.GetMatchResultById)
-
-		matchResultRoutes.Use(auth.AuthMiddleware(jwtSecret))
+		matchResultRoutes.Use(auth.AuthMiddleware(jwtSecret, auth.ScopeResultsWrite, auth.TournamentManageScope("*")), twoFactorPolicy)
 
+		matchResultRoutes.GET("/:id", matchResultHandler.GetMatchResultById)
 		matchResultRoutes.POST("/", matchResultHandler.CreateMatchResult)
 		matchResultRoutes.PUT("/:id", matchResultHandler.UpdateMatchResult)
+		matchResultRoutes.PATCH("/:id", matchResultHandler.PatchMatchResult)
 		matchResultRoutes.DELETE("/:id", matchResultHandler.DeleteMatchResult)
+		matchResultRoutes.POST("/:id/restore", matchResultHandler.RestoreMatchResult)
 	}
 }
\ No newline at end of file
@@ -10,7 +10,7 @@ import (
 )
 
 // Setup tournament routes
-func SetupMatchRoutes(r *gin.Engine, matchHandler *handlers.MatchHandler) {
+func SetupMatchRoutes(r *gin.Engine, matchHandler *handlers.MatchHandler, twoFactorPolicy gin.HandlerFunc) {
 	jwtSecret := os.Getenv("SECRET_KEY")
 	if jwtSecret == "" {
 		log.Fatalf("SECRET_KEY environment variable is not set")
@@ -18,13 +18,14 @@ func SetupMatchRoutes(r *gin.Engine, matchHandler *handlers.MatchHandler) {
 
 	matchRoutes := r.Group("/matches")
 	{
-		matchRoutes.GET("/", matchHandler.GetMatchesByOrganiserID)
-		matchRoutes.GET("/:id", matchHandler.GetMatchByID)
-
-		matchRoutes.Use(auth.AuthMiddleware(jwtSecret))
+		// API keys need the manage scope for the match's tournament, which the match service checks
+		matchRoutes.Use(auth.AuthMiddleware(jwtSecret, auth.TournamentManageScope("*")), twoFactorPolicy)
 		
+		matchRoutes.GET("/:id", matchHandler.GetMatchByID)
 		matchRoutes.POST("/", matchHandler.CreateMatch)
 		matchRoutes.PUT("/:id", matchHandler.UpdateMatch)
+		matchRoutes.PATCH("/:id", matchHandler.PatchMatch)
 		matchRoutes.DELETE("/:id", matchHandler.DeleteMatch)
+		matchRoutes.POST("/:id/restore", matchHandler.RestoreMatch)
 	}
 }
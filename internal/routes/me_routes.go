@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup the signed in user's own listings
+func SetupMeRoutes(r *gin.Engine, tournamentHandler *handlers.TournamentHandler, teamHandler *handlers.TeamHandler, matchHandler *handlers.MatchHandler, matchResultHandler *handlers.MatchResultHandler) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		log.Fatalf("SECRET_KEY environment variable is not set")
+	}
+
+	meRoutes := r.Group("/me")
+	{
+		meRoutes.Use(auth.AuthMiddleware(jwtSecret))
+
+		meRoutes.GET("/tournaments", tournamentHandler.GetTournamentsForUser)
+		meRoutes.GET("/teams", teamHandler.GetTeamsForUser)
+		meRoutes.GET("/matches", matchHandler.GetMatchesForUser)
+		meRoutes.GET("/match-results", matchResultHandler.GetMatchResultsForUser)
+	}
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup external login provider routes
+func SetupOIDCRoutes(r *gin.Engine, oidcHandler *handlers.OIDCHandler) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		log.Fatalf("SECRET_KEY environment variable is not set")
+	}
+
+	oidcRoutes := r.Group("/auth/oidc")
+	{
+		oidcRoutes.GET("/providers", oidcHandler.GetProviders)
+		oidcRoutes.GET("/:provider/login", oidcHandler.Login)
+		// Signed in users are identified so linking can check it is still them
+		oidcRoutes.GET("/:provider/callback", auth.OptionalAuthMiddleware(jwtSecret), oidcHandler.Callback)
+
+		oidcRoutes.Use(auth.AuthMiddleware(jwtSecret))
+
+		oidcRoutes.GET("/:provider/link", oidcHandler.Link)
+		oidcRoutes.DELETE("/:provider", oidcHandler.Unlink)
+	}
+}
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup organisation routes
+func SetupOrganisationRoutes(r *gin.Engine, organisationHandler *handlers.OrganisationHandler, twoFactorPolicy gin.HandlerFunc) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		log.Fatalf("SECRET_KEY environment variable is not set")
+	}
+
+	organisationRoutes := r.Group("/organisations")
+	{
+		organisationRoutes.Use(auth.AuthMiddleware(jwtSecret))
+
+		organisationRoutes.GET("/", organisationHandler.GetOrganisations)
+		organisationRoutes.POST("/", twoFactorPolicy, organisationHandler.CreateOrganisation)
+		organisationRoutes.GET("/:id", organisationHandler.GetOrganisationByID)
+		organisationRoutes.POST("/:id/members", twoFactorPolicy, organisationHandler.AddMember)
+		organisationRoutes.PUT("/:id/members/:userId", twoFactorPolicy, organisationHandler.UpdateMember)
+		organisationRoutes.DELETE("/:id/members/:userId", twoFactorPolicy, organisationHandler.RemoveMember)
+	}
+}
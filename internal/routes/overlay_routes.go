@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup broadcast overlay routes
+func SetupOverlayRoutes(r *gin.Engine, overlayHandler *handlers.OverlayHandler) {
+	r.GET("/matches/:id/overlay", overlayHandler.GetOverlay)
+}
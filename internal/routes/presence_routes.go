@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup presence routes
+func SetupPresenceRoutes(r *gin.Engine, presenceHandler *handlers.PresenceHandler) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		log.Fatalf("SECRET_KEY environment variable is not set")
+	}
+
+	// Anyone who can see a match or tournament can see how many are watching it
+	r.GET("/matches/:id/presence", auth.OptionalAuthMiddleware(jwtSecret), presenceHandler.GetMatchPresence)
+	r.GET("/tournaments/:id/presence", auth.OptionalAuthMiddleware(jwtSecret), presenceHandler.GetTournamentPresence)
+
+	// Only its staff can see who they are
+	r.GET("/matches/:id/presence/users", auth.AuthMiddleware(jwtSecret), presenceHandler.GetMatchViewers)
+	r.GET("/tournaments/:id/presence/users", auth.AuthMiddleware(jwtSecret), presenceHandler.GetTournamentViewers)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup public spectator routes
+func SetupPublicRoutes(r *gin.Engine, publicHandler *handlers.PublicHandler) {
+	publicRoutes := r.Group("/public")
+	{
+		publicRoutes.GET("/tournaments", publicHandler.GetTournaments)
+		publicRoutes.GET("/tournaments/:id", publicHandler.GetTournament)
+		publicRoutes.GET("/tournaments/:id/bracket", publicHandler.GetBracket)
+		publicRoutes.GET("/tournaments/:id/standings", publicHandler.GetStandings)
+		publicRoutes.GET("/tournaments/:id/schedule", publicHandler.GetSchedule)
+		publicRoutes.GET("/matches/:id", publicHandler.GetMatch)
+	}
+}
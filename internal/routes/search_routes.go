@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/handlers"
+)
+
+// Setup search routes
+func SetupSearchRoutes(r *gin.Engine, searchHandler *handlers.SearchHandler) {
+	jwtSecret := os.Getenv("SECRET_KEY")
+	if jwtSecret == "" {
+		log.Fatalf("SECRET_KEY environment variable is not set")
+	}
+
+	searchRoutes := r.Group("/search")
+	{
+		searchRoutes.Use(auth.AuthMiddleware(jwtSecret))
+
+		searchRoutes.GET("", searchHandler.SearchAll)
+	}
+}
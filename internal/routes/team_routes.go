@@ -10,7 +10,7 @@ import (
 )
 
 // Setup user routes
-func SetupTeamRoutes(r *gin.Engine, teamHandler *handlers.TeamHandler) {
+func SetupTeamRoutes(r *gin.Engine, teamHandler *handlers.TeamHandler, twoFactorPolicy gin.HandlerFunc) {
 	jwtSecret := os.Getenv("SECRET_KEY")
 	if jwtSecret == "" {
 		log.Fatalf("SECRET_KEY environment variable is not set")
@@ -18,13 +18,13 @@ func SetupTeamRoutes(r *gin.Engine, teamHandler *handlers.TeamHandler) {
 
 	teamRoutes := r.Group("/teams")
 	{
-		teamRoutes.GET("/", teamHandler.GetTeamsByOrganiserID)
-		teamRoutes.GET("/:id", teamHandler.GetTeamByID)
-
-		teamRoutes.Use(auth.AuthMiddleware(jwtSecret))
+		teamRoutes.Use(auth.AuthMiddleware(jwtSecret), twoFactorPolicy)
 
+		teamRoutes.GET("/:id", teamHandler.GetTeamByID)
 		teamRoutes.POST("/", teamHandler.CreateTeam)
 		teamRoutes.PUT("/:id", teamHandler.UpdateTeam)
+		teamRoutes.PATCH("/:id", teamHandler.PatchTeam)
 		teamRoutes.DELETE("/:id", teamHandler.DeleteTeam)
+		teamRoutes.POST("/:id/restore", teamHandler.RestoreTeam)
 	}
 }
\ No newline at end of file
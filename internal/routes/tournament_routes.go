@@ -10,21 +10,23 @@ import (
 )
 
 // Setup tournament routes
-func SetupTournamentRoutes(r *gin.Engine, tournamentHandler *handlers.TournamentHandler) {
+func SetupTournamentRoutes(r *gin.Engine, tournamentHandler *handlers.TournamentHandler, twoFactorPolicy gin.HandlerFunc) {
 	jwtSecret := os.Getenv("SECRET_KEY")
 	if jwtSecret == "" {
 		log.Fatalf("SECRET_KEY environment variable is not set")
 	}
 
+	// API keys can manage the tournament they were given, but can't create tournaments
+	manage := auth.AuthMiddleware(jwtSecret, auth.TournamentManageScope("{id}"))
+	loginOnly := auth.AuthMiddleware(jwtSecret)
+
 	tournamentRoutes := r.Group("/tournaments")
 	{
-		tournamentRoutes.GET("/", tournamentHandler.GetTournamentsByOrganiserID)
-		tournamentRoutes.GET("/:id", tournamentHandler.GetTournamentByID)
-
-		tournamentRoutes.Use(auth.AuthMiddleware(jwtSecret))
-		
-		tournamentRoutes.POST("/", tournamentHandler.CreateTournament)
-		tournamentRoutes.PUT("/:id", tournamentHandler.UpdateTournament)
-		tournamentRoutes.DELETE("/:id", tournamentHandler.DeleteTournament)
+		tournamentRoutes.GET("/:id", manage, twoFactorPolicy, tournamentHandler.GetTournamentByID)
+		tournamentRoutes.POST("/", loginOnly, twoFactorPolicy, tournamentHandler.CreateTournament)
+		tournamentRoutes.PUT("/:id", manage, twoFactorPolicy, tournamentHandler.UpdateTournament)
+		tournamentRoutes.PATCH("/:id", manage, twoFactorPolicy, tournamentHandler.PatchTournament)
+		tournamentRoutes.DELETE("/:id", manage, twoFactorPolicy, tournamentHandler.DeleteTournament)
+		tournamentRoutes.POST("/:id/restore", manage, twoFactorPolicy, tournamentHandler.RestoreTournament)
 	}
 }
\ No newline at end of file
@@ -10,7 +10,7 @@ import (
 )
 
 // Setup user routes
-func SetupUserRoutes(r *gin.Engine, userHandler *handlers.UserHandler) {
+func SetupUserRoutes(r *gin.Engine, userHandler *handlers.UserHandler, twoFactorPolicy gin.HandlerFunc) {
 	jwtSecret := os.Getenv("SECRET_KEY")
 	if jwtSecret == "" {
 		log.Fatalf("SECRET_KEY environment variable is not set")
@@ -20,10 +20,28 @@ func SetupUserRoutes(r *gin.Engine, userHandler *handlers.UserHandler) {
 	{
 		userRoutes.POST("/register", userHandler.RegisterUser)
 		userRoutes.POST("/login", userHandler.LoginUser)
-		userRoutes.POST("/logout", userHandler.LogoutUser)
+		userRoutes.POST("/login/2fa", userHandler.LoginTwoFactor)
+		userRoutes.POST("/refresh", userHandler.RefreshToken)
+		userRoutes.POST("/verify-email", userHandler.VerifyEmail)
+		userRoutes.POST("/verify-email/resend", userHandler.ResendVerificationEmail)
+		userRoutes.POST("/forgot-password", userHandler.ForgotPassword)
+		userRoutes.POST("/reset-password", userHandler.ResetPassword)
+		userRoutes.POST("/logout", auth.OptionalAuthMiddleware(jwtSecret), userHandler.LogoutUser)
 
 		userRoutes.Use(auth.AuthMiddleware(jwtSecret))
 
+		// Users can always manage their own account, so they can turn on two-factor when it is required,
+		// but keys act on tournaments and need the policy met
 		userRoutes.PUT("/update", userHandler.UpdateUser)
+		userRoutes.GET("/sessions", userHandler.GetSessions)
+		userRoutes.DELETE("/sessions", userHandler.RevokeAllSessions)
+		userRoutes.DELETE("/sessions/:id", userHandler.RevokeSession)
+		userRoutes.GET("/api-keys", userHandler.GetAPIKeys)
+		userRoutes.POST("/api-keys", twoFactorPolicy, userHandler.CreateAPIKey)
+		userRoutes.DELETE("/api-keys/:id", userHandler.RevokeAPIKey)
+		userRoutes.POST("/2fa/setup", userHandler.SetupTwoFactor)
+		userRoutes.POST("/2fa/enable", userHandler.EnableTwoFactor)
+		userRoutes.POST("/2fa/disable", userHandler.DisableTwoFactor)
+		userRoutes.POST("/2fa/recovery-codes", userHandler.RegenerateRecoveryCodes)
 	}
 }
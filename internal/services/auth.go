@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Logs users in and out. Tokens are returned for the caller to hand to the client,
+// the service never sets cookies itself.
+type AuthService struct {
+	Repos *models.Repositories
+}
+
+// Logs a user in with their email and password
+func (s *AuthService) Login(c context.Context, email, password string, client models.ClientInfo) (*models.LoginResult, error) {
+	result, err := models.LoginUser(c, s.Repos, email, password, client)
+	return result, classify(err)
+}
+
+// Finishes a login that is waiting on a two-factor code
+func (s *AuthService) CompleteTwoFactorLogin(c context.Context, loginToken, code string, client models.ClientInfo) (*models.AuthTokens, error) {
+	tokens, err := models.CompleteTwoFactorLogin(c, s.Repos, loginToken, code, client)
+	return tokens, classify(err)
+}
+
+// Starts a login at a provider, returning the URL to send the user to and the nonce the
+// browser has to keep for the callback. Pass the signed in user's ID to link the provider
+// to their account instead.
+func (s *AuthService) StartOIDCLogin(c context.Context, provider *auth.OIDCProvider, linkUserID *primitive.ObjectID) (string, string, error) {
+	authURL, nonce, err := models.StartOIDCLogin(c, s.Repos.OIDCStates, provider, linkUserID)
+	return authURL, nonce, classify(err)
+}
+
+// Finishes a login, or linking an account, when a provider sends the user back to the
+// browser that started it. currentUserID is the user signed in to the callback, if any.
+func (s *AuthService) FinishOIDCLogin(c context.Context, provider *auth.OIDCProvider, code, state, nonce string, currentUserID primitive.ObjectID, client models.ClientInfo) (*models.LoginResult, error) {
+	result, err := models.FinishOIDCLogin(c, s.Repos, provider, code, state, nonce, currentUserID, client)
+	return result, classify(err)
+}
+
+// Swaps a refresh token for new tokens
+func (s *AuthService) Refresh(c context.Context, refreshToken string, client models.ClientInfo) (*models.AuthTokens, error) {
+	tokens, err := models.RefreshSession(c, s.Repos.Sessions, refreshToken, client)
+	return tokens, classify(err)
+}
+
+// Ends the session the user's access token or refresh token belongs to
+func (s *AuthService) Logout(c context.Context, userID, sessionID primitive.ObjectID, refreshToken string) error {
+	return classify(models.LogoutUser(c, s.Repos.Sessions, userID, sessionID, refreshToken))
+}
+
+func NewAuthService(repos *models.Repositories) *AuthService {
+	return &AuthService{
+		Repos: repos,
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Runs match lobby chat. Access loads a match and checks the user can use its chat,
+// and the other methods act as that user.
+type ChatService struct {
+	Repos *models.Repositories
+}
+
+// Gets a match and the user's access to its chat, failing unless they are a member
+func (s *ChatService) Access(c context.Context, userID, matchID primitive.ObjectID) (*models.Match, *models.ChatAccess, error) {
+	match, err := s.Repos.Matches.GetByID(c, matchID)
+	if err != nil {
+		return nil, nil, classify(err)
+	}
+
+	access, err := models.GetChatAccess(c, s.Repos, match, userID)
+	if err != nil {
+		return nil, nil, classify(err)
+	}
+	if !access.Member {
+		return nil, nil, forbidden(models.ErrChatForbidden)
+	}
+
+	return match, access, nil
+}
+
+// Gets a page of a match's chat history, newest first
+func (s *ChatService) ListMessages(c context.Context, match *models.Match, before primitive.ObjectID, limit int) ([]*models.ChatMessage, error) {
+	chatMessages, err := models.GetChatMessages(c, s.Repos.Chat, match.ID, before, limit)
+	return chatMessages, classify(err)
+}
+
+// Posts a message to a match's chat
+func (s *ChatService) Post(c context.Context, match *models.Match, access *models.ChatAccess, body string) (*models.ChatMessage, error) {
+	chatMessage, err := models.CreateChatMessage(c, s.Repos.Chat, match, access, body)
+	return chatMessage, classify(err)
+}
+
+// Deletes a message from a match's chat
+func (s *ChatService) DeleteMessage(c context.Context, match *models.Match, access *models.ChatAccess, messageID primitive.ObjectID) error {
+	return classify(models.DeleteChatMessage(c, s.Repos.Chat, match, access, messageID))
+}
+
+// Mutes a member of a match's chat for a while
+func (s *ChatService) Mute(c context.Context, match *models.Match, access *models.ChatAccess, userID primitive.ObjectID, duration time.Duration, reason string) (*models.ChatMute, error) {
+	mute, err := models.MuteChatUser(c, s.Repos.Chat, match, access, userID, duration, reason)
+	return mute, classify(err)
+}
+
+// Lifts a user's mute in a match's chat
+func (s *ChatService) Unmute(c context.Context, match *models.Match, access *models.ChatAccess, userID primitive.ObjectID) error {
+	return classify(models.UnmuteChatUser(c, s.Repos.Chat, match, access, userID))
+}
+
+func NewChatService(repos *models.Repositories) *ChatService {
+	return &ChatService{
+		Repos: repos,
+	}
+}
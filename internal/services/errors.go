@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+)
+
+// What went wrong, so each transport can report it its own way
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindForbidden
+	KindConflict
+	KindValidation
+	KindUnauthorized
+)
+
+// An error returned by a service, wrapping the error that caused it
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Gets the kind of an error returned by a service. Anything unrecognised is internal.
+func KindOf(err error) Kind {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Kind
+	}
+	return KindInternal
+}
+
+func notFound(err error) error   { return &Error{Kind: KindNotFound, Err: err} }
+func forbidden(err error) error  { return &Error{Kind: KindForbidden, Err: err} }
+func validation(err error) error { return &Error{Kind: KindValidation, Err: err} }
+
+// The kind of each error the models return
+var errorKinds = map[error]Kind{
+	models.ErrTournamentNotFound:         KindNotFound,
+	models.ErrTeamNotFound:               KindNotFound,
+	models.ErrMatchNotFound:              KindNotFound,
+	models.ErrMatchResultNotFound:        KindNotFound,
+	models.ErrUserNotFound:               KindNotFound,
+	models.ErrOrganisationNotFound:       KindNotFound,
+	models.ErrOrganisationMemberNotFound: KindNotFound,
+	models.ErrPublicTournamentNotFound:   KindNotFound,
+	models.ErrPublicMatchNotFound:        KindNotFound,
+	models.ErrChatMessageNotFound:        KindNotFound,
+	models.ErrLiveScoreNotFound:          KindNotFound,
+	models.ErrAPIKeyNotFound:             KindNotFound,
+	models.ErrProviderNotLinked:          KindNotFound,
+
+	models.ErrNotOrganisationMember: KindForbidden,
+	models.ErrOrganisationForbidden: KindForbidden,
+	models.ErrEmailNotVerified:      KindForbidden,
+	models.ErrTwoFactorRequired:     KindForbidden,
+	models.ErrChatForbidden:         KindForbidden,
+	models.ErrChatMuted:             KindForbidden,
+	models.ErrScopeNotManaged:       KindForbidden,
+	models.ErrOIDCLinkUserMismatch:  KindForbidden,
+
+	models.ErrAlreadyOrganisationMember: KindConflict,
+	models.ErrLastOrganisationOwner:     KindConflict,
+	models.ErrEmailInUse:                KindConflict,
+	models.ErrIdentityAlreadyLinked:     KindConflict,
+	models.ErrOIDCEmailInUse:            KindConflict,
+	models.ErrTournamentHasTeams:        KindConflict,
+	models.ErrTournamentHasMatches:      KindConflict,
+	models.ErrTeamHasMatches:            KindConflict,
+	models.ErrMatchHasResults:           KindConflict,
+	models.ErrParentDeleted:             KindConflict,
+	models.ErrVersionConflict:           KindConflict,
+	models.ErrMapResultExists:           KindConflict,
+	models.ErrTwoFactorAlreadyEnabled:   KindConflict,
+	models.ErrTwoFactorNotEnabled:       KindConflict,
+	models.ErrTwoFactorNotSetUp:         KindConflict,
+	models.ErrLastLoginMethod:           KindConflict,
+
+	models.ErrInvalidOrganisationRole:    KindValidation,
+	models.ErrOrganisationNameMissing:    KindValidation,
+	models.ErrCrossOrganisationReference: KindValidation,
+	models.ErrInvalidEmail:               KindValidation,
+	models.ErrPasswordTooShort:           KindValidation,
+	models.ErrInvalidTimeZone:            KindValidation,
+	models.ErrTournamentDatesRequired:    KindValidation,
+	models.ErrTournamentEndsBeforeStart:  KindValidation,
+	models.ErrMatchOutsideTournament:     KindValidation,
+	models.ErrInvalidListQuery:           KindValidation,
+	models.ErrInvalidCursor:              KindValidation,
+	models.ErrInvalidSearch:              KindValidation,
+	models.ErrChatMessageEmpty:           KindValidation,
+	models.ErrChatMessageTooLong:         KindValidation,
+	models.ErrInvalidMuteDuration:        KindValidation,
+	models.ErrInvalidScope:               KindValidation,
+	models.ErrAPIKeyNameRequired:         KindValidation,
+	models.ErrAPIKeyScopesRequired:       KindValidation,
+	models.ErrAPIKeyExpiryInPast:         KindValidation,
+	models.ErrAPIKeyLifetimeTooLong:      KindValidation,
+
+	// Login lockouts match this too
+	models.ErrInvalidCredentials:   KindUnauthorized,
+	models.ErrInvalidRefreshToken:  KindUnauthorized,
+	models.ErrInvalidTwoFactorCode: KindUnauthorized,
+	models.ErrInvalidAccountToken:  KindUnauthorized,
+	models.ErrInvalidOIDCState:     KindUnauthorized,
+	auth.ErrInvalidIDToken:         KindUnauthorized,
+}
+
+// Wraps an error from the models in the kind it belongs to
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return err
+	}
+
+	for target, kind := range errorKinds {
+		if errors.Is(err, target) {
+			return &Error{Kind: kind, Err: err}
+		}
+	}
+	return err
+}
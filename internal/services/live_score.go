@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LiveScoreService struct {
+	LiveScores    models.LiveScoreRepository
+	Matches       models.MatchRepository
+	Organisations models.OrganisationRepository
+}
+
+// Stores the latest live state for a match the user can manage. A final update also records
+// the map's result, which is returned; other updates return a nil result.
+func (s *LiveScoreService) Save(c context.Context, userID, matchID primitive.ObjectID, score *models.LiveScore) (*models.MapResult, error) {
+	match, err := s.Matches.GetByID(c, matchID)
+	if err != nil {
+		return nil, classify(err)
+	}
+	if err := checkCanManage(c, s.Organisations, match.OrganisationID, match.OrganiserID, userID); err != nil {
+		return nil, err
+	}
+	if err := checkResultsScope(c, match.TournamentID); err != nil {
+		return nil, err
+	}
+
+	if err := score.Validate(match); err != nil {
+		return nil, validation(err)
+	}
+
+	score.MatchID = match.ID
+	score.TournamentID = match.TournamentID
+
+	if score.Final {
+		mapResult, err := models.SaveFinalLiveScore(c, s.LiveScores, match, score)
+		return mapResult, classify(err)
+	}
+
+	if err := models.SaveLiveScore(c, s.LiveScores, score); err != nil {
+		return nil, classify(err)
+	}
+
+	return nil, nil
+}
+
+// Gets the latest live score for a match
+func (s *LiveScoreService) Get(c context.Context, matchID primitive.ObjectID) (*models.LiveScore, error) {
+	score, err := s.LiveScores.GetByMatchID(c, matchID)
+	return score, classify(err)
+}
+
+// Gets the results of each map played in a match, in map order
+func (s *LiveScoreService) ListMapResults(c context.Context, matchID primitive.ObjectID) ([]*models.MapResult, error) {
+	mapResults, err := s.LiveScores.ListMapResults(c, matchID)
+	return mapResults, classify(err)
+}
+
+func NewLiveScoreService(liveScores models.LiveScoreRepository, matches models.MatchRepository, organisations models.OrganisationRepository) *LiveScoreService {
+	return &LiveScoreService{
+		LiveScores:    liveScores,
+		Matches:       matches,
+		Organisations: organisations,
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/auth"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MatchService struct {
+	Matches       models.MatchRepository
+	Teams         models.TeamRepository
+	Tournaments   models.TournamentRepository
+	Organisations models.OrganisationRepository
+	Cascade       models.CascadeRules
+}
+
+// Creates a match in one of the user's organisations. A match with a tournament joins it
+// in the same write, so the match is never stored without being in the tournament's list.
+func (s *MatchService) Create(c context.Context, userID primitive.ObjectID, match *models.Match) (*models.Match, error) {
+	if err := checkMember(c, s.Organisations, match.OrganisationID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkReferences(c, userID, match); err != nil {
+		return nil, err
+	}
+
+	match.OrganiserID = userID
+	createdMatch, err := s.Matches.Create(c, match)
+	if err != nil {
+		return nil, classifyTournamentWrite(err)
+	}
+
+	return createdMatch, nil
+}
+
+// Lists a page of the matches in the user's organisations, or in one of them if
+// organisationID is set
+func (s *MatchService) List(c context.Context, userID, organisationID primitive.ObjectID, query models.ListQuery) (*models.Page[models.Match], error) {
+	scope, err := ownerScope(c, s.Organisations, userID, organisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := s.Matches.List(c, scope, query)
+	return matches, classify(err)
+}
+
+// Gets a match the user can manage
+func (s *MatchService) Get(c context.Context, userID, id primitive.ObjectID) (*models.Match, error) {
+	match, err := s.Matches.GetByID(c, id)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, match.OrganisationID, match.OrganiserID, userID); err != nil {
+		return nil, err
+	}
+	if err := checkTournamentScope(c, match.TournamentID); err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// Updates a match the user can manage. The update is made against updatedMatch.Version,
+// and conflicts if that isn't the stored version.
+func (s *MatchService) Update(c context.Context, userID, id primitive.ObjectID, updatedMatch *models.Match) error {
+	match, err := s.Get(c, userID, id)
+	if err != nil {
+		return err
+	}
+
+	// Matches can't be moved to another organisation or organiser by updating them
+	updatedMatch.OrganisationID = match.OrganisationID
+	updatedMatch.OrganiserID = match.OrganiserID
+	updatedMatch.CreatedAt = match.CreatedAt
+
+	if err := s.checkReferences(c, userID, updatedMatch); err != nil {
+		return err
+	}
+
+	if err := s.Matches.Update(c, id, updatedMatch); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			if current, getErr := s.Matches.GetByID(c, id); getErr == nil {
+				return versionConflict(current, current.Version)
+			}
+		}
+		return classifyTournamentWrite(err)
+	}
+
+	return nil
+}
+
+// Applies a partial update to a match the user can manage. apply changes a copy of the
+// stored match, and is refused if it changes who owns it. The update is made against
+// version.
+func (s *MatchService) Patch(c context.Context, userID, id primitive.ObjectID, version int64, apply func(*models.Match) error) (*models.Match, error) {
+	match, err := s.Get(c, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := *match
+	if err := apply(&patched); err != nil {
+		return nil, validation(err)
+	}
+
+	err = firstError(
+		checkImmutable("ID", match.ID, patched.ID),
+		checkImmutable("OrganiserID", match.OrganiserID, patched.OrganiserID),
+		checkImmutable("OrganisationID", match.OrganisationID, patched.OrganisationID),
+		checkImmutable("CreatedAt", match.CreatedAt.UnixMilli(), patched.CreatedAt.UnixMilli()),
+		// The team names are copied from the teams
+		checkImmutable("Team1Name", match.Team1Name, patched.Team1Name),
+		checkImmutable("Team2Name", match.Team2Name, patched.Team2Name),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	patched.Version = version
+	if err := s.Update(c, userID, id, &patched); err != nil {
+		return nil, err
+	}
+
+	return &patched, nil
+}
+
+// Deletes a match the user can manage, cascading to its results
+func (s *MatchService) Delete(c context.Context, userID, id primitive.ObjectID) error {
+	if _, err := s.Get(c, userID, id); err != nil {
+		return err
+	}
+
+	return classify(s.Matches.Delete(c, id, s.Cascade))
+}
+
+// Restores a deleted match the user can manage, along with everything deleted with it
+func (s *MatchService) Restore(c context.Context, userID, id primitive.ObjectID) error {
+	match, err := s.Matches.GetDeletedByID(c, id)
+	if err != nil {
+		return classify(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, match.OrganisationID, match.OrganiserID, userID); err != nil {
+		return err
+	}
+	if err := checkTournamentScope(c, match.TournamentID); err != nil {
+		return err
+	}
+
+	return classify(s.Matches.Restore(c, id))
+}
+
+// Checks the match's tournament and teams exist and are in its organisation, and that it
+// is scheduled within the tournament, then copies the teams' names onto the match so it
+// can be shown without looking them up
+func (s *MatchService) checkReferences(c context.Context, userID primitive.ObjectID, match *models.Match) error {
+	if err := checkTournamentScope(c, match.TournamentID); err != nil {
+		return err
+	}
+
+	tournament, err := checkTournamentReference(c, s.Tournaments, s.Organisations, userID, match.OrganisationID, match.TournamentID)
+	if err != nil {
+		return err
+	}
+
+	match.Date = models.UTC(match.Date)
+	if tournament != nil {
+		if err := tournament.CheckMatchDate(match.Date); err != nil {
+			return validation(err)
+		}
+	}
+
+	if err := models.CheckOrganisationReferences(c, s.Tournaments, s.Teams, match.OrganisationID, primitive.NilObjectID, match.Team1ID, match.Team2ID); err != nil {
+		return classifyReference(err)
+	}
+
+	team1, err := s.Teams.GetByID(c, match.Team1ID)
+	if err != nil {
+		return classifyReference(err)
+	}
+
+	team2, err := s.Teams.GetByID(c, match.Team2ID)
+	if err != nil {
+		return classifyReference(err)
+	}
+
+	match.Team1Name = team1.Name
+	match.Team2Name = team2.Name
+	return nil
+}
+
+// Checks a request made with an API key can manage the tournament. API keys can't manage
+// matches that aren't in a tournament.
+func checkTournamentScope(c context.Context, tournamentID primitive.ObjectID) error {
+	if err := auth.CheckScope(c, auth.TournamentManageScope(tournamentID.Hex())); err != nil {
+		return forbidden(err)
+	}
+	return nil
+}
+
+// Checks a request made with an API key can write results in the tournament, which it can
+// with results:write or the tournament's own scope
+func checkResultsScope(c context.Context, tournamentID primitive.ObjectID) error {
+	if err := auth.CheckScope(c, auth.ScopeResultsWrite, auth.TournamentManageScope(tournamentID.Hex())); err != nil {
+		return forbidden(err)
+	}
+	return nil
+}
+
+func NewMatchService(matches models.MatchRepository, teams models.TeamRepository, tournaments models.TournamentRepository, organisations models.OrganisationRepository, cascade models.CascadeRules) *MatchService {
+	return &MatchService{
+		Matches:       matches,
+		Teams:         teams,
+		Tournaments:   tournaments,
+		Organisations: organisations,
+		Cascade:       cascade,
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MatchResultService struct {
+	MatchResults  models.MatchResultRepository
+	Matches       models.MatchRepository
+	Organisations models.OrganisationRepository
+}
+
+// Records the result of a match the user can manage. Results belong to their match's organisation.
+func (s *MatchResultService) Create(c context.Context, userID primitive.ObjectID, matchResult *models.MatchResult) (*models.MatchResult, error) {
+	match, err := s.Matches.GetByID(c, matchResult.MatchID)
+	if err != nil {
+		return nil, classifyReference(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, match.OrganisationID, match.OrganiserID, userID); err != nil {
+		return nil, err
+	}
+	if err := checkResultsScope(c, match.TournamentID); err != nil {
+		return nil, err
+	}
+
+	matchResult.OrganiserID = userID
+	matchResult.OrganisationID = match.OrganisationID
+
+	createdMatchResult, err := s.MatchResults.Create(c, matchResult)
+	return createdMatchResult, classify(err)
+}
+
+// Lists a page of the match results in the user's organisations, or in one of them if
+// organisationID is set
+func (s *MatchResultService) List(c context.Context, userID, organisationID primitive.ObjectID, query models.ListQuery) (*models.Page[models.MatchResult], error) {
+	scope, err := ownerScope(c, s.Organisations, userID, organisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	matchResults, err := s.MatchResults.List(c, scope, query)
+	return matchResults, classify(err)
+}
+
+// Gets a match result the user can manage
+func (s *MatchResultService) Get(c context.Context, userID, id primitive.ObjectID) (*models.MatchResult, error) {
+	matchResult, err := s.MatchResults.GetByID(c, id)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, matchResult.OrganisationID, matchResult.OrganiserID, userID); err != nil {
+		return nil, err
+	}
+	if err := s.checkScope(c, matchResult.MatchID); err != nil {
+		return nil, err
+	}
+	return matchResult, nil
+}
+
+// Updates a match result the user can manage. The update is made against
+// updatedMatchResult.Version, and conflicts if that isn't the stored version.
+func (s *MatchResultService) Update(c context.Context, userID, id primitive.ObjectID, updatedMatchResult *models.MatchResult) error {
+	matchResult, err := s.Get(c, userID, id)
+	if err != nil {
+		return err
+	}
+
+	// A result can only be moved to another match in the same organisation
+	if updatedMatchResult.MatchID != matchResult.MatchID {
+		match, err := s.Matches.GetByID(c, updatedMatchResult.MatchID)
+		if err != nil {
+			return classifyReference(err)
+		}
+		if match.OrganisationID != matchResult.OrganisationID {
+			return validation(models.ErrCrossOrganisationReference)
+		}
+		if err := checkResultsScope(c, match.TournamentID); err != nil {
+			return err
+		}
+	}
+
+	updatedMatchResult.OrganisationID = matchResult.OrganisationID
+	updatedMatchResult.OrganiserID = matchResult.OrganiserID
+	updatedMatchResult.CreatedAt = matchResult.CreatedAt
+
+	if err := s.MatchResults.Update(c, id, updatedMatchResult); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			if current, getErr := s.MatchResults.GetByID(c, id); getErr == nil {
+				return versionConflict(current, current.Version)
+			}
+		}
+		return classify(err)
+	}
+
+	return nil
+}
+
+// Applies a partial update to a match result the user can manage. apply changes a copy of the
+// stored match result, and is refused if it changes who owns it. The update is made against
+// version.
+func (s *MatchResultService) Patch(c context.Context, userID, id primitive.ObjectID, version int64, apply func(*models.MatchResult) error) (*models.MatchResult, error) {
+	matchResult, err := s.Get(c, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := *matchResult
+	if err := apply(&patched); err != nil {
+		return nil, validation(err)
+	}
+
+	err = firstError(
+		checkImmutable("ID", matchResult.ID, patched.ID),
+		checkImmutable("OrganiserID", matchResult.OrganiserID, patched.OrganiserID),
+		checkImmutable("OrganisationID", matchResult.OrganisationID, patched.OrganisationID),
+		checkImmutable("CreatedAt", matchResult.CreatedAt.UnixMilli(), patched.CreatedAt.UnixMilli()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	patched.Version = version
+	if err := s.Update(c, userID, id, &patched); err != nil {
+		return nil, err
+	}
+
+	return &patched, nil
+}
+
+// Deletes a match result the user can manage
+func (s *MatchResultService) Delete(c context.Context, userID, id primitive.ObjectID) error {
+	if _, err := s.Get(c, userID, id); err != nil {
+		return err
+	}
+
+	return classify(s.MatchResults.Delete(c, id))
+}
+
+// Restores a deleted match result the user can manage
+func (s *MatchResultService) Restore(c context.Context, userID, id primitive.ObjectID) error {
+	matchResult, err := s.MatchResults.GetDeletedByID(c, id)
+	if err != nil {
+		return classify(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, matchResult.OrganisationID, matchResult.OrganiserID, userID); err != nil {
+		return err
+	}
+	if err := s.checkScope(c, matchResult.MatchID); err != nil {
+		return err
+	}
+
+	return classify(s.MatchResults.Restore(c, id))
+}
+
+// Checks a request made with an API key can write results for the match's tournament
+func (s *MatchResultService) checkScope(c context.Context, matchID primitive.ObjectID) error {
+	match, err := s.Matches.GetByID(c, matchID)
+	if err != nil {
+		return classify(err)
+	}
+	return checkResultsScope(c, match.TournamentID)
+}
+
+func NewMatchResultService(matchResults models.MatchResultRepository, matches models.MatchRepository, organisations models.OrganisationRepository) *MatchResultService {
+	return &MatchResultService{
+		MatchResults:  matchResults,
+		Matches:       matches,
+		Organisations: organisations,
+	}
+}
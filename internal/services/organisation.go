@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errOrganisationRequired = errors.New("organisation_id is required")
+
+type OrganisationService struct {
+	Organisations models.OrganisationRepository
+	Users         models.UserRepository
+}
+
+// Creates an organisation, with the user as its owner
+func (s *OrganisationService) Create(c context.Context, userID primitive.ObjectID, name string) (*models.Organisation, error) {
+	organisation, err := models.CreateOrganisation(c, s.Organisations, name, userID)
+	return organisation, classify(err)
+}
+
+// Lists the organisations a user is a member of
+func (s *OrganisationService) List(c context.Context, userID primitive.ObjectID) ([]*models.Organisation, error) {
+	organisations, err := s.Organisations.ListForUser(c, userID)
+	return organisations, classify(err)
+}
+
+// Gets an organisation the user is a member of. Other organisations are reported
+// as missing so their existence isn't revealed.
+func (s *OrganisationService) Get(c context.Context, userID, id primitive.ObjectID) (*models.Organisation, error) {
+	organisation, err := s.Organisations.GetByID(c, id)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	if organisation.RoleOf(userID) == "" {
+		return nil, notFound(models.ErrOrganisationNotFound)
+	}
+	return organisation, nil
+}
+
+// Adds a user to an organisation on behalf of one of its admins or owners
+func (s *OrganisationService) AddMember(c context.Context, organisationID, actorID, userID primitive.ObjectID, role string) error {
+	return classify(models.AddOrganisationMember(c, s.Organisations, s.Users, organisationID, actorID, userID, role))
+}
+
+// Changes a member's role on behalf of one of the organisation's admins or owners
+func (s *OrganisationService) UpdateMember(c context.Context, organisationID, actorID, userID primitive.ObjectID, role string) error {
+	return classify(models.UpdateOrganisationMemberRole(c, s.Organisations, organisationID, actorID, userID, role))
+}
+
+// Removes a member from an organisation
+func (s *OrganisationService) RemoveMember(c context.Context, organisationID, actorID, userID primitive.ObjectID) error {
+	return classify(models.RemoveOrganisationMember(c, s.Organisations, organisationID, actorID, userID))
+}
+
+func NewOrganisationService(organisations models.OrganisationRepository, users models.UserRepository) *OrganisationService {
+	return &OrganisationService{
+		Organisations: organisations,
+		Users:         users,
+	}
+}
+
+// Checks a user can manage something an organisation owns
+func checkCanManage(c context.Context, organisations models.OrganisationRepository, organisationID, organiserID, userID primitive.ObjectID) error {
+	allowed, err := models.CanManageResource(c, organisations, organisationID, organiserID, userID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return forbidden(models.ErrNotOrganisationMember)
+	}
+	return nil
+}
+
+// Checks a user belongs to the organisation they are creating something in
+func checkMember(c context.Context, organisations models.OrganisationRepository, organisationID, userID primitive.ObjectID) error {
+	if organisationID.IsZero() {
+		return validation(errOrganisationRequired)
+	}
+
+	_, err := models.GetOrganisationRole(c, organisations, organisationID, userID)
+	return classify(err)
+}
+
+// Gets what a user can list, narrowed to one organisation when organisationID is set
+func ownerScope(c context.Context, organisations models.OrganisationRepository, userID, organisationID primitive.ObjectID) (models.OwnerScope, error) {
+	scope, err := models.GetOwnerScope(c, organisations, userID, organisationID)
+	return scope, classify(err)
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OverlayService struct {
+	Repos *models.Repositories
+}
+
+// Builds the broadcast overlay for a match
+func (s *OverlayService) Get(c context.Context, matchID primitive.ObjectID) (*models.OverlaySnapshot, error) {
+	snapshot, err := models.BuildOverlaySnapshot(c, s.Repos, matchID)
+	return snapshot, classify(err)
+}
+
+func NewOverlayService(repos *models.Repositories) *OverlayService {
+	return &OverlayService{
+		Repos: repos,
+	}
+}
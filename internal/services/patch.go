@@ -0,0 +1,33 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Refuses a patch that changes a field which can't be changed
+func checkImmutable[T comparable](name string, before, after T) error {
+	if before != after {
+		return validation(fmt.Errorf("%s can't be changed", name))
+	}
+	return nil
+}
+
+// Like checkImmutable, for lists of references such as a tournament's teams
+func checkImmutableIDs(name string, before, after []primitive.ObjectID) error {
+	if !slices.Equal(before, after) {
+		return validation(fmt.Errorf("%s can't be changed", name))
+	}
+	return nil
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reads what spectators can see. Nothing here needs a signed in user.
+type PublicService struct {
+	Public  models.PublicReader
+	Matches models.MatchRepository
+}
+
+// Lists a page of the published tournaments
+func (s *PublicService) ListTournaments(c context.Context, query models.ListQuery) (*models.Page[models.PublicTournament], error) {
+	tournaments, err := models.GetPublishedTournaments(c, s.Public, query)
+	return tournaments, classify(err)
+}
+
+// Gets a published tournament and its teams
+func (s *PublicService) GetTournament(c context.Context, id primitive.ObjectID) (*models.PublicTournament, error) {
+	tournament, err := models.GetPublishedTournament(c, s.Public, id)
+	return tournament, classify(err)
+}
+
+// Gets a published tournament's bracket
+func (s *PublicService) GetBracket(c context.Context, id primitive.ObjectID) ([]*models.PublicBracketRound, error) {
+	rounds, err := models.GetPublicBracket(c, s.Public, id)
+	return rounds, classify(err)
+}
+
+// Gets a published tournament's standings
+func (s *PublicService) GetStandings(c context.Context, id primitive.ObjectID) ([]*models.PublicStanding, error) {
+	standings, err := models.GetPublicStandings(c, s.Public, id)
+	return standings, classify(err)
+}
+
+// Gets a published tournament's matches in the order they are played
+func (s *PublicService) GetSchedule(c context.Context, id primitive.ObjectID) ([]*models.PublicMatch, error) {
+	matches, err := models.GetPublicSchedule(c, s.Public, id)
+	return matches, classify(err)
+}
+
+// Gets a match from a published tournament
+func (s *PublicService) GetMatch(c context.Context, id primitive.ObjectID) (*models.PublicMatch, error) {
+	match, err := models.GetPublicMatch(c, s.Public, s.Matches, id)
+	return match, classify(err)
+}
+
+func NewPublicService(public models.PublicReader, matches models.MatchRepository) *PublicService {
+	return &PublicService{
+		Public:  public,
+		Matches: matches,
+	}
+}
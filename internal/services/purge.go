@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+)
+
+// How long deleted documents can be restored for when DELETED_RETENTION isn't set
+const DefaultDeletedRetention = 30 * 24 * time.Hour
+
+// Reads DELETED_RETENTION as a duration such as "720h"
+func DeletedRetentionFromEnv() (time.Duration, error) {
+	value := os.Getenv("DELETED_RETENTION")
+	if value == "" {
+		return DefaultDeletedRetention, nil
+	}
+
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid DELETED_RETENTION %q", value)
+	}
+	return retention, nil
+}
+
+// Removes deleted tournaments, teams, matches and results for good once they have been
+// kept for the retention period
+type PurgeService struct {
+	Purger    models.DeletedPurger
+	Retention time.Duration
+}
+
+// Purges everything deleted more than the retention period ago
+func (s *PurgeService) Purge(c context.Context) (int64, error) {
+	return s.Purger.PurgeDeleted(c, time.Now().UTC().Add(-s.Retention))
+}
+
+// Run purges on an interval until ctx is cancelled
+func (s *PurgeService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.Purge(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error purging deleted documents: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted documents", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func NewPurgeService(purger models.DeletedPurger, retention time.Duration) *PurgeService {
+	return &PurgeService{
+		Purger:    purger,
+		Retention: retention,
+	}
+}
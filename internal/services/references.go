@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reports a tournament, team or match that something refers to but doesn't exist as
+// a problem with the request rather than a missing page
+func classifyReference(err error) error {
+	err = classify(err)
+
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) && serviceErr.Kind == KindNotFound {
+		return validation(serviceErr.Err)
+	}
+	return err
+}
+
+// Checks the tournament something is being added to exists, belongs to the same
+// organisation and is one the user can manage, and returns it. An empty tournament is skipped.
+func checkTournamentReference(c context.Context, tournaments models.TournamentRepository, organisations models.OrganisationRepository, userID, organisationID, tournamentID primitive.ObjectID) (*models.Tournament, error) {
+	if tournamentID.IsZero() {
+		return nil, nil
+	}
+
+	tournament, err := tournaments.GetByID(c, tournamentID)
+	if err != nil {
+		return nil, classifyReference(err)
+	}
+
+	if err := checkCanManage(c, organisations, tournament.OrganisationID, tournament.OrganiserID, userID); err != nil {
+		return nil, err
+	}
+	if tournament.OrganisationID != organisationID {
+		return nil, validation(models.ErrCrossOrganisationReference)
+	}
+	return tournament, nil
+}
+
+// Classifies an error from storing a team or match, where a missing tournament means the
+// tournament it was joining was deleted after it was checked
+func classifyTournamentWrite(err error) error {
+	if errors.Is(err, models.ErrTournamentNotFound) {
+		return validation(err)
+	}
+	return classify(err)
+}
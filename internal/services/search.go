@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SearchService struct {
+	Searcher      models.Searcher
+	Organisations models.OrganisationRepository
+}
+
+// Searches the tournaments, teams and players in the user's organisations, or in one of
+// them if organisationID is set
+func (s *SearchService) Search(c context.Context, userID, organisationID primitive.ObjectID, query models.SearchQuery) (*models.SearchResults, error) {
+	scope, err := ownerScope(c, s.Organisations, userID, organisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.Searcher.Search(c, scope, query)
+	return results, classify(err)
+}
+
+func NewSearchService(searcher models.Searcher, organisations models.OrganisationRepository) *SearchService {
+	return &SearchService{
+		Searcher:      searcher,
+		Organisations: organisations,
+	}
+}
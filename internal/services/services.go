@@ -0,0 +1,46 @@
+// Package services holds the application's use cases. Services take a context.Context
+// rather than an HTTP request, so the same rules apply whether they are called from the
+// HTTP API, a CLI or a scheduled job, and they return *Error so callers can tell what
+// went wrong without knowing about the models.
+package services
+
+import (
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/mailer"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+)
+
+// Every service, built on the same repositories
+type Services struct {
+	Tournaments   *TournamentService
+	Teams         *TeamService
+	Matches       *MatchService
+	MatchResults  *MatchResultService
+	Organisations *OrganisationService
+	Users         *UserService
+	Auth          *AuthService
+	Search        *SearchService
+	Chat          *ChatService
+	LiveScores    *LiveScoreService
+	Overlays      *OverlayService
+	Public        *PublicService
+	Visibility    *VisibilityService
+}
+
+// Deletes cascade by the given rules
+func NewServices(repos *models.Repositories, m mailer.Mailer, cascade models.CascadeRules) *Services {
+	return &Services{
+		Tournaments:   NewTournamentService(repos.Tournaments, repos.Teams, repos.Matches, repos.Organisations, cascade),
+		Teams:         NewTeamService(repos.Teams, repos.Tournaments, repos.Organisations, cascade),
+		Matches:       NewMatchService(repos.Matches, repos.Teams, repos.Tournaments, repos.Organisations, cascade),
+		MatchResults:  NewMatchResultService(repos.MatchResults, repos.Matches, repos.Organisations),
+		Organisations: NewOrganisationService(repos.Organisations, repos.Users),
+		Users:         NewUserService(repos, m),
+		Auth:          NewAuthService(repos),
+		Search:        NewSearchService(repos.Search, repos.Organisations),
+		Chat:          NewChatService(repos),
+		LiveScores:    NewLiveScoreService(repos.LiveScores, repos.Matches, repos.Organisations),
+		Overlays:      NewOverlayService(repos),
+		Public:        NewPublicService(repos.Public, repos.Matches),
+		Visibility:    NewVisibilityService(repos.Tournaments, repos.Matches, repos.Organisations),
+	}
+}
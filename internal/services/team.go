@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TeamService struct {
+	Teams         models.TeamRepository
+	Tournaments   models.TournamentRepository
+	Organisations models.OrganisationRepository
+	Cascade       models.CascadeRules
+}
+
+// Creates a team in one of the user's organisations. A team with a tournament joins it in
+// the same write, so the team is never stored without being in the tournament's list.
+func (s *TeamService) Create(c context.Context, userID primitive.ObjectID, team *models.Team) (*models.Team, error) {
+	if err := checkMember(c, s.Organisations, team.OrganisationID, userID); err != nil {
+		return nil, err
+	}
+
+	if _, err := checkTournamentReference(c, s.Tournaments, s.Organisations, userID, team.OrganisationID, team.TournamentID); err != nil {
+		return nil, err
+	}
+
+	team.OrganiserID = userID
+	createdTeam, err := s.Teams.Create(c, team)
+	if err != nil {
+		return nil, classifyTournamentWrite(err)
+	}
+
+	return createdTeam, nil
+}
+
+// Lists a page of the teams in the user's organisations, or in one of them if
+// organisationID is set
+func (s *TeamService) List(c context.Context, userID, organisationID primitive.ObjectID, query models.ListQuery) (*models.Page[models.Team], error) {
+	scope, err := ownerScope(c, s.Organisations, userID, organisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := s.Teams.List(c, scope, query)
+	return teams, classify(err)
+}
+
+// Gets a team the user can manage
+func (s *TeamService) Get(c context.Context, userID, id primitive.ObjectID) (*models.Team, error) {
+	team, err := s.Teams.GetByID(c, id)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, team.OrganisationID, team.OrganiserID, userID); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// Updates a team the user can manage. The update is made against updatedTeam.Version,
+// and conflicts if that isn't the stored version.
+func (s *TeamService) Update(c context.Context, userID, id primitive.ObjectID, updatedTeam *models.Team) error {
+	team, err := s.Get(c, userID, id)
+	if err != nil {
+		return err
+	}
+
+	// Teams can't be moved to another organisation or organiser by updating them
+	updatedTeam.OrganisationID = team.OrganisationID
+	updatedTeam.OrganiserID = team.OrganiserID
+	updatedTeam.CreatedAt = team.CreatedAt
+
+	if _, err := checkTournamentReference(c, s.Tournaments, s.Organisations, userID, updatedTeam.OrganisationID, updatedTeam.TournamentID); err != nil {
+		return err
+	}
+
+	if err := s.Teams.Update(c, id, updatedTeam); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			if current, getErr := s.Teams.GetByID(c, id); getErr == nil {
+				return versionConflict(current, current.Version)
+			}
+		}
+		return classifyTournamentWrite(err)
+	}
+
+	return nil
+}
+
+// Applies a partial update to a team the user can manage. apply changes a copy of the
+// stored team, and is refused if it changes who owns it. The update is made against
+// version.
+func (s *TeamService) Patch(c context.Context, userID, id primitive.ObjectID, version int64, apply func(*models.Team) error) (*models.Team, error) {
+	team, err := s.Get(c, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := *team
+	if err := apply(&patched); err != nil {
+		return nil, validation(err)
+	}
+
+	err = firstError(
+		checkImmutable("ID", team.ID, patched.ID),
+		checkImmutable("OrganiserID", team.OrganiserID, patched.OrganiserID),
+		checkImmutable("OrganisationID", team.OrganisationID, patched.OrganisationID),
+		checkImmutable("CreatedAt", team.CreatedAt.UnixMilli(), patched.CreatedAt.UnixMilli()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	patched.Version = version
+	if err := s.Update(c, userID, id, &patched); err != nil {
+		return nil, err
+	}
+
+	return &patched, nil
+}
+
+// Deletes a team the user can manage, cascading to its matches
+func (s *TeamService) Delete(c context.Context, userID, id primitive.ObjectID) error {
+	if _, err := s.Get(c, userID, id); err != nil {
+		return err
+	}
+
+	return classify(s.Teams.Delete(c, id, s.Cascade))
+}
+
+// Restores a deleted team the user can manage, along with everything deleted with it
+func (s *TeamService) Restore(c context.Context, userID, id primitive.ObjectID) error {
+	team, err := s.Teams.GetDeletedByID(c, id)
+	if err != nil {
+		return classify(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, team.OrganisationID, team.OrganiserID, userID); err != nil {
+		return err
+	}
+
+	return classify(s.Teams.Restore(c, id))
+}
+
+func NewTeamService(teams models.TeamRepository, tournaments models.TournamentRepository, organisations models.OrganisationRepository, cascade models.CascadeRules) *TeamService {
+	return &TeamService{
+		Teams:         teams,
+		Tournaments:   tournaments,
+		Organisations: organisations,
+		Cascade:       cascade,
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TournamentService struct {
+	Tournaments   models.TournamentRepository
+	Teams         models.TeamRepository
+	Matches       models.MatchRepository
+	Organisations models.OrganisationRepository
+	Cascade       models.CascadeRules
+}
+
+// Creates a tournament in one of the user's organisations, with the user as its organiser
+func (s *TournamentService) Create(c context.Context, userID primitive.ObjectID, tournament *models.Tournament) (*models.Tournament, error) {
+	if err := checkMember(c, s.Organisations, tournament.OrganisationID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkReferences(c, tournament); err != nil {
+		return nil, err
+	}
+
+	tournament.OrganiserID = userID
+	createdTournament, err := s.Tournaments.Create(c, tournament)
+	return createdTournament, classify(err)
+}
+
+// Lists a page of the tournaments in the user's organisations, or in one of them if
+// organisationID is set
+func (s *TournamentService) List(c context.Context, userID, organisationID primitive.ObjectID, query models.ListQuery) (*models.Page[models.Tournament], error) {
+	scope, err := ownerScope(c, s.Organisations, userID, organisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	tournaments, err := s.Tournaments.List(c, scope, query)
+	return tournaments, classify(err)
+}
+
+// Gets a tournament the user can manage
+func (s *TournamentService) Get(c context.Context, userID, id primitive.ObjectID) (*models.Tournament, error) {
+	tournament, err := s.Tournaments.GetByID(c, id)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, tournament.OrganisationID, tournament.OrganiserID, userID); err != nil {
+		return nil, err
+	}
+	return tournament, nil
+}
+
+// Updates a tournament the user can manage. The update is made against
+// updatedTournament.Version, and conflicts if that isn't the stored version.
+func (s *TournamentService) Update(c context.Context, userID, id primitive.ObjectID, updatedTournament *models.Tournament) error {
+	tournament, err := s.Get(c, userID, id)
+	if err != nil {
+		return err
+	}
+
+	// Tournaments can't be moved to another organisation or organiser by updating them
+	updatedTournament.OrganisationID = tournament.OrganisationID
+	updatedTournament.OrganiserID = tournament.OrganiserID
+	updatedTournament.CreatedAt = tournament.CreatedAt
+
+	// The teams and matches lists are kept in step with the teams and matches pointing at the
+	// tournament, which are moved by updating them, so the stored lists are kept
+	updatedTournament.Teams = tournament.Teams
+	updatedTournament.Matches = tournament.Matches
+
+	if err := s.checkReferences(c, updatedTournament); err != nil {
+		return err
+	}
+
+	if err := s.Tournaments.Update(c, id, updatedTournament); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			if current, getErr := s.Tournaments.GetByID(c, id); getErr == nil {
+				return versionConflict(current, current.Version)
+			}
+		}
+		return classify(err)
+	}
+
+	return nil
+}
+
+// Applies a partial update to a tournament the user can manage. apply changes a copy of the
+// stored tournament, and is refused if it changes who owns it or its teams or matches. The update is made against
+// version.
+func (s *TournamentService) Patch(c context.Context, userID, id primitive.ObjectID, version int64, apply func(*models.Tournament) error) (*models.Tournament, error) {
+	tournament, err := s.Get(c, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := *tournament
+	if err := apply(&patched); err != nil {
+		return nil, validation(err)
+	}
+
+	err = firstError(
+		checkImmutable("ID", tournament.ID, patched.ID),
+		checkImmutable("OrganiserID", tournament.OrganiserID, patched.OrganiserID),
+		checkImmutable("OrganisationID", tournament.OrganisationID, patched.OrganisationID),
+		checkImmutable("CreatedAt", tournament.CreatedAt.UnixMilli(), patched.CreatedAt.UnixMilli()),
+		checkImmutableIDs("Teams", tournament.Teams, patched.Teams),
+		checkImmutableIDs("Matches", tournament.Matches, patched.Matches),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	patched.Version = version
+	if err := s.Update(c, userID, id, &patched); err != nil {
+		return nil, err
+	}
+
+	return &patched, nil
+}
+
+// Deletes a tournament the user can manage, cascading to its teams and matches
+func (s *TournamentService) Delete(c context.Context, userID, id primitive.ObjectID) error {
+	if _, err := s.Get(c, userID, id); err != nil {
+		return err
+	}
+
+	return classify(s.Tournaments.Delete(c, id, s.Cascade))
+}
+
+// Restores a deleted tournament the user can manage, along with everything deleted with it
+func (s *TournamentService) Restore(c context.Context, userID, id primitive.ObjectID) error {
+	tournament, err := s.Tournaments.GetDeletedByID(c, id)
+	if err != nil {
+		return classify(err)
+	}
+
+	if err := checkCanManage(c, s.Organisations, tournament.OrganisationID, tournament.OrganiserID, userID); err != nil {
+		return err
+	}
+
+	return classify(s.Tournaments.Restore(c, id))
+}
+
+// Checks the tournament's dates and time zone, and that the teams and matches listed on it
+// exist in its organisation with the matches scheduled within its dates
+func (s *TournamentService) checkReferences(c context.Context, tournament *models.Tournament) error {
+	if err := tournament.NormaliseSchedule(); err != nil {
+		return validation(err)
+	}
+
+	if err := models.CheckOrganisationReferences(c, s.Tournaments, s.Teams, tournament.OrganisationID, primitive.NilObjectID, tournament.Teams...); err != nil {
+		return classifyReference(err)
+	}
+
+	for _, matchID := range tournament.Matches {
+		match, err := s.Matches.GetByID(c, matchID)
+		if err != nil {
+			return classifyReference(err)
+		}
+		if match.OrganisationID != tournament.OrganisationID {
+			return validation(models.ErrCrossOrganisationReference)
+		}
+		if err := tournament.CheckMatchDate(match.Date); err != nil {
+			return validation(err)
+		}
+	}
+	return nil
+}
+
+func NewTournamentService(tournaments models.TournamentRepository, teams models.TeamRepository, matches models.MatchRepository, organisations models.OrganisationRepository, cascade models.CascadeRules) *TournamentService {
+	return &TournamentService{
+		Tournaments:   tournaments,
+		Teams:         teams,
+		Matches:       matches,
+		Organisations: organisations,
+		Cascade:       cascade,
+	}
+}
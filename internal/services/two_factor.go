@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Generates a new authenticator secret for the user to confirm with EnableTwoFactor
+func (s *UserService) SetupTwoFactor(c context.Context, userID primitive.ObjectID) (*models.TwoFactorSetup, error) {
+	user, err := s.Repos.Users.GetByID(c, userID)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	setup, err := models.SetupTwoFactor(c, s.Repos.Users, user)
+	return setup, classify(err)
+}
+
+// Turns on two-factor authentication, returning the user's recovery codes
+func (s *UserService) EnableTwoFactor(c context.Context, userID primitive.ObjectID, code string) ([]string, error) {
+	user, err := s.Repos.Users.GetByID(c, userID)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	recoveryCodes, err := models.EnableTwoFactor(c, s.Repos.Users, user, code)
+	return recoveryCodes, classify(err)
+}
+
+// Turns off two-factor authentication, unless the policy requires the user to keep it
+func (s *UserService) DisableTwoFactor(c context.Context, userID primitive.ObjectID, code string) error {
+	user, err := s.Repos.Users.GetByID(c, userID)
+	if err != nil {
+		return classify(err)
+	}
+
+	return classify(models.DisableTwoFactor(c, s.Repos, user, code))
+}
+
+// Replaces the user's recovery codes with new ones
+func (s *UserService) RegenerateRecoveryCodes(c context.Context, userID primitive.ObjectID, code string) ([]string, error) {
+	user, err := s.Repos.Users.GetByID(c, userID)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	recoveryCodes, err := models.RegenerateRecoveryCodes(c, s.Repos.Users, user, code)
+	return recoveryCodes, classify(err)
+}
+
+// Fails if the two-factor policy requires the user to have two-factor authentication
+// and they haven't turned it on
+func (s *UserService) CheckTwoFactorPolicy(c context.Context, userID primitive.ObjectID) error {
+	user, err := s.Repos.Users.GetByID(c, userID)
+	if err != nil {
+		return classify(err)
+	}
+	if user.TwoFactorEnabled {
+		return nil
+	}
+
+	required, err := models.TwoFactorRequired(c, s.Repos, user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return forbidden(models.ErrTwoFactorRequired)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/mailer"
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Manages the signed in user's account, sessions and API keys
+type UserService struct {
+	Repos  *models.Repositories
+	Mailer mailer.Mailer
+}
+
+// Registers a user and sends them the link to verify their email address
+func (s *UserService) Register(c context.Context, newUser *models.User) error {
+	if err := models.RegisterUser(c, s.Repos.Users, newUser); err != nil {
+		return classify(err)
+	}
+
+	// The account exists either way, a failed email can be sent again from /users/verify-email/resend
+	if err := models.SendVerificationEmail(c, s.Repos.AccountTokens, s.Mailer, newUser); err != nil {
+		log.Println("could not send verification email:", err)
+	}
+
+	return nil
+}
+
+// Gets a user by ID
+func (s *UserService) Get(c context.Context, userID primitive.ObjectID) (*models.User, error) {
+	user, err := s.Repos.Users.GetByID(c, userID)
+	return user, classify(err)
+}
+
+// Changes a user's username and/or password. Empty values are left as they are.
+func (s *UserService) Update(c context.Context, userID primitive.ObjectID, username, password string) error {
+	return classify(models.UpdateUser(c, s.Repos, userID, username, password))
+}
+
+// Marks the email address a verification token was sent to as verified
+func (s *UserService) VerifyEmail(c context.Context, token string) error {
+	return classify(models.VerifyEmail(c, s.Repos, token))
+}
+
+// Sends a new verification email if the address belongs to an unverified user
+func (s *UserService) ResendVerificationEmail(c context.Context, email string) error {
+	return classify(models.ResendVerificationEmail(c, s.Repos, s.Mailer, email))
+}
+
+// Emails a password reset link if the address belongs to a user
+func (s *UserService) RequestPasswordReset(c context.Context, email string) error {
+	return classify(models.RequestPasswordReset(c, s.Repos, s.Mailer, email))
+}
+
+// Sets a new password using a reset token and signs the user out everywhere
+func (s *UserService) ResetPassword(c context.Context, token, password string) error {
+	return classify(models.ResetPassword(c, s.Repos, token, password))
+}
+
+// Lists the user's active sessions, most recently used first
+func (s *UserService) ListSessions(c context.Context, userID primitive.ObjectID) ([]*models.Session, error) {
+	sessions, err := s.Repos.Sessions.ListActive(c, userID)
+	return sessions, classify(err)
+}
+
+// Revokes one of the user's sessions
+func (s *UserService) RevokeSession(c context.Context, userID, sessionID primitive.ObjectID) error {
+	return classify(models.RevokeSession(c, s.Repos.Sessions, userID, sessionID))
+}
+
+// Revokes every one of the user's sessions
+func (s *UserService) RevokeAllSessions(c context.Context, userID primitive.ObjectID) error {
+	return classify(models.RevokeAllSessions(c, s.Repos.Sessions, userID))
+}
+
+// Creates an API key for the user, returning the key itself this one time
+func (s *UserService) CreateAPIKey(c context.Context, userID primitive.ObjectID, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	apiKey, key, err := models.CreateAPIKey(c, s.Repos, userID, name, scopes, expiresAt)
+	return apiKey, key, classify(err)
+}
+
+// Lists the user's API keys that have not been revoked, newest first
+func (s *UserService) ListAPIKeys(c context.Context, userID primitive.ObjectID) ([]*models.APIKey, error) {
+	apiKeys, err := s.Repos.APIKeys.ListActive(c, userID)
+	return apiKeys, classify(err)
+}
+
+// Revokes one of the user's API keys
+func (s *UserService) RevokeAPIKey(c context.Context, userID, keyID primitive.ObjectID) error {
+	return classify(s.Repos.APIKeys.Revoke(c, userID, keyID))
+}
+
+// Unlinks a login provider from the user, as long as they can still log in some other way
+func (s *UserService) UnlinkIdentity(c context.Context, userID primitive.ObjectID, provider string) error {
+	user, err := s.Repos.Users.GetByID(c, userID)
+	if err != nil {
+		return classify(err)
+	}
+
+	return classify(models.UnlinkIdentity(c, s.Repos.Users, user, provider))
+}
+
+func NewUserService(repos *models.Repositories, m mailer.Mailer) *UserService {
+	return &UserService{
+		Repos:  repos,
+		Mailer: m,
+	}
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+)
+
+// An update made against an old version. Current is the stored document, so the client
+// can see what changed without fetching it again.
+type VersionConflictError struct {
+	Current interface{}
+	Version int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return models.ErrVersionConflict.Error()
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return models.ErrVersionConflict
+}
+
+func versionConflict(current interface{}, version int64) error {
+	return &Error{Kind: KindConflict, Err: &VersionConflictError{Current: current, Version: version}}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+
+	"github.com/haydnmeyburgh/cod-eSports-tournament-manager/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Decides who can see tournaments and their matches on the routes anyone can read, like
+// presence, live scores and event streams. A zero userID is someone who isn't signed in.
+type VisibilityService struct {
+	Tournaments   models.TournamentRepository
+	Matches       models.MatchRepository
+	Organisations models.OrganisationRepository
+}
+
+// Gets a tournament the user can see. Anyone can once it is published, only its staff
+// before then. Anyone else is told it wasn't found, so drafts aren't given away.
+func (s *VisibilityService) Tournament(c context.Context, userID, id primitive.ObjectID) (*models.Tournament, error) {
+	tournament, err := s.Tournaments.GetByID(c, id)
+	if err != nil {
+		return nil, classify(err)
+	}
+	if tournament.Published {
+		return tournament, nil
+	}
+
+	staff, err := models.IsTournamentStaff(c, s.Organisations, tournament, userID)
+	if err != nil {
+		return nil, classify(err)
+	}
+	if !staff {
+		return nil, notFound(models.ErrTournamentNotFound)
+	}
+	return tournament, nil
+}
+
+// Gets a match the user can see, which they can if they can see its tournament. A match
+// outside a tournament is only seen by whoever can manage it.
+func (s *VisibilityService) Match(c context.Context, userID, id primitive.ObjectID) (*models.Match, error) {
+	match, err := s.Matches.GetByID(c, id)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	if match.TournamentID.IsZero() {
+		canManage, err := models.CanManageResource(c, s.Organisations, match.OrganisationID, match.OrganiserID, userID)
+		if err != nil {
+			return nil, classify(err)
+		}
+		if userID.IsZero() || !canManage {
+			return nil, notFound(models.ErrMatchNotFound)
+		}
+		return match, nil
+	}
+
+	if _, err := s.Tournament(c, userID, match.TournamentID); err != nil {
+		if KindOf(err) == KindNotFound {
+			return nil, notFound(models.ErrMatchNotFound)
+		}
+		return nil, err
+	}
+	return match, nil
+}
+
+// Gets a tournament the user is on the staff of. Anyone who can't see it is told it wasn't
+// found, and anyone else that they aren't allowed.
+func (s *VisibilityService) StaffTournament(c context.Context, userID, id primitive.ObjectID) (*models.Tournament, error) {
+	tournament, err := s.Tournament(c, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	staff, err := models.IsTournamentStaff(c, s.Organisations, tournament, userID)
+	if err != nil {
+		return nil, classify(err)
+	}
+	if !staff {
+		return nil, forbidden(models.ErrNotOrganisationMember)
+	}
+	return tournament, nil
+}
+
+// Gets a match whose tournament the user is on the staff of, or that they can manage if it
+// isn't in a tournament
+func (s *VisibilityService) StaffMatch(c context.Context, userID, id primitive.ObjectID) (*models.Match, error) {
+	match, err := s.Match(c, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if match.TournamentID.IsZero() {
+		return match, nil
+	}
+
+	if _, err := s.StaffTournament(c, userID, match.TournamentID); err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+func NewVisibilityService(tournaments models.TournamentRepository, matches models.MatchRepository, organisations models.OrganisationRepository) *VisibilityService {
+	return &VisibilityService{
+		Tournaments:   tournaments,
+		Matches:       matches,
+		Organisations: organisations,
+	}
+}